@@ -0,0 +1,149 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jaypipes/kwiz/pkg/agent"
+	"github.com/jaypipes/kwiz/pkg/cgroup"
+	"github.com/jaypipes/kwiz/pkg/checkpoint"
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	"github.com/jaypipes/kwiz/pkg/podresources"
+	"github.com/jaypipes/kwiz/pkg/sysfs"
+)
+
+const (
+	agentNodeNameDesc = `Name of the Node the agent runs on. Defaults to the
+NODE_NAME environment variable, which should be set from spec.nodeName with
+the downward API.`
+	agentNamespaceDesc  = "Namespace to publish the Node's report ConfigMap in."
+	agentSysfsRootDesc  = "Directory the host's sysfs is mounted at."
+	agentKubeletDirDesc = `The kubelet's root directory, containing its CPU and
+memory manager checkpoints.`
+	agentPodResourcesSocketDesc = `Path of the unix socket the kubelet serves its
+PodResources API on. If the socket exists, the CPUs, memory and devices it
+reports as assigned to containers are used instead of the kubelet's
+checkpoints.`
+	agentCgroupRootDesc  = "Directory the host's cgroup hierarchy is mounted at."
+	agentUsageWindowDesc = `Interval over which to sample the CPU usage of the
+Pods on the Node, from their cgroups. 0 to not collect Pod usage.`
+	agentIntervalDesc        = "How often to collect and publish the Node's report."
+	agentOnceDesc            = "If true, collect and publish the Node's report once and exit."
+	agentReportNamespaceDesc = `Namespace to read the NUMA topology reports
+published by the kwiz agent from. Empty to not read agent reports.`
+	defaultAgentInterval    = time.Minute
+	defaultAgentUsageWindow = 5 * time.Second
+)
+
+var (
+	agentCollectOpts = agent.CollectOptions{}
+	agentNodeName    string
+	agentNamespace   string
+	agentInterval    time.Duration
+	agentOnce        bool
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Publish this node's NUMA topology and allocations",
+	Long: `Publish this node's NUMA topology and allocations.
+
+The agent is meant to run as a DaemonSet on every Node. It reads the local
+host's NUMA topology and the CPUs and memory the kubelet's CPU and memory
+managers have exclusively assigned to containers, and publishes them as a
+report in a ConfigMap, which the node and pod commands read.`,
+	RunE: runAgent,
+}
+
+func init() {
+	agentCmd.Flags().StringVar(&agentNodeName, "node-name", os.Getenv("NODE_NAME"), agentNodeNameDesc)
+	agentCmd.Flags().StringVar(&agentNamespace, "namespace", kagent.DefaultNamespace, agentNamespaceDesc)
+	agentCmd.Flags().StringVar(&agentCollectOpts.SysfsRoot, "sysfs-root", sysfs.DefaultRoot, agentSysfsRootDesc)
+	agentCmd.Flags().StringVar(&agentCollectOpts.KubeletDir, "kubelet-dir", checkpoint.DefaultKubeletDir, agentKubeletDirDesc)
+	agentCmd.Flags().StringVar(&agentCollectOpts.PodResourcesSocket, "pod-resources-socket", podresources.DefaultSocket, agentPodResourcesSocketDesc)
+	agentCmd.Flags().StringVar(&agentCollectOpts.CgroupRoot, "cgroup-root", cgroup.DefaultRoot, agentCgroupRootDesc)
+	agentCmd.Flags().DurationVar(&agentCollectOpts.UsageWindow, "usage-window", defaultAgentUsageWindow, agentUsageWindowDesc)
+	agentCmd.Flags().DurationVar(&agentInterval, "interval", defaultAgentInterval, agentIntervalDesc)
+	agentCmd.Flags().BoolVar(&agentOnce, "once", false, agentOnceDesc)
+	rootCmd.AddCommand(agentCmd)
+}
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	if agentNodeName == "" {
+		return fmt.Errorf("--node-name or the NODE_NAME environment variable is required")
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	if agentOnce {
+		return publishAgentReport(ctx, conn)
+	}
+	ticker := time.NewTicker(agentInterval)
+	defer ticker.Stop()
+	for {
+		// A failure to collect or publish one report should not stop the
+		// agent, since the next one may succeed
+		if err := publishAgentReport(ctx, conn); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to publish report: %s\n", err)
+		}
+		<-ticker.C
+	}
+}
+
+// getAgentReports returns the reports published by the kwiz agent in the
+// supplied namespace, keyed by Node name, printing a warning for each report
+// skipped. Returns no reports if the namespace is empty.
+func getAgentReports(
+	ctx context.Context,
+	conn *kconnect.Connection,
+	namespace string,
+) (map[string]*agent.Report, error) {
+	if namespace == "" {
+		return map[string]*agent.Report{}, nil
+	}
+	reports, warnings, err := kagent.Get(ctx, conn, namespace)
+	if err != nil {
+		return nil, err
+	}
+	printWarnings(warnings)
+	return reports, nil
+}
+
+// publishAgentReport collects and publishes the report of the Node the
+// agent runs on
+func publishAgentReport(ctx context.Context, conn *kconnect.Connection) error {
+	report, err := agent.Collect(ctx, agentNodeName, &agentCollectOpts)
+	if err != nil {
+		return err
+	}
+	printWarnings(report.Warnings)
+	if !agentOnce {
+		report.Interval = agentInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	return kagent.Publish(ctx, conn, agentNamespace, report)
+}
@@ -0,0 +1,196 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/cost"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	pricesPathDesc = `Path to a YAML or JSON file mapping Node instance types
+or labels to an hourly price.`
+	costGroupByDesc = `Node label key to group costs by (e.g.
+node.kubernetes.io/instance-type). No group summary is shown if empty.`
+)
+
+var (
+	costNodeGetOpts = knode.NodeGetOptions{}
+	pricesPath      string
+	costGroupBy     string
+)
+
+// costCmd represents the cost command
+var costCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Show cost of allocated, requested and idle capacity",
+	RunE:  showCostSummary,
+}
+
+func init() {
+	costCmd.Flags().StringVar(&pricesPath, "prices", "", pricesPathDesc)
+	costCmd.MarkFlagRequired("prices")
+	costCmd.Flags().StringVar(&costGroupBy, "group-by", "", costGroupByDesc)
+	cmdutil.AddLabelSelectorFlagVar(costCmd, &costNodeGetOpts.LabelSelector)
+	rootCmd.AddCommand(costCmd)
+}
+
+func showCostSummary(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+
+	prices, err := cost.Load(pricesPath)
+	if err != nil {
+		return err
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	nodes, err := knode.Get(ctx, conn, &costNodeGetOpts)
+	if err != nil {
+		return err
+	}
+	pods, err := kpod.Get(ctx, conn, &kpod.PodGetOptions{})
+	if err != nil {
+		return err
+	}
+
+	costTotals := types.Cost{}
+	nodeCosts := make([]types.Cost, len(nodes))
+	groupCosts := map[string]*types.Cost{}
+	for x, node := range nodes {
+		nc := prices.ForNode(node)
+		nodeCosts[x] = nc
+		costTotals.Add(nc)
+		if costGroupBy != "" {
+			group := node.Labels[costGroupBy]
+			gc, ok := groupCosts[group]
+			if !ok {
+				gc = &types.Cost{}
+				groupCosts[group] = gc
+			}
+			gc.Add(nc)
+		}
+	}
+	nsCosts := prices.ByNamespace(nodes, pods)
+
+	costHeaders := []string{"HOURLY", "RESERVED", "ALLOCATABLE", "REQUESTED", "IDLE"}
+	if prices.Currency != "" {
+		for x, h := range costHeaders {
+			costHeaders[x] = fmt.Sprintf("%s (%s)", h, prices.Currency)
+		}
+	}
+
+	switch outputFormat {
+	case outputFormatHuman:
+		headers := append([]string{"NODE", "INSTANCE TYPE"}, costHeaders...)
+		columnAligns := []int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: true})
+		table.SetHeader(headers)
+		table.SetColumnAlignment(columnAligns)
+		for x, node := range nodes {
+			table.Append(costRow(node.Name, node.InstanceType, nodeCosts[x]))
+		}
+		table.SetFooter(costRow("Totals", "", costTotals))
+		table.Render()
+
+		if costGroupBy != "" {
+			groups := make([]string, 0, len(groupCosts))
+			for group := range groupCosts {
+				groups = append(groups, group)
+			}
+			sort.Strings(groups)
+			grpTable := tablewriter.NewWriter(os.Stdout)
+			grpTable.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+			grpTable.SetHeader(append([]string{costGroupBy, ""}, costHeaders...))
+			grpTable.SetColumnAlignment(columnAligns)
+			for _, group := range groups {
+				grpTable.Append(costRow(group, "", *groupCosts[group]))
+			}
+			grpTable.Render()
+		}
+
+		namespaces := make([]string, 0, len(nsCosts))
+		for ns := range nsCosts {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		nsTable := tablewriter.NewWriter(os.Stdout)
+		nsTable.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+		nsTable.SetHeader([]string{"NAMESPACE", costHeaders[3], "SHARE OF REQUESTED"})
+		nsTable.SetColumnAlignment([]int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+		})
+		for _, ns := range namespaces {
+			nsCost := nsCosts[ns]
+			sharePct := float64(0)
+			if costTotals.Requested > 0 {
+				sharePct = (nsCost / costTotals.Requested) * 100
+			}
+			nsTable.Append([]string{
+				ns,
+				fmt.Sprintf("%.4f", nsCost),
+				fmt.Sprintf("%.2f%%", sharePct),
+			})
+		}
+		nsTable.Render()
+	}
+	return nil
+}
+
+// costRow returns the table row for a single Cost
+func costRow(
+	name string,
+	instanceType string,
+	c types.Cost,
+) []string {
+	return []string{
+		name,
+		instanceType,
+		fmt.Sprintf("%.4f", c.Hourly),
+		fmt.Sprintf("%.4f", c.Reserved),
+		fmt.Sprintf("%.4f", c.Allocatable),
+		fmt.Sprintf("%.4f", c.Requested),
+		fmt.Sprintf("%.4f", c.Idle),
+	}
+}
@@ -0,0 +1,342 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/cost"
+	"github.com/jaypipes/kwiz/pkg/group"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	kquota "github.com/jaypipes/kwiz/pkg/kube/quota"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+const (
+	nsGroupByLabelDesc = `Pod label key (e.g. team) to group Pods by instead of
+grouping by namespace. Pods without the label are grouped under <none>.`
+	nsPricesPathDesc = `Path to a YAML or JSON file mapping Node instance types
+or labels to an hourly price. If set, each group's share of cost is shown.`
+	nsShowQuotaDesc = `If true, shows the hard limits and used amounts of each
+namespace's ResourceQuotas and flags quotas that together exceed the
+cluster's allocatable capacity. Ignored when grouping by label.`
+)
+
+var (
+	nsGroupByLabel string
+	nsPricesPath   string
+	nsShowQuota    bool
+)
+
+// namespaceCmd represents the namespace command
+var namespaceCmd = &cobra.Command{
+	Use:     "namespace",
+	Short:   "Show resource requests by namespace or label",
+	Aliases: []string{"namespaces", "ns"},
+	RunE:    showNamespaceResourceSummary,
+}
+
+func init() {
+	namespaceCmd.Flags().StringVar(&nsGroupByLabel, "group-by-label", "", nsGroupByLabelDesc)
+	namespaceCmd.Flags().StringVar(&nsPricesPath, "prices", "", nsPricesPathDesc)
+	namespaceCmd.Flags().BoolVar(&nsShowQuota, "show-quota", false, nsShowQuotaDesc)
+	rootCmd.AddCommand(namespaceCmd)
+}
+
+func showNamespaceResourceSummary(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+	var prices *cost.Prices
+	var err error
+
+	if nsPricesPath != "" {
+		prices, err = cost.Load(nsPricesPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	nodes, err := knode.Get(ctx, conn, &knode.NodeGetOptions{})
+	if err != nil {
+		return err
+	}
+	pods, err := kpod.Get(ctx, conn, &kpod.PodGetOptions{})
+	if err != nil {
+		return err
+	}
+
+	resourceTotals := types.Resources{}
+	nodesByName := make(map[string]*types.Node, len(nodes))
+	for _, node := range nodes {
+		resourceTotals.Add(node.Resources)
+		nodesByName[node.Name] = node
+	}
+
+	groupHeader := "NAMESPACE"
+	var groups []*types.PodGroup
+	showQuota := false
+	if nsGroupByLabel != "" {
+		groupHeader = strings.ToUpper(nsGroupByLabel)
+		groups = group.ByLabel(pods, nsGroupByLabel)
+	} else {
+		groups = group.ByNamespace(pods)
+		showQuota = nsShowQuota
+	}
+
+	var nsQuotas map[string]*types.ResourceQuota
+	// quotaTotals contains the sum of all namespaces' hard limits. Amounts
+	// are -1 if no namespace's quota limits them.
+	quotaTotals := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: -1, Ceiling: -1},
+		Memory: types.ResourceRequest{Floor: -1, Ceiling: -1},
+	}
+	if showQuota {
+		quotas, err := kquota.Get(ctx, conn)
+		if err != nil {
+			return err
+		}
+		nsQuotas = kquota.ByNamespace(quotas)
+		for _, q := range nsQuotas {
+			addQuotaHard(&quotaTotals.CPU, q.Hard.CPU)
+			addQuotaHard(&quotaTotals.Memory, q.Hard.Memory)
+		}
+		// Namespaces that have a ResourceQuota but no Pods should still be
+		// shown.
+		hasGroup := make(map[string]bool, len(groups))
+		for _, g := range groups {
+			hasGroup[g.Name] = true
+		}
+		for ns := range nsQuotas {
+			if !hasGroup[ns] {
+				groups = append(groups, &types.PodGroup{Name: ns})
+			}
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			return groups[i].Name < groups[j].Name
+		})
+	}
+
+	// If prices are configured, calculate the cost of each group's request
+	// floor and the cost of all requests in the cluster.
+	groupCosts := make([]float64, len(groups))
+	totalCost := float64(0)
+	if prices != nil {
+		for x, g := range groups {
+			for _, p := range g.Pods {
+				if n, ok := nodesByName[p.Node]; ok {
+					groupCosts[x] += prices.ForPod(p, n)
+				}
+			}
+			totalCost += groupCosts[x]
+		}
+	}
+
+	switch outputFormat {
+	case outputFormatHuman:
+		headers := []string{
+			groupHeader, "PODS", "RESOURCE", "REQUEST FLOOR", "REQUEST CEIL",
+		}
+		columnAligns := []int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+		}
+		mergeColumns := []int{0, 1}
+		if showQuota {
+			headers = append(headers, "QUOTA HARD (REQ/LIM)", "QUOTA USED (REQ/LIM)")
+			columnAligns = append(columnAligns, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT)
+		}
+		if prices != nil {
+			costHeader := "COST"
+			if prices.Currency != "" {
+				costHeader = fmt.Sprintf("COST (%s)", prices.Currency)
+			}
+			mergeColumns = append(mergeColumns, len(headers))
+			headers = append(headers, costHeader)
+			columnAligns = append(columnAligns, tablewriter.ALIGN_RIGHT)
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAutoMergeCellsByColumnIndex(mergeColumns)
+		table.SetHeader(headers)
+		table.SetColumnAlignment(columnAligns)
+		table.SetRowLine(true)
+		for x, g := range groups {
+			name := g.Name
+			if name == "" {
+				name = "<none>"
+			}
+			podCount := fmt.Sprintf("%d", len(g.Pods))
+			costStr := ""
+			if prices != nil {
+				costPct := float64(0)
+				if totalCost > 0 {
+					costPct = (groupCosts[x] / totalCost) * 100
+				}
+				costStr = fmt.Sprintf("%.4f (%.2f%%)", groupCosts[x], costPct)
+			}
+			q, hasQuota := nsQuotas[g.Name]
+
+			cpu := g.ResourceRequests.CPU
+			cpuAlloc := resourceTotals.CPU.Allocatable
+			data := []string{
+				name,
+				podCount,
+				"CPU",
+				fmt.Sprintf("%.2f (%.2f%%)", cpu.Floor, (cpu.Floor/cpuAlloc)*100),
+				"-",
+			}
+			if cpu.Ceiling != -1 {
+				data[4] = fmt.Sprintf("%.2f (%.2f%%)", cpu.Ceiling, (cpu.Ceiling/cpuAlloc)*100)
+			}
+			if showQuota {
+				if hasQuota {
+					data = append(
+						data,
+						quotaString(q.Hard.CPU, cpuString),
+						quotaString(q.Used.CPU, cpuString),
+					)
+				} else {
+					data = append(data, "-", "-")
+				}
+			}
+			if prices != nil {
+				data = append(data, costStr)
+			}
+			table.Append(data)
+
+			mem := g.ResourceRequests.Memory
+			memAlloc := resourceTotals.Memory.Allocatable
+			data = []string{
+				name,
+				podCount,
+				"Memory",
+				fmt.Sprintf(
+					"%s (%.2f%%)",
+					unit.BytesToSizeString(mem.Floor),
+					(mem.Floor/memAlloc)*100,
+				),
+				"-",
+			}
+			if mem.Ceiling != -1 {
+				data[4] = fmt.Sprintf(
+					"%s (%.2f%%)",
+					unit.BytesToSizeString(mem.Ceiling),
+					(mem.Ceiling/memAlloc)*100,
+				)
+			}
+			if showQuota {
+				if hasQuota {
+					data = append(
+						data,
+						quotaString(q.Hard.Memory, unit.BytesToSizeString),
+						quotaString(q.Used.Memory, unit.BytesToSizeString),
+					)
+				} else {
+					data = append(data, "-", "-")
+				}
+			}
+			if prices != nil {
+				data = append(data, costStr)
+			}
+			table.Append(data)
+		}
+		table.Render()
+
+		if showQuota {
+			warnQuotaOvercommit(
+				"CPU requests", quotaTotals.CPU.Floor,
+				resourceTotals.CPU.Allocatable, cpuString,
+			)
+			warnQuotaOvercommit(
+				"CPU limits", quotaTotals.CPU.Ceiling,
+				resourceTotals.CPU.Allocatable, cpuString,
+			)
+			warnQuotaOvercommit(
+				"memory requests", quotaTotals.Memory.Floor,
+				resourceTotals.Memory.Allocatable, unit.BytesToSizeString,
+			)
+			warnQuotaOvercommit(
+				"memory limits", quotaTotals.Memory.Ceiling,
+				resourceTotals.Memory.Allocatable, unit.BytesToSizeString,
+			)
+		}
+	}
+	return nil
+}
+
+// cpuString returns a string representation of an amount of CPU
+func cpuString(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+// addQuotaHard adds a hard quota limit to a running total of hard quota
+// limits, where -1 means there is no limit.
+func addQuotaHard(total *types.ResourceRequest, hard types.ResourceRequest) {
+	if hard.Floor != -1 {
+		total.Floor = max(total.Floor, 0) + hard.Floor
+	}
+	if hard.Ceiling != -1 {
+		total.Ceiling = max(total.Ceiling, 0) + hard.Ceiling
+	}
+}
+
+// quotaString returns a "requests / limits" string for a quota amount, using
+// the supplied function to format each side. Amounts of -1 are shown as "-".
+func quotaString(r types.ResourceRequest, fmtFn func(float64) string) string {
+	floor, ceil := "-", "-"
+	if r.Floor != -1 {
+		floor = fmtFn(r.Floor)
+	}
+	if r.Ceiling != -1 {
+		ceil = fmtFn(r.Ceiling)
+	}
+	return floor + " / " + ceil
+}
+
+// warnQuotaOvercommit prints a warning if the sum of all namespaces' hard
+// quota limits for some amount exceeds the cluster's allocatable capacity.
+func warnQuotaOvercommit(
+	what string,
+	quotaTotal float64,
+	alloc float64,
+	fmtFn func(float64) string,
+) {
+	if quotaTotal == -1 || quotaTotal <= alloc {
+		return
+	}
+	fmt.Printf(
+		"WARNING: ResourceQuota hard %s total %s (%.2f%%) exceeds cluster allocatable %s\n",
+		what, fmtFn(quotaTotal), (quotaTotal/alloc)*100, fmtFn(alloc),
+	)
+}
@@ -10,38 +10,88 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 
 	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/cpuset"
+	"github.com/jaypipes/kwiz/pkg/fit"
 	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
 	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
 	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	"github.com/jaypipes/kwiz/pkg/reserved"
 	"github.com/jaypipes/kwiz/pkg/types"
 	"github.com/jaypipes/kwiz/pkg/unit"
 )
 
 const (
-	showActualDesc = "If true, instructs kwiz to go gather actual resource usage information from nodes"
+	showActualDesc       = "If true, instructs kwiz to go gather actual resource usage information from nodes"
+	nodeShowAdjustedDesc = `If true, applies the default limits of namespace
+LimitRanges to Pods with no limits and shows the adjusted request ceiling next
+to the worst-case one.`
+	nodeShowDaemonSetDesc = `If true, splits the request floor of each resource into
+the amount requested by DaemonSet-owned Pods and the amount requested by all
+other Pods, and shows a summary of DaemonSet overhead per node pool.`
+	nodePoolLabelDesc = `Node label key used to group Nodes into pools for the
+DaemonSet overhead summary.`
+	defaultNodePoolLabel  = "node.kubernetes.io/instance-type"
+	nodeShowExclusiveDesc = `If true, shows the CPUs requested by Guaranteed Pods
+with whole-number CPU requests, which are pinned to exclusive CPUs under the
+static CPU manager policy, separately from the CPUs in the shared pool, per
+Node and per NUMA cell. Reads each Node's kubelet configuration for its CPU
+manager policy; "-" is shown for Nodes whose policy cannot be read.`
+	nodeShowReservedDesc = `If true, reads each Node's kubelet configuration and
+shows how much of the RESERVED amount is caused by kube-reserved,
+system-reserved, reserved system CPUs, the hard eviction threshold and
+hugepages. Remainders not explained by these settings are flagged.`
+	nodeShowCoresDesc = `If true, shows the CPU topology of each NUMA cell and
+its free capacity in whole physical cores as well as logical CPUs.`
 )
 
 var (
-	nodeGetOpts      = knode.NodeGetOptions{}
-	showActual  bool = false
+	nodeGetOpts       = knode.NodeGetOptions{}
+	nodeAgentNS       string
+	showActual        bool = false
+	nodeShowAdjusted  bool = false
+	nodeShowDaemonSet bool = false
+	nodePoolLabel     string
+	nodeShowExclusive bool = false
+	nodeShowReserved  bool = false
+	nodeShowCores     bool = false
+	// nonExclusiveAmounts is used to show "-" in the EXCLUSIVE and SHARED
+	// POOL columns for resources that cannot be pinned, like Pods
+	nonExclusiveAmounts = types.ResourceAmounts{ExclusiveRequestedFloor: -1}
 )
 
 // nodeCmd represents the node command
 var nodeCmd = &cobra.Command{
-	Use:     "node",
-	Short:   "Show node resource summary",
+	Use:   "node [NAME]",
+	Short: "Show node resource summary",
+	Long: `Show node resource summary.
+
+If a Node name is given, shows the detail of that Node, including the kubelet
+CPU, memory and Topology Manager settings read from the kubelet's /configz
+endpoint.`,
 	Aliases: []string{"nodes"},
+	Args:    cobra.MaximumNArgs(1),
 	RunE:    showNodeResourceSummary,
 }
 
 func init() {
 	nodeCmd.PersistentFlags().BoolVarP(&showActual, "show-actual", "a", false, showActualDesc)
+	nodeCmd.PersistentFlags().BoolVar(&nodeShowAdjusted, "show-adjusted", false, nodeShowAdjustedDesc)
+	nodeCmd.PersistentFlags().BoolVar(&nodeShowDaemonSet, "show-daemonset", false, nodeShowDaemonSetDesc)
+	nodeCmd.PersistentFlags().StringVar(&nodePoolLabel, "pool-label", defaultNodePoolLabel, nodePoolLabelDesc)
+	nodeCmd.PersistentFlags().BoolVar(&nodeShowExclusive, "show-exclusive", false, nodeShowExclusiveDesc)
+	nodeCmd.PersistentFlags().BoolVar(&nodeShowReserved, "show-reserved", false, nodeShowReservedDesc)
+	nodeCmd.PersistentFlags().BoolVar(&nodeShowCores, "show-cores", false, nodeShowCoresDesc)
+	nodeCmd.PersistentFlags().StringVar(&nodeAgentNS, "agent-namespace", kagent.DefaultNamespace, agentReportNamespaceDesc)
 	cmdutil.AddLabelSelectorFlagVar(nodeCmd, &nodeGetOpts.LabelSelector)
 	rootCmd.AddCommand(nodeCmd)
 }
@@ -63,50 +113,60 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
+	nodeGetOpts.ApplyLimitRanges = nodeShowAdjusted
+	nodeGetOpts.KubeletConfig = nodeShowReserved || nodeShowExclusive
+	if len(args) == 1 {
+		nodeGetOpts.Name = args[0]
+		nodeGetOpts.KubeletConfig = true
+	}
+	nodeGetOpts.AgentReports, err = getAgentReports(ctx, conn, nodeAgentNS)
+	if err != nil {
+		return err
+	}
 	nodes, err := knode.Get(ctx, conn, &nodeGetOpts)
 	if err != nil {
 		return err
 	}
+	printWarnings(kubeletConfigWarnings(nodes))
+	if nodeGetOpts.Name != "" {
+		if len(nodes) == 0 {
+			return fmt.Errorf("node %q not found", nodeGetOpts.Name)
+		}
+		if outputFormat != outputFormatHuman {
+			return printStructured(nodes[0])
+		}
+		showNodeDetail(nodes[0])
+		showReservedBreakdown(nodes)
+		showNUMACellCores(nodes)
+		return nil
+	}
 
 	resourceTotals := types.Resources{}
 	for _, node := range nodes {
-		cpu := node.Resources.CPU
-		resourceTotals.CPU.Capacity += cpu.Capacity
-		resourceTotals.CPU.Allocatable += cpu.Allocatable
-		resourceTotals.CPU.Reserved += cpu.Reserved
-		resourceTotals.CPU.RequestedFloor += cpu.RequestedFloor
-		// If there is any Pod on the Node that has no limits set for this
-		// resource, it can potentially consume all of the resource on the
-		// Node. So, we treat ceiling == -1 specially.
-		if cpu.RequestedCeiling == -1 || resourceTotals.CPU.RequestedCeiling == -1 {
-			resourceTotals.CPU.RequestedCeiling = -1
-		} else {
-			resourceTotals.CPU.RequestedCeiling += cpu.RequestedCeiling
-		}
-		resourceTotals.CPU.Used += cpu.Used
-		mem := node.Resources.Memory
-		resourceTotals.Memory.Capacity += mem.Capacity
-		resourceTotals.Memory.Allocatable += mem.Allocatable
-		resourceTotals.Memory.Reserved += mem.Reserved
-		resourceTotals.Memory.RequestedFloor += mem.RequestedFloor
-		if mem.RequestedCeiling == -1 || resourceTotals.Memory.RequestedCeiling == -1 {
-			resourceTotals.Memory.RequestedCeiling = -1
-		} else {
-			resourceTotals.Memory.RequestedCeiling += mem.RequestedCeiling
-		}
-		resourceTotals.Memory.Used += mem.Used
-		pods := node.Resources.Pods
-		resourceTotals.Pods.Capacity += pods.Capacity
-		resourceTotals.Pods.Allocatable += pods.Allocatable
-		resourceTotals.Pods.Reserved += pods.Reserved
-		resourceTotals.Pods.RequestedFloor += pods.RequestedFloor
-		resourceTotals.Pods.RequestedCeiling += pods.RequestedCeiling
-		resourceTotals.Pods.Used += pods.Used
+		resourceTotals.Add(node.Resources)
+	}
+
+	// The largest Pod that fits in a single NUMA cell of any Node
+	clusterLargest := types.ResourceRequests{}
+	haveLargest := false
+	for _, node := range nodes {
+		largest, ok := fit.LargestAligned(node.NUMACells)
+		if !ok {
+			continue
+		}
+		if !haveLargest || largest.CPU.Floor > clusterLargest.CPU.Floor ||
+			(largest.CPU.Floor == clusterLargest.CPU.Floor &&
+				largest.Memory.Floor > clusterLargest.Memory.Floor) {
+			clusterLargest = largest
+		}
+		haveLargest = true
 	}
 
 	maxNodeNameLen := 0
 
 	switch outputFormat {
+	case outputFormatJSON, outputFormatYAML:
+		return printStructured(nodes)
 	case outputFormatHuman:
 		headers := []string{
 			"NODE", "RESOURCE", "CAPACITY", "RESERVED", "REQUEST FLOOR", "REQUEST CEIL",
@@ -125,12 +185,27 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 		if showActual {
 			columnAligns = append(columnAligns, tablewriter.ALIGN_RIGHT)
 		}
+		if nodeShowAdjusted {
+			headers = slices.Insert(headers, 6, "ADJ CEIL")
+			columnAligns = slices.Insert(columnAligns, 6, tablewriter.ALIGN_RIGHT)
+		}
+		if nodeShowDaemonSet {
+			headers = slices.Insert(headers, 4, "DAEMONSET", "WORKLOAD")
+			columnAligns = slices.Insert(columnAligns, 4, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT)
+		}
+		headers = append(headers, "LARGEST ALIGNED")
+		columnAligns = append(columnAligns, tablewriter.ALIGN_RIGHT)
+		if nodeShowExclusive {
+			headers = append(headers, "EXCLUSIVE", "SHARED POOL")
+			columnAligns = append(columnAligns, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT)
+		}
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetAutoMergeCells(true)
 		table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: true})
 		table.SetHeader(headers)
 		table.SetColumnAlignment(columnAligns)
 		for _, node := range nodes {
+			largestCPU, largestMem := largestAlignedStrings(fit.LargestAligned(node.NUMACells))
 			cpu := node.Resources.CPU
 			cpuFloorPct := (cpu.RequestedFloor / cpu.Allocatable) * 100
 			cpuFloorStr := fmt.Sprintf("%.0f (%.2f%%)", cpu.RequestedFloor, cpuFloorPct)
@@ -142,8 +217,7 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 			}
 			cpuCeilPct := (cpuCeil / cpu.Allocatable) * 100
 			cpuCeilStr := fmt.Sprintf("%.0f (%.2f%%)", cpuCeil, cpuCeilPct)
-			cpuUsedPct := (cpu.Used / cpu.Allocatable) * 100
-			cpuUsedStr := fmt.Sprintf("%.0f (%.2f%%)", cpu.Used, cpuUsedPct)
+			cpuUsedStr, cpuUsedPct := usedString(cpu, wholeNumberString)
 
 			data := []string{
 				node.Name,
@@ -157,6 +231,23 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 				data = append(data, cpuUsedStr)
 			}
 			fieldColors := fieldColorsByPct(cpuFloorPct, cpuCeilPct, cpuUsedPct)
+			if nodeShowAdjusted {
+				adjCeilStr, adjCeilPct := adjustedCeilingString(cpu, wholeNumberString)
+				data = slices.Insert(data, 6, adjCeilStr)
+				fieldColors = slices.Insert(fieldColors, 6, colorByPct(adjCeilPct))
+			}
+			if nodeShowDaemonSet {
+				data = slices.Insert(
+					data, 4,
+					daemonSetString(cpu, cpuString),
+					workloadString(cpu, cpuString),
+				)
+				fieldColors = slices.Insert(fieldColors, 4, tablewriter.Colors{}, tablewriter.Colors{})
+			}
+			data, fieldColors = appendField(data, fieldColors, largestCPU)
+			if nodeShowExclusive {
+				data, fieldColors = appendExclusiveFields(data, fieldColors, cpu)
+			}
 			table.Rich(data, fieldColors)
 
 			mem := node.Resources.Memory
@@ -170,12 +261,7 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 			}
 			memCeilPct := (memCeil / mem.Allocatable) * 100
 			memCeilStr := fmt.Sprintf("%s (%.2f%%)", unit.BytesToSizeString(memCeil), memCeilPct)
-			memUsedPct := (mem.Used / mem.Allocatable) * 100
-			memUsedStr := fmt.Sprintf(
-				"%s (%.2f%%)",
-				unit.BytesToSizeString(mem.Used),
-				memUsedPct,
-			)
+			memUsedStr, memUsedPct := usedString(mem, unit.BytesToSizeString)
 
 			data = []string{
 				node.Name,
@@ -189,6 +275,23 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 				data = append(data, memUsedStr)
 			}
 			fieldColors = fieldColorsByPct(memFloorPct, memCeilPct, memUsedPct)
+			if nodeShowAdjusted {
+				adjCeilStr, adjCeilPct := adjustedCeilingString(mem, unit.BytesToSizeString)
+				data = slices.Insert(data, 6, adjCeilStr)
+				fieldColors = slices.Insert(fieldColors, 6, colorByPct(adjCeilPct))
+			}
+			if nodeShowDaemonSet {
+				data = slices.Insert(
+					data, 4,
+					daemonSetString(mem, unit.BytesToSizeString),
+					workloadString(mem, unit.BytesToSizeString),
+				)
+				fieldColors = slices.Insert(fieldColors, 4, tablewriter.Colors{}, tablewriter.Colors{})
+			}
+			data, fieldColors = appendField(data, fieldColors, largestMem)
+			if nodeShowExclusive {
+				data, fieldColors = appendExclusiveFields(data, fieldColors, nonExclusiveAmounts)
+			}
 			table.Rich(data, fieldColors)
 
 			pod := node.Resources.Pods
@@ -211,6 +314,23 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 			}
 			maxNodeNameLen = max(maxNodeNameLen, len(node.Name))
 			fieldColors = fieldColorsByPct(podFloorPct, podCeilPct, podCeilPct)
+			if nodeShowAdjusted {
+				adjCeilStr, adjCeilPct := adjustedCeilingString(pod, wholeNumberString)
+				data = slices.Insert(data, 6, adjCeilStr)
+				fieldColors = slices.Insert(fieldColors, 6, colorByPct(adjCeilPct))
+			}
+			if nodeShowDaemonSet {
+				data = slices.Insert(
+					data, 4,
+					daemonSetString(pod, wholeNumberString),
+					workloadString(pod, wholeNumberString),
+				)
+				fieldColors = slices.Insert(fieldColors, 4, tablewriter.Colors{}, tablewriter.Colors{})
+			}
+			data, fieldColors = appendField(data, fieldColors, "-")
+			if nodeShowExclusive {
+				data, fieldColors = appendExclusiveFields(data, fieldColors, nonExclusiveAmounts)
+			}
 			table.Rich(data, fieldColors)
 		}
 		table.Render()
@@ -235,11 +355,26 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 		if showActual {
 			totColumnAligns = append(columnAligns, tablewriter.ALIGN_RIGHT)
 		}
+		if nodeShowAdjusted {
+			totHeaders = slices.Insert(totHeaders, 6, "ADJ CEIL")
+			totColumnAligns = slices.Insert(totColumnAligns, 6, tablewriter.ALIGN_RIGHT)
+		}
+		if nodeShowDaemonSet {
+			totHeaders = slices.Insert(totHeaders, 4, "DAEMONSET", "WORKLOAD")
+			totColumnAligns = slices.Insert(totColumnAligns, 4, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT)
+		}
+		totHeaders = append(totHeaders, "LARGEST ALIGNED")
+		totColumnAligns = append(totColumnAligns, tablewriter.ALIGN_RIGHT)
+		if nodeShowExclusive {
+			totHeaders = append(totHeaders, "EXCLUSIVE", "SHARED POOL")
+			totColumnAligns = append(totColumnAligns, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT)
+		}
 		totTable.SetHeader(totHeaders)
 		totTable.SetAutoMergeCells(true)
 		totTable.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
 		totTable.SetColumnAlignment(totColumnAligns)
 
+		largestCPU, largestMem := largestAlignedStrings(clusterLargest, haveLargest)
 		cpu := resourceTotals.CPU
 		cpuFloorPct := (cpu.RequestedFloor / cpu.Allocatable) * 100
 		cpuFloorStr := fmt.Sprintf("%.0f (%.2f%%)", cpu.RequestedFloor, cpuFloorPct)
@@ -251,8 +386,7 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 		}
 		cpuCeilPct := (cpuCeil / cpu.Allocatable) * 100
 		cpuCeilStr := fmt.Sprintf("%.0f (%.2f%%)", cpuCeil, cpuCeilPct)
-		cpuUsedPct := (cpu.Used / cpu.Allocatable) * 100
-		cpuUsedStr := fmt.Sprintf("%.0f (%.2f%%)", cpu.Used, cpuUsedPct)
+		cpuUsedStr, cpuUsedPct := usedString(cpu, wholeNumberString)
 
 		data := []string{
 			fmt.Sprintf(totalsFormatStr, "Totals"),
@@ -266,6 +400,23 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 			data = append(data, cpuUsedStr)
 		}
 		fieldColors := fieldColorsByPct(cpuFloorPct, cpuCeilPct, cpuUsedPct)
+		if nodeShowAdjusted {
+			adjCeilStr, adjCeilPct := adjustedCeilingString(cpu, wholeNumberString)
+			data = slices.Insert(data, 6, adjCeilStr)
+			fieldColors = slices.Insert(fieldColors, 6, colorByPct(adjCeilPct))
+		}
+		if nodeShowDaemonSet {
+			data = slices.Insert(
+				data, 4,
+				daemonSetString(cpu, cpuString),
+				workloadString(cpu, cpuString),
+			)
+			fieldColors = slices.Insert(fieldColors, 4, tablewriter.Colors{}, tablewriter.Colors{})
+		}
+		data, fieldColors = appendField(data, fieldColors, largestCPU)
+		if nodeShowExclusive {
+			data, fieldColors = appendExclusiveFields(data, fieldColors, cpu)
+		}
 		totTable.Rich(data, fieldColors)
 
 		mem := resourceTotals.Memory
@@ -279,12 +430,7 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 		}
 		memCeilPct := (memCeil / mem.Allocatable) * 100
 		memCeilStr := fmt.Sprintf("%s (%.2f%%)", unit.BytesToSizeString(memCeil), memCeilPct)
-		memUsedPct := (mem.Used / mem.Allocatable) * 100
-		memUsedStr := fmt.Sprintf(
-			"%s (%.2f%%)",
-			unit.BytesToSizeString(mem.Used),
-			memUsedPct,
-		)
+		memUsedStr, memUsedPct := usedString(mem, unit.BytesToSizeString)
 
 		data = []string{
 			fmt.Sprintf(totalsFormatStr, "Totals"),
@@ -298,6 +444,23 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 			data = append(data, memUsedStr)
 		}
 		fieldColors = fieldColorsByPct(memFloorPct, memCeilPct, memUsedPct)
+		if nodeShowAdjusted {
+			adjCeilStr, adjCeilPct := adjustedCeilingString(mem, unit.BytesToSizeString)
+			data = slices.Insert(data, 6, adjCeilStr)
+			fieldColors = slices.Insert(fieldColors, 6, colorByPct(adjCeilPct))
+		}
+		if nodeShowDaemonSet {
+			data = slices.Insert(
+				data, 4,
+				daemonSetString(mem, unit.BytesToSizeString),
+				workloadString(mem, unit.BytesToSizeString),
+			)
+			fieldColors = slices.Insert(fieldColors, 4, tablewriter.Colors{}, tablewriter.Colors{})
+		}
+		data, fieldColors = appendField(data, fieldColors, largestMem)
+		if nodeShowExclusive {
+			data, fieldColors = appendExclusiveFields(data, fieldColors, nonExclusiveAmounts)
+		}
 		totTable.Rich(data, fieldColors)
 
 		pod := resourceTotals.Pods
@@ -319,37 +482,456 @@ func showNodeResourceSummary(cmd *cobra.Command, args []string) error {
 			data = append(data, podCeilStr)
 		}
 		fieldColors = fieldColorsByPct(podFloorPct, podCeilPct, podCeilPct)
+		if nodeShowAdjusted {
+			adjCeilStr, adjCeilPct := adjustedCeilingString(pod, wholeNumberString)
+			data = slices.Insert(data, 6, adjCeilStr)
+			fieldColors = slices.Insert(fieldColors, 6, colorByPct(adjCeilPct))
+		}
+		if nodeShowDaemonSet {
+			data = slices.Insert(
+				data, 4,
+				daemonSetString(pod, wholeNumberString),
+				workloadString(pod, wholeNumberString),
+			)
+			fieldColors = slices.Insert(fieldColors, 4, tablewriter.Colors{}, tablewriter.Colors{})
+		}
+		data, fieldColors = appendField(data, fieldColors, "-")
+		if nodeShowExclusive {
+			data, fieldColors = appendExclusiveFields(data, fieldColors, nonExclusiveAmounts)
+		}
 		totTable.Rich(data, fieldColors)
 
 		totTable.Render()
+
+		if nodeShowDaemonSet {
+			showDaemonSetPoolSummary(nodes)
+		}
+		if nodeShowExclusive {
+			showExclusiveNUMACellSummary(nodes)
+		}
+		if nodeShowReserved {
+			showReservedBreakdown(nodes)
+		}
+		if nodeShowCores {
+			showNUMACellCores(nodes)
+		}
 	}
 	return nil
 }
 
+// showNodeDetail prints the detail of a single Node, including its kubelet
+// configuration and NUMA cells
+func showNodeDetail(node *types.Node) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+	table.SetColumnAlignment([]int{tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"NODE", node.Name})
+	table.Append([]string{"Address", node.Address})
+	table.Append([]string{"Instance Type", node.InstanceType})
+	cpu := node.Resources.CPU
+	mem := node.Resources.Memory
+	table.Append([]string{
+		"CPU (cap/alloc/reserved)",
+		fmt.Sprintf("%s / %s / %s", cpuString(cpu.Capacity), cpuString(cpu.Allocatable), cpuString(cpu.Reserved)),
+	})
+	table.Append([]string{
+		"Memory (cap/alloc/reserved)",
+		fmt.Sprintf(
+			"%s / %s / %s",
+			unit.BytesToSizeString(mem.Capacity),
+			unit.BytesToSizeString(mem.Allocatable),
+			unit.BytesToSizeString(mem.Reserved),
+		),
+	})
+	table.Append([]string{"NUMA Cells", fmt.Sprintf("%d", len(node.NUMACells))})
+	kc := node.KubeletConfig
+	if kc != nil {
+		table.Append([]string{"CPU Manager Policy", kc.CPUManagerPolicy})
+		if len(kc.CPUManagerPolicyOptions) > 0 {
+			table.Append([]string{"CPU Manager Policy Options", mapString(kc.CPUManagerPolicyOptions)})
+		}
+		table.Append([]string{"Memory Manager Policy", kc.MemoryManagerPolicy})
+		table.Append([]string{"Topology Manager Policy", kc.TopologyManagerPolicy})
+		table.Append([]string{"Topology Manager Scope", kc.TopologyManagerScope})
+		table.Append([]string{"Reserved System CPUs", valueOrDash(kc.ReservedSystemCPUs)})
+		table.Append([]string{"Kube Reserved", valueOrDash(mapString(kc.KubeReserved))})
+		table.Append([]string{"System Reserved", valueOrDash(mapString(kc.SystemReserved))})
+		table.Append([]string{"Eviction Hard", valueOrDash(mapString(kc.EvictionHard))})
+		for _, rm := range kc.ReservedMemory {
+			table.Append([]string{
+				fmt.Sprintf("Reserved Memory (NUMA cell %d)", rm.NUMACellID),
+				mapString(rm.Limits),
+			})
+		}
+	}
+	table.Render()
+}
+
+// showReservedBreakdown prints a table attributing the reserved CPU and
+// memory of the supplied Nodes to the kubelet settings that reserve them.
+// Unexplained remainders are shown in red.
+func showReservedBreakdown(nodes []*types.Node) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0})
+	table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+	table.SetHeader([]string{
+		"NODE", "RESOURCE", "RESERVED", "KUBE", "SYSTEM", "SYSTEM CPUS",
+		"EVICTION", "HUGEPAGES", "UNEXPLAINED",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	for _, node := range nodes {
+		for _, res := range []struct {
+			name    string
+			amounts types.ResourceAmounts
+			str     func(float64) string
+		}{
+			{"CPU", node.Resources.CPU, cpuString},
+			{"Memory", node.Resources.Memory, unit.BytesToSizeString},
+		} {
+			b := res.amounts.ReservedBreakdown
+			if b == nil {
+				continue
+			}
+			colors := make([]tablewriter.Colors, 9)
+			if reserved.IsUnexplained(b, res.amounts.Capacity) {
+				colors[8] = twColorRedNormal
+			}
+			data := []string{
+				node.Name,
+				res.name,
+				res.str(res.amounts.Reserved),
+				res.str(b.KubeReserved),
+				res.str(b.SystemReserved),
+				res.str(b.ReservedSystemCPUs),
+				res.str(b.EvictionHard),
+				res.str(b.Hugepages),
+				res.str(b.Unexplained),
+			}
+			// Reserved system CPUs only apply to CPU and eviction
+			// thresholds and hugepages only apply to memory
+			if res.name == "CPU" {
+				data[6], data[7] = "-", "-"
+			} else {
+				data[5] = "-"
+			}
+			table.Rich(data, colors)
+		}
+	}
+	table.Render()
+}
+
+// showNUMACellCores prints a table of the CPU topology of each NUMA cell of
+// the supplied Nodes, with free capacity in logical CPUs and whole physical
+// cores. Nodes with no known NUMA cells are skipped.
+func showNUMACellCores(nodes []*types.Node) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0})
+	table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+	table.SetHeader([]string{
+		"NODE", "NUMA CELL", "SOCKET", "CORES", "THREADS/CORE", "FREE CPUS", "FREE CORES",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	rows := 0
+	for _, node := range nodes {
+		var reservedCPUs []int
+		if node.KubeletConfig != nil {
+			// An invalid cpuset would have been reported when breaking
+			// down the Node's reserved CPUs
+			reservedCPUs, _ = cpuset.Parse(node.KubeletConfig.ReservedSystemCPUs)
+		}
+		for _, cell := range node.NUMACells {
+			socket := "-"
+			if cell.SocketID != -1 {
+				socket = fmt.Sprintf("%d", cell.SocketID)
+			}
+			cores, threads, freeCores := "-", "-", "-"
+			if free, ok := fit.FreeCores(cell, reservedCPUs); ok {
+				cores = fmt.Sprintf("%d", len(cell.Cores))
+				threads = fmt.Sprintf("%d", cell.ThreadsPerCore())
+				freeCores = wholeNumberString(free)
+			}
+			table.Append([]string{
+				node.Name,
+				fmt.Sprintf("%d", cell.ID),
+				socket,
+				cores,
+				threads,
+				freeCPUString(cell.Resources.CPU),
+				freeCores,
+			})
+			rows++
+		}
+	}
+	if rows > 0 {
+		table.Render()
+	}
+}
+
+// freeCPUString returns a string representation of the unrequested CPUs of
+// a NUMA cell, or "-" if the requested CPUs are unknown
+func freeCPUString(cpu types.ResourceAmounts) string {
+	if cpu.RequestedFloor == -1 {
+		return "-"
+	}
+	return cpuString(fit.Free(cpu))
+}
+
+// mapString returns a string representation of a map of strings, sorted by
+// key, e.g. "cpu=500m, memory=1Gi"
+func mapString(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for x, k := range keys {
+		pairs[x] = k + "=" + m[k]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// valueOrDash returns s, or "-" if s is empty
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// showDaemonSetPoolSummary prints a table of the resources requested by
+// DaemonSet-owned Pods in each pool of Nodes, as a percentage of the pool's
+// allocatable resources.
+func showDaemonSetPoolSummary(nodes []*types.Node) {
+	poolNodes := map[string]int{}
+	poolRes := map[string]*types.Resources{}
+	pools := []string{}
+	for _, node := range nodes {
+		pool := node.Labels[nodePoolLabel]
+		res, ok := poolRes[pool]
+		if !ok {
+			res = &types.Resources{}
+			poolRes[pool] = res
+			pools = append(pools, pool)
+		}
+		res.Add(node.Resources)
+		poolNodes[pool]++
+	}
+	sort.Strings(pools)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
+	table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+	table.SetHeader([]string{
+		strings.ToUpper(nodePoolLabel), "NODES", "RESOURCE", "ALLOCATABLE", "DAEMONSET",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	for _, pool := range pools {
+		res := poolRes[pool]
+		name := pool
+		if name == "" {
+			name = "<none>"
+		}
+		nodeCount := fmt.Sprintf("%d", poolNodes[pool])
+		table.Append([]string{
+			name, nodeCount, "CPU",
+			cpuString(res.CPU.Allocatable),
+			daemonSetString(res.CPU, cpuString),
+		})
+		table.Append([]string{
+			name, nodeCount, "Memory",
+			unit.BytesToSizeString(res.Memory.Allocatable),
+			daemonSetString(res.Memory, unit.BytesToSizeString),
+		})
+		table.Append([]string{
+			name, nodeCount, "Pods",
+			wholeNumberString(res.Pods.Allocatable),
+			daemonSetString(res.Pods, wholeNumberString),
+		})
+	}
+	table.Render()
+}
+
 func fieldColorsByPct(floorPct, ceilPct, usedPct float64) []tablewriter.Colors {
-	colors := []tablewriter.Colors{
+	return []tablewriter.Colors{
 		tablewriter.Colors{},
 		tablewriter.Colors{},
 		tablewriter.Colors{},
 		tablewriter.Colors{},
-		twColorGreenNormal,
-		twColorGreenNormal,
-		twColorGreenNormal,
+		colorByPct(floorPct),
+		colorByPct(ceilPct),
+		colorByPct(usedPct),
 	}
-	if floorPct > float64(85) {
-		colors[4] = twColorRedNormal
-	} else if floorPct > float64(75) {
-		colors[4] = twColorYellowNormal
+}
+
+// colorByPct returns the table field color for a percentage of allocatable
+func colorByPct(pct float64) tablewriter.Colors {
+	if pct > float64(85) {
+		return twColorRedNormal
+	} else if pct > float64(75) {
+		return twColorYellowNormal
+	}
+	return twColorGreenNormal
+}
+
+// adjustedCeilingString returns the string representation of a resource's
+// AdjustedRequestedCeiling, formatting the amount with the supplied
+// function, along with its percentage of the resource's allocatable amount.
+func adjustedCeilingString(
+	amounts types.ResourceAmounts,
+	fmtFn func(float64) string,
+) (string, float64) {
+	ceil := amounts.AdjustedRequestedCeiling
+	if ceil == -1 {
+		// If any Pod is still unbounded, it can consume all of the
+		// resource...
+		ceil = amounts.Allocatable
+	}
+	pct := (ceil / amounts.Allocatable) * 100
+	return fmt.Sprintf("%s (%.2f%%)", fmtFn(ceil), pct), pct
+}
+
+// appendField appends a field with no color to a table row and its field
+// colors. The field colors of a row always include the ACTUAL column, which
+// may not be shown, so any colors beyond the row's fields are dropped first.
+func appendField(
+	data []string,
+	colors []tablewriter.Colors,
+	field string,
+) ([]string, []tablewriter.Colors) {
+	colors = colors[:min(len(colors), len(data))]
+	return append(data, field), append(colors, tablewriter.Colors{})
+}
+
+// largestAlignedStrings returns string representations of the CPU and
+// memory of the largest request that fits in a single NUMA cell, or "-" if
+// the NUMA cells are not known
+func largestAlignedStrings(reqs types.ResourceRequests, ok bool) (string, string) {
+	if !ok {
+		return "-", "-"
+	}
+	return cpuString(reqs.CPU.Floor), unit.BytesToSizeString(reqs.Memory.Floor)
+}
+
+// appendExclusiveFields appends the exclusive and shared pool amounts of a
+// resource to a table row and its field colors. An ExclusiveRequestedFloor
+// of -1 means the resource cannot be used exclusively and "-" is shown.
+func appendExclusiveFields(
+	data []string,
+	colors []tablewriter.Colors,
+	amounts types.ResourceAmounts,
+) ([]string, []tablewriter.Colors) {
+	// The field colors always include the ACTUAL column, which may not be
+	// shown. Callers that don't color the row pass no colors.
+	colors = colors[:min(len(colors), len(data))]
+	colors = append(colors, tablewriter.Colors{}, tablewriter.Colors{})
+	if amounts.ExclusiveRequestedFloor == -1 {
+		return append(data, "-", "-"), colors
+	}
+	excl := amounts.ExclusiveRequestedFloor
+	return append(
+		data,
+		fmt.Sprintf("%.0f (%.2f%%)", excl, (excl/amounts.Allocatable)*100),
+		cpuString(amounts.Allocatable-excl),
+	), colors
+}
+
+// showExclusiveNUMACellSummary prints a table of the exclusive and shared
+// pool CPUs in each NUMA cell of the supplied Nodes. Nodes with no known
+// NUMA cells are skipped.
+func showExclusiveNUMACellSummary(nodes []*types.Node) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0})
+	table.SetBorders(tablewriter.Border{Left: false, Right: false, Bottom: true, Top: false})
+	table.SetHeader([]string{"NODE", "NUMA CELL", "CPU", "EXCLUSIVE", "SHARED POOL"})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	rows := 0
+	for _, node := range nodes {
+		for _, cell := range node.NUMACells {
+			cpu := cell.Resources.CPU
+			data := []string{
+				node.Name,
+				fmt.Sprintf("%d", cell.ID),
+				cpuString(cpu.Allocatable),
+			}
+			data, _ = appendExclusiveFields(data, nil, cpu)
+			table.Append(data)
+			rows++
+		}
 	}
-	if ceilPct > float64(85) {
-		colors[5] = twColorRedNormal
-	} else if ceilPct > float64(75) {
-		colors[5] = twColorYellowNormal
+	if rows > 0 {
+		table.Render()
 	}
-	if usedPct > float64(85) {
-		colors[6] = twColorRedNormal
-	} else if usedPct > float64(75) {
-		colors[6] = twColorYellowNormal
+}
+
+// daemonSetString returns the string representation of a resource's
+// DaemonSetRequestedFloor, formatting the amount with the supplied function,
+// along with its percentage of the resource's allocatable amount.
+func daemonSetString(
+	amounts types.ResourceAmounts,
+	fmtFn func(float64) string,
+) string {
+	pct := (amounts.DaemonSetRequestedFloor / amounts.Allocatable) * 100
+	return fmt.Sprintf("%s (%.2f%%)", fmtFn(amounts.DaemonSetRequestedFloor), pct)
+}
+
+// workloadString returns the string representation of the portion of a
+// resource's RequestedFloor not requested by DaemonSet-owned Pods, formatting
+// the amount with the supplied function, along with its percentage of the
+// resource's allocatable amount.
+func workloadString(
+	amounts types.ResourceAmounts,
+	fmtFn func(float64) string,
+) string {
+	workload := amounts.RequestedFloor - amounts.DaemonSetRequestedFloor
+	pct := (workload / amounts.Allocatable) * 100
+	return fmt.Sprintf("%s (%.2f%%)", fmtFn(workload), pct)
+}
+
+// usedString returns the used amount of a resource, formatted with the
+// supplied function, and its percentage of the allocatable amount, or "-"
+// if the used amount is unknown
+func usedString(amounts types.ResourceAmounts, format func(float64) string) (string, float64) {
+	if amounts.Used == -1 {
+		return "-", 0
 	}
-	return colors
+	pct := (amounts.Used / amounts.Allocatable) * 100
+	return fmt.Sprintf("%s (%.2f%%)", format(amounts.Used), pct), pct
+}
+
+// wholeNumberString returns a string representation of an amount rounded to
+// a whole number
+func wholeNumberString(v float64) string {
+	return fmt.Sprintf("%.0f", v)
 }
@@ -0,0 +1,67 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+// captureStdout returns what the supplied function writes to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return string(out)
+}
+
+func TestShowExclusiveNUMACellSummary(t *testing.T) {
+	nodes := []*types.Node{
+		{
+			Name: "worker-0",
+			NUMACells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU: types.ResourceAmounts{Allocatable: 4, ExclusiveRequestedFloor: 2},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU: types.ResourceAmounts{Allocatable: 4, ExclusiveRequestedFloor: -1},
+					},
+				},
+			},
+		},
+	}
+	out := captureStdout(t, func() {
+		showExclusiveNUMACellSummary(nodes)
+	})
+	for _, exp := range []string{"worker-0", "EXCLUSIVE", "SHARED POOL", "2 (50.00%)"} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected output to contain %q but got:\n%s", exp, out)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/fit"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+// pendingCmd represents the pending command
+var pendingCmd = &cobra.Command{
+	Use:   "pending",
+	Short: "Show unscheduled pods and why they don't fit",
+	RunE:  showPendingPodSummary,
+}
+
+func init() {
+	rootCmd.AddCommand(pendingCmd)
+}
+
+func showPendingPodSummary(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	// The kubelet configuration determines whether a Pod must fit in a
+	// single NUMA cell
+	nodes, err := knode.Get(ctx, conn, &knode.NodeGetOptions{KubeletConfig: true})
+	if err != nil {
+		return err
+	}
+	printWarnings(kubeletConfigWarnings(nodes))
+	pods, err := kpod.Get(ctx, conn, &kpod.PodGetOptions{})
+	if err != nil {
+		return err
+	}
+
+	pending := []*types.Pod{}
+	demand := types.ResourceRequests{}
+	for _, p := range pods {
+		if p.Node != "" || p.Phase != "Pending" {
+			continue
+		}
+		pending = append(pending, p)
+		demand.Add(p.ResourceRequests)
+	}
+
+	switch outputFormat {
+	case outputFormatHuman:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"NAMESPACE", "POD", "CPU", "MEMORY", "REASON"})
+		table.SetColumnAlignment([]int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_LEFT,
+		})
+		table.SetAutoWrapText(false)
+		table.SetRowLine(true)
+		for _, p := range pending {
+			table.Append([]string{
+				p.Namespace,
+				p.Name,
+				cpuRequestString(p.ResourceRequests.CPU.Floor),
+				memRequestString(p.ResourceRequests.Memory.Floor),
+				pendingReason(p, nodes),
+			})
+		}
+		table.SetFooter([]string{
+			"Totals",
+			fmt.Sprintf("%d", len(pending)),
+			cpuRequestString(demand.CPU.Floor),
+			unit.BytesToSizeString(demand.Memory.Floor),
+			"",
+		})
+		table.Render()
+	}
+	return nil
+}
+
+// pendingReason returns a summary of why the supplied Pod does not fit on
+// any of the supplied Nodes, in the style of the scheduler's
+// FailedScheduling events, e.g. "0/3 nodes are available: 2 Insufficient
+// cpu, 1 had untolerated taint {dedicated: gpu}".
+func pendingReason(p *types.Pod, nodes []*types.Node) string {
+	fitCount := 0
+	reasonCounts := map[string]int{}
+	for _, res := range fit.CheckNodes(p, nodes) {
+		if res.Fits {
+			fitCount++
+			continue
+		}
+		for _, r := range res.Reasons {
+			reasonCounts[r]++
+		}
+	}
+	if fitCount > 0 {
+		return fmt.Sprintf(
+			"fits on %d/%d nodes; waiting for the scheduler",
+			fitCount, len(nodes),
+		)
+	}
+	reasons := make([]string, 0, len(reasonCounts))
+	for r, count := range reasonCounts {
+		reasons = append(reasons, fmt.Sprintf("%d %s", count, r))
+	}
+	sort.Strings(reasons)
+	return fmt.Sprintf(
+		"0/%d nodes are available: %s",
+		len(nodes), strings.Join(reasons, ", "),
+	)
+}
@@ -10,15 +10,71 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 
 	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/cpuset"
 	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
 	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	klimitrange "github.com/jaypipes/kwiz/pkg/kube/limitrange"
 	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/prometheus"
+	"github.com/jaypipes/kwiz/pkg/types"
 	"github.com/jaypipes/kwiz/pkg/unit"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+const (
+	showAdjustedDesc = `If true, applies the defaults of namespace LimitRanges
+to containers with no requests or limits and shows the adjusted requests and
+limits next to the declared ones.`
+	podManifestDesc = `Path to a YAML or JSON manifest of Pods or workloads
+with Pod templates (e.g. Deployments) to show instead of the Pods in the
+cluster. LimitRange defaults are always applied to manifests. Cannot be
+combined with --namespace, --all-namespaces, --selector, --field-selector or
+--node.`
+	showContainersDesc = `If true, shows the requests, limits and QoS class
+compatibility of every init, sidecar, app and ephemeral container instead of
+one row per Pod.`
+	podNamespaceDesc     = "If present, only show Pods in this namespace."
+	podAllNamespacesDesc = `If true, show Pods in all namespaces, even if
+--namespace is specified.`
+	podFieldSelectorDesc = `Selector (field query) to filter on, supports '=',
+'==', and '!='.(e.g. --field-selector key1=value1,key2=value2).`
+	podNodeDesc   = "If present, only show Pods on this Node."
+	podSortByDesc = `Sort Pods by one of: namespace, name, cpu-floor,
+cpu-ceil, memory-floor, memory-ceil. Resource amounts are sorted largest
+first, with unbounded ceilings largest of all.`
+	podTopDesc        = "If greater than 0, only show this many Pods (after sorting)."
+	podShowActualDesc = `If true, shows the actual CPU and memory used by each
+Pod, as published by the kwiz agent or, if --prometheus-url is specified,
+the latest usage and 95th percentile of usage over --window.`
+	podUsageWindowDesc = `Window of usage history to calculate percentiles
+over when --prometheus-url is specified.`
+	showPinnedDesc = `If true, shows the CPUs, per-NUMA cell memory and devices
+the kubelet has assigned to each container, as published by the kwiz agent.`
+)
+
+var (
+	podGetOpts       = kpod.PodGetOptions{}
+	podAllNamespaces bool
+	podSortBy        string
+	podTop           int
+	showAdjusted     bool
+	podManifest      string
+	showContainers   bool
+	showPinned       bool
+	podShowActual    bool
+	podPrometheusURL string
+	podUsageWindow   time.Duration
+	podAgentNS       string
 )
 
 // podCmd represents the node command
@@ -30,12 +86,48 @@ var podCmd = &cobra.Command{
 }
 
 func init() {
+	podCmd.Flags().BoolVar(&showAdjusted, "show-adjusted", false, showAdjustedDesc)
+	podCmd.Flags().StringVar(&podManifest, "manifest", "", podManifestDesc)
+	podCmd.Flags().BoolVar(&showContainers, "containers", false, showContainersDesc)
+	podCmd.Flags().StringVarP(&podGetOpts.Namespace, "namespace", "n", "", podNamespaceDesc)
+	podCmd.Flags().BoolVarP(&podAllNamespaces, "all-namespaces", "A", false, podAllNamespacesDesc)
+	cmdutil.AddLabelSelectorFlagVar(podCmd, &podGetOpts.LabelSelector)
+	podCmd.Flags().StringVar(&podGetOpts.FieldSelector, "field-selector", "", podFieldSelectorDesc)
+	podCmd.Flags().StringVar(&podGetOpts.Node, "node", "", podNodeDesc)
+	podCmd.Flags().StringVar(&podSortBy, "sort-by", "", podSortByDesc)
+	podCmd.Flags().IntVar(&podTop, "top", 0, podTopDesc)
+	podCmd.Flags().BoolVarP(&podShowActual, "show-actual", "a", false, podShowActualDesc)
+	podCmd.Flags().StringVar(&podPrometheusURL, "prometheus-url", "", usagePrometheusURLDesc)
+	podCmd.Flags().DurationVar(&podUsageWindow, "window", time.Hour, podUsageWindowDesc)
+	podCmd.Flags().BoolVar(&showPinned, "show-pinned", false, showPinnedDesc)
+	podCmd.Flags().StringVar(&podAgentNS, "agent-namespace", kagent.DefaultNamespace, agentReportNamespaceDesc)
 	rootCmd.AddCommand(podCmd)
 }
 
 func showPodResourceSummary(cmd *cobra.Command, args []string) error {
 	var cancel context.CancelFunc
 
+	if podSortBy != "" && !slices.Contains(kpod.SortKeys, podSortBy) {
+		return fmt.Errorf(
+			"invalid sort key %q. choices are: %s",
+			podSortBy, strings.Join(kpod.SortKeys, ", "),
+		)
+	}
+	if podManifest != "" {
+		// The filters select Pods in the cluster and are not applied to the
+		// Pods in a manifest
+		for _, name := range []string{
+			"namespace", "all-namespaces", "selector", "field-selector", "node",
+		} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s cannot be combined with --manifest", name)
+			}
+		}
+	}
+	if podAllNamespaces {
+		podGetOpts.Namespace = ""
+	}
+
 	ctx := kwcontext.New()
 	cfg, err := kube.Config(ctx)
 	if err != nil {
@@ -50,9 +142,50 @@ func showPodResourceSummary(cmd *cobra.Command, args []string) error {
 	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
-	pods, err := kpod.Get(ctx, conn)
-	if err != nil {
-		return err
+	var pods []*types.Pod
+	if podManifest != "" {
+		manifest, err := os.ReadFile(podManifest)
+		if err != nil {
+			return err
+		}
+		ranges, err := klimitrange.Get(ctx, conn)
+		if err != nil {
+			return err
+		}
+		pods, err = kpod.FromManifest(manifest, klimitrange.ByNamespace(ranges))
+		if err != nil {
+			return err
+		}
+		showAdjusted = true
+	} else {
+		podGetOpts.ApplyLimitRanges = showAdjusted
+		pods, err = kpod.Get(ctx, conn, &podGetOpts)
+		if err != nil {
+			return err
+		}
+	}
+	if showPinned || (podShowActual && podPrometheusURL == "") {
+		reports, err := getAgentReports(ctx, conn, podAgentNS)
+		if err != nil {
+			return err
+		}
+		kagent.AttachAssignments(pods, reports)
+		kagent.AttachUsage(pods, reports)
+	}
+	if podShowActual && podPrometheusURL != "" {
+		source := prometheus.NewSource(prometheus.NewClient(podPrometheusURL))
+		promCtx, promCancel := prometheusContext(ctx)
+		defer promCancel()
+		scope := usage.Scope{Namespace: podGetOpts.Namespace}
+		samples, err := source.ContainerUsage(promCtx, podUsageWindow, scope)
+		if err != nil {
+			return err
+		}
+		usage.AttachToPods(pods, samples)
+	}
+	kpod.Sort(pods, podSortBy)
+	if podTop > 0 && len(pods) > podTop {
+		pods = pods[:podTop]
 	}
 	colors := []tablewriter.Colors{
 		tablewriter.Colors{},
@@ -63,10 +196,30 @@ func showPodResourceSummary(cmd *cobra.Command, args []string) error {
 	}
 
 	switch outputFormat {
+	case outputFormatJSON, outputFormatYAML:
+		return printStructured(pods)
 	case outputFormatHuman:
+		if showPinned {
+			showPinnedSummary(pods)
+			return nil
+		}
+		if showContainers {
+			showContainerResourceSummary(pods)
+			return nil
+		}
+		headers := []string{"NAMESPACE", "POD", "RESOURCE", "Req", "Lim"}
+		if showAdjusted {
+			headers = append(headers, "Adj Req", "Adj Lim")
+		}
+		if podShowActual {
+			headers = append(headers, "Used")
+			if podPrometheusURL != "" {
+				headers = append(headers, "Used (p95)")
+			}
+		}
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
-		table.SetHeader([]string{"NAMESPACE", "POD", "RESOURCE", "Req", "Lim"})
+		table.SetHeader(headers)
 		table.SetColumnAlignment([]int{
 			tablewriter.ALIGN_LEFT,
 			tablewriter.ALIGN_LEFT,
@@ -74,46 +227,71 @@ func showPodResourceSummary(cmd *cobra.Command, args []string) error {
 			tablewriter.ALIGN_RIGHT,
 			tablewriter.ALIGN_RIGHT,
 			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
 		})
 		table.SetRowLine(true)
 		for _, pod := range pods {
 			cpu := pod.ResourceRequests.CPU
-			cpuFloor := fmt.Sprintf("%.2f", cpu.Floor)
-			if cpu.Floor == float64(-1) {
-				cpuFloor = "-"
-			}
-			cpuCeiling := fmt.Sprintf("%.2f", cpu.Ceiling)
-			if cpu.Ceiling == float64(-1) {
-				cpuCeiling = "-"
-			}
 			data := []string{
 				pod.Namespace,
 				pod.Name,
 				"CPU",
-				cpuFloor,
-				cpuCeiling,
+				cpuRequestString(cpu.Floor),
+				cpuRequestString(cpu.Ceiling),
+			}
+			if showAdjusted {
+				adjCPU := pod.AdjustedResourceRequests.CPU
+				data = append(
+					data,
+					cpuRequestString(adjCPU.Floor),
+					cpuRequestString(adjCPU.Ceiling),
+				)
+			}
+			if podShowActual {
+				used, p95 := float64(-1), float64(-1)
+				if pod.Usage != nil {
+					used = pod.Usage.Resources.CPU.Used
+					if pod.Usage.CPUStats != nil {
+						p95 = pod.Usage.CPUStats.P95
+					}
+				}
+				data = append(data, cpuRequestString(used))
+				if podPrometheusURL != "" {
+					data = append(data, cpuRequestString(p95))
+				}
 			}
 			table.Rich(data, colors)
 
 			mem := pod.ResourceRequests.Memory
-			var memFloor string
-			if mem.Floor == float64(-1) {
-				memFloor = "-"
-			} else {
-				memFloor = unit.BytesToSizeString(mem.Floor)
-			}
-			var memCeiling string
-			if mem.Ceiling == float64(-1) {
-				memCeiling = "-"
-			} else {
-				memCeiling = unit.BytesToSizeString(mem.Ceiling)
-			}
 			data = []string{
 				pod.Namespace,
 				pod.Name,
 				"Memory",
-				memFloor,
-				memCeiling,
+				memRequestString(mem.Floor),
+				memRequestString(mem.Ceiling),
+			}
+			if showAdjusted {
+				adjMem := pod.AdjustedResourceRequests.Memory
+				data = append(
+					data,
+					memRequestString(adjMem.Floor),
+					memRequestString(adjMem.Ceiling),
+				)
+			}
+			if podShowActual {
+				used, p95 := float64(-1), float64(-1)
+				if pod.Usage != nil {
+					used = pod.Usage.Resources.Memory.Used
+					if pod.Usage.MemoryStats != nil {
+						p95 = pod.Usage.MemoryStats.P95
+					}
+				}
+				data = append(data, memRequestString(used))
+				if podPrometheusURL != "" {
+					data = append(data, memRequestString(p95))
+				}
 			}
 			table.Rich(data, colors)
 		}
@@ -121,3 +299,129 @@ func showPodResourceSummary(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// showContainerResourceSummary prints a table of the requests and limits of
+// every container in the supplied Pods
+func showContainerResourceSummary(pods []*types.Pod) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1, 2})
+	table.SetHeader([]string{
+		"NAMESPACE", "POD", "CONTAINER", "TYPE", "QOS", "RESOURCE", "Req", "Lim",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	table.SetRowLine(true)
+	for _, pod := range pods {
+		for _, ctr := range pod.Containers {
+			cpu := ctr.ResourceRequests.CPU
+			table.Append([]string{
+				pod.Namespace,
+				pod.Name,
+				ctr.Name,
+				string(ctr.Type),
+				string(ctr.QOSClass),
+				"CPU",
+				cpuRequestString(cpu.Floor),
+				cpuRequestString(cpu.Ceiling),
+			})
+			mem := ctr.ResourceRequests.Memory
+			table.Append([]string{
+				pod.Namespace,
+				pod.Name,
+				ctr.Name,
+				string(ctr.Type),
+				string(ctr.QOSClass),
+				"Memory",
+				memRequestString(mem.Floor),
+				memRequestString(mem.Ceiling),
+			})
+		}
+	}
+	table.Render()
+}
+
+// showPinnedSummary prints a table of the CPUs, memory and devices assigned
+// to every container of the supplied Pods
+func showPinnedSummary(pods []*types.Pod) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
+	table.SetHeader([]string{
+		"NAMESPACE", "POD", "CONTAINER", "CPUS", "MEMORY", "DEVICES",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+	})
+	table.SetAutoWrapText(false)
+	table.SetRowLine(true)
+	for _, pod := range pods {
+		for _, a := range pod.Assignments {
+			table.Append([]string{
+				pod.Namespace,
+				pod.Name,
+				a.ContainerName,
+				valueOrDash(cpuset.String(a.CPUs)),
+				valueOrDash(memoryBlocksString(a.Memory)),
+				valueOrDash(deviceAssignmentsString(a.Devices)),
+			})
+		}
+	}
+	table.Render()
+}
+
+// memoryBlocksString returns a string representation of the supplied memory
+// blocks, e.g. "memory: 4Gi (cells 0), hugepages-1Gi: 2Gi (cells 0,1)"
+func memoryBlocksString(blocks []types.MemoryBlock) string {
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, fmt.Sprintf(
+			"%s: %s (cells %s)",
+			b.Type, unit.BytesToSizeString(b.Size), cpuset.String(b.NUMACells),
+		))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// deviceAssignmentsString returns a string representation of the supplied
+// device assignments, e.g. "nvidia.com/gpu: gpu-0,gpu-1 (cells 0)"
+func deviceAssignmentsString(devs []types.DeviceAssignment) string {
+	parts := make([]string, 0, len(devs))
+	for _, d := range devs {
+		part := fmt.Sprintf("%s: %s", d.ResourceName, strings.Join(d.DeviceIDs, ","))
+		if len(d.NUMACells) > 0 {
+			part += fmt.Sprintf(" (cells %s)", cpuset.String(d.NUMACells))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// cpuRequestString returns a string representation of a CPU request amount,
+// with -1 (no amount) shown as "-"
+func cpuRequestString(v float64) string {
+	if v == float64(-1) {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// memRequestString returns a string representation of a memory request
+// amount, with -1 (no amount) shown as "-"
+func memRequestString(v float64) string {
+	if v == float64(-1) {
+		return "-"
+	}
+	return unit.BytesToSizeString(v)
+}
@@ -0,0 +1,292 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	kmetrics "github.com/jaypipes/kwiz/pkg/kube/metrics"
+	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	kworkload "github.com/jaypipes/kwiz/pkg/kube/workload"
+	"github.com/jaypipes/kwiz/pkg/prometheus"
+	"github.com/jaypipes/kwiz/pkg/rightsize"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+const (
+	rightsizeNamespaceDesc = "If present, only show workloads in this namespace."
+	rightsizeWindowDesc    = `Window of usage history to base recommendations on
+when --prometheus-url is specified. metrics-server only has current usage.`
+	usagePrometheusURLDesc = `Base URL of a Prometheus-compatible HTTP API to query
+for usage history, e.g. http://prometheus.monitoring:9090. If empty, current
+usage from metrics-server is used instead.`
+	cpuRequestPercentileDesc    = "Percentile of CPU usage to recommend as the CPU request."
+	cpuLimitPercentileDesc      = "Percentile of CPU usage to recommend as the CPU limit."
+	memoryRequestPercentileDesc = "Percentile of memory usage to recommend as the memory request."
+	memoryLimitPercentileDesc   = "Percentile of memory usage to recommend as the memory limit."
+	rightsizeMarginDesc         = `Safety margin added to each recommended amount, as
+a fraction of the amount (e.g. 0.15 for 15%).`
+	showPatchesDesc = `If true, prints a kubectl patch command applying the
+recommendations to each workload.`
+	defaultRightsizeWindow = 24 * time.Hour
+)
+
+var (
+	rightsizeNamespace     string
+	rightsizeAllNamespaces bool
+	rightsizeWindow        time.Duration
+	rightsizeOpts          = rightsize.DefaultOptions
+	rightsizeAgentNS       string
+	rightsizePrometheusURL string
+	showPatches            bool
+)
+
+// rightsizeCmd represents the rightsize command
+var rightsizeCmd = &cobra.Command{
+	Use:   "rightsize",
+	Short: "Recommend container requests and limits from observed usage",
+	Long: `Recommend container requests and limits from observed usage.
+
+Requests and limits are recommended for every app container of each workload
+from percentiles of the container's usage, pooled across the workload's
+replicas, plus a safety margin. A limit is only recommended for containers
+that already have one. The summary shows the requests that would be freed
+across the cluster and the number of Nodes on which a new replica fits in a
+single NUMA cell, before and after applying the recommendations.`,
+	RunE: showRightsizeSummary,
+}
+
+func init() {
+	rightsizeCmd.Flags().StringVarP(&rightsizeNamespace, "namespace", "n", "", rightsizeNamespaceDesc)
+	rightsizeCmd.Flags().BoolVarP(&rightsizeAllNamespaces, "all-namespaces", "A", false, podAllNamespacesDesc)
+	rightsizeCmd.Flags().StringVar(&rightsizePrometheusURL, "prometheus-url", "", usagePrometheusURLDesc)
+	rightsizeCmd.Flags().DurationVar(&rightsizeWindow, "window", defaultRightsizeWindow, rightsizeWindowDesc)
+	rightsizeCmd.Flags().Float64Var(&rightsizeOpts.CPURequestPercentile, "cpu-request-percentile", rightsize.DefaultOptions.CPURequestPercentile, cpuRequestPercentileDesc)
+	rightsizeCmd.Flags().Float64Var(&rightsizeOpts.CPULimitPercentile, "cpu-limit-percentile", rightsize.DefaultOptions.CPULimitPercentile, cpuLimitPercentileDesc)
+	rightsizeCmd.Flags().Float64Var(&rightsizeOpts.MemoryRequestPercentile, "memory-request-percentile", rightsize.DefaultOptions.MemoryRequestPercentile, memoryRequestPercentileDesc)
+	rightsizeCmd.Flags().Float64Var(&rightsizeOpts.MemoryLimitPercentile, "memory-limit-percentile", rightsize.DefaultOptions.MemoryLimitPercentile, memoryLimitPercentileDesc)
+	rightsizeCmd.Flags().Float64Var(&rightsizeOpts.Margin, "margin", rightsize.DefaultOptions.Margin, rightsizeMarginDesc)
+	rightsizeCmd.Flags().StringVar(&rightsizeAgentNS, "agent-namespace", kagent.DefaultNamespace, agentReportNamespaceDesc)
+	rightsizeCmd.Flags().BoolVar(&showPatches, "show-patches", false, showPatchesDesc)
+	rootCmd.AddCommand(rightsizeCmd)
+}
+
+func showRightsizeSummary(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+
+	for _, pct := range []float64{
+		rightsizeOpts.CPURequestPercentile, rightsizeOpts.CPULimitPercentile,
+		rightsizeOpts.MemoryRequestPercentile, rightsizeOpts.MemoryLimitPercentile,
+	} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("invalid percentile %.2f. must be between 0 and 100", pct)
+		}
+	}
+	if rightsizeAllNamespaces {
+		rightsizeNamespace = ""
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	all, err := kworkload.Get(ctx, conn)
+	if err != nil {
+		return err
+	}
+	workloads := []*types.Workload{}
+	for _, w := range all {
+		if rightsizeNamespace == "" || w.Namespace == rightsizeNamespace {
+			workloads = append(workloads, w)
+		}
+	}
+	promCtx, promCancel := prometheusContext(ctx)
+	defer promCancel()
+	samples, err := usageSource(conn, rightsizePrometheusURL).ContainerUsage(
+		promCtx, rightsizeWindow, usage.Scope{Namespace: rightsizeNamespace},
+	)
+	if err != nil {
+		return err
+	}
+	reports, err := getAgentReports(ctx, conn, rightsizeAgentNS)
+	if err != nil {
+		return err
+	}
+	nodes, err := knode.Get(ctx, conn, &knode.NodeGetOptions{AgentReports: reports})
+	if err != nil {
+		return err
+	}
+	recs := rightsize.Recommend(workloads, samples, rightsizeOpts)
+	rightsize.SetNUMAFit(recs, nodes)
+
+	switch outputFormat {
+	case outputFormatHuman:
+		showContainerRecommendations(recs)
+		showRightsizeTotals(recs)
+		if showPatches {
+			showRightsizePatches(recs)
+		}
+	}
+	return nil
+}
+
+// usageSource returns a usage.Source querying the Prometheus-compatible API
+// at the supplied URL, or metrics-server if the URL is empty
+func usageSource(conn *kconnect.Connection, prometheusURL string) usage.Source {
+	if prometheusURL != "" {
+		return prometheus.NewSource(prometheus.NewClient(prometheusURL))
+	}
+	return kmetrics.NewSource(conn)
+}
+
+// showContainerRecommendations prints a table of the current and
+// recommended requests and limits of every container in the supplied
+// Recommendations
+func showContainerRecommendations(recs []rightsize.Recommendation) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoMergeCellsByColumnIndex([]int{0, 1, 2, 6})
+	table.SetHeader([]string{
+		"NAMESPACE", "WORKLOAD", "CONTAINER", "RESOURCE",
+		"CURRENT (REQ/LIM)", "RECOMMENDED (REQ/LIM)", "SAMPLES",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	table.SetRowLine(true)
+	for _, rec := range recs {
+		w := rec.Workload
+		for _, cr := range rec.Containers {
+			for _, r := range []struct {
+				name  string
+				cur   types.ResourceRequest
+				rec   types.ResourceRequest
+				fmtFn func(float64) string
+			}{
+				{"CPU", cr.Current.CPU, cr.Recommended.CPU, cpuRequestString},
+				{"Memory", cr.Current.Memory, cr.Recommended.Memory, memRequestString},
+			} {
+				table.Append([]string{
+					w.Namespace,
+					w.Kind + "/" + w.Name,
+					cr.Name,
+					r.name,
+					r.fmtFn(r.cur.Floor) + " / " + r.fmtFn(r.cur.Ceiling),
+					r.fmtFn(r.rec.Floor) + " / " + r.fmtFn(r.rec.Ceiling),
+					fmt.Sprintf("%d", cr.Samples),
+				})
+			}
+		}
+	}
+	table.Render()
+}
+
+// showRightsizeTotals prints a table of the requests each workload would
+// free and its NUMA fit before and after applying the supplied
+// Recommendations, with the cluster totals in the footer
+func showRightsizeTotals(recs []rightsize.Recommendation) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{
+		"NAMESPACE", "WORKLOAD", "REPLICAS", "CPU FREED", "MEMORY FREED", "NUMA FIT",
+	})
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_LEFT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT,
+	})
+	cpuFreed := float64(0)
+	memFreed := float64(0)
+	for _, rec := range recs {
+		w := rec.Workload
+		cpuFreed += rec.CPUFreed
+		memFreed += rec.MemoryFreed
+		table.Append([]string{
+			w.Namespace,
+			w.Kind + "/" + w.Name,
+			fmt.Sprintf("%d", len(w.Pods)),
+			fmt.Sprintf("%.2f", rec.CPUFreed),
+			signedBytesString(rec.MemoryFreed),
+			numaFitString(rec.NUMAFitCurrent, rec.NUMAFitRecommended),
+		})
+	}
+	table.SetFooter([]string{
+		"Totals",
+		fmt.Sprintf("%d", len(recs)),
+		"",
+		fmt.Sprintf("%.2f", cpuFreed),
+		signedBytesString(memFreed),
+		"",
+	})
+	table.Render()
+}
+
+// showRightsizePatches prints a kubectl patch command for each of the
+// supplied Recommendations that can be applied
+func showRightsizePatches(recs []rightsize.Recommendation) {
+	for _, rec := range recs {
+		patch, ok := rightsize.Patch(rec)
+		if !ok {
+			continue
+		}
+		w := rec.Workload
+		fmt.Printf(
+			"kubectl patch %s %s -n %s --type strategic -p '%s'\n",
+			strings.ToLower(w.Kind), w.Name, w.Namespace, patch,
+		)
+	}
+}
+
+// signedBytesString returns a string representation of a memory amount
+// that may be negative
+func signedBytesString(b float64) string {
+	if b < 0 {
+		return "-" + unit.BytesToSizeString(math.Abs(b))
+	}
+	return unit.BytesToSizeString(b)
+}
+
+// numaFitString returns a string representation of the number of Nodes a
+// replica fits in a single NUMA cell of before and after rightsizing, e.g.
+// "2 -> 5", or "-" if unknown
+func numaFitString(current int, recommended int) string {
+	if current == -1 {
+		return "-"
+	}
+	return fmt.Sprintf("%d -> %d", current, recommended)
+}
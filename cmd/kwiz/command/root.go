@@ -7,12 +7,17 @@
 package command
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/jaypipes/kwiz/pkg/types"
 )
 
 const (
@@ -22,6 +27,9 @@ const (
 	outputFormatYAML  = "yaml"
 	usageOutputFormat = `Output format.
 Choices are 'json','yaml', and 'human'.`
+	// prometheusTimeout is how long usage history queries may take. Range
+	// queries over long windows take much longer than Kubernetes API calls.
+	prometheusTimeout = time.Minute
 )
 
 var (
@@ -95,6 +103,53 @@ func validateRootCommand(rootCmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printStructured prints the supplied value to stdout in the JSON or YAML
+// output format. The serve command's HTTP API returns the same JSON.
+func printStructured(v interface{}) error {
+	var out []byte
+	var err error
+	switch outputFormat {
+	case outputFormatJSON:
+		out, err = json.MarshalIndent(v, "", "  ")
+		out = append(out, '\n')
+	case outputFormatYAML:
+		out, err = yaml.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// prometheusContext returns a copy of the supplied context, without its
+// deadline, that times out after prometheusTimeout
+func prometheusContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), prometheusTimeout)
+}
+
+// printWarnings prints each of the supplied warnings to stderr
+func printWarnings(warnings []string) {
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+	}
+}
+
+// kubeletConfigWarnings returns a warning for each of the supplied Nodes
+// whose kubelet configuration was requested but could not be read
+func kubeletConfigWarnings(nodes []*types.Node) []string {
+	res := []string{}
+	for _, n := range nodes {
+		if n.KubeletConfigError != "" {
+			res = append(res, fmt.Sprintf(
+				"ignoring unreadable kubelet configuration of node %s: %s",
+				n.Name, n.KubeletConfigError,
+			))
+		}
+	}
+	return res
+}
+
 func init() {
 	rootCmd.PersistentFlags().BoolVar(
 		&debug, "debug", false, "Enable or disable debug mode",
@@ -0,0 +1,206 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/jaypipes/kwiz/pkg/api"
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/exporter"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+)
+
+const (
+	serveMetricsAddrDesc = `Address to serve Prometheus metrics on at /metrics,
+e.g. ":9090". Empty to not serve metrics.`
+	serveAPIAddrDesc = `Address to serve the JSON API on at /v1/, e.g. ":8080".
+May be the same as --metrics-addr. The API is not served unless this is
+specified.`
+	serveRefreshIntervalDesc = `How often to refresh Node, Pod and NUMA cell data
+from the cluster. Reading the agent reports, the Nodes and the Pods may each
+take up to a third of the interval.`
+	serveClusterDesc    = "Value of the cluster label of every metric."
+	serveGroupLabelDesc = `Node label key to add to every metric as a
+"label_<key>" metric label, with non-alphanumeric characters of the key
+replaced by underscores. May be repeated.`
+	defaultServeMetricsAddr     = ":9090"
+	defaultServeRefreshInterval = time.Minute
+)
+
+var (
+	// The kubelet configuration determines whether /v1/fit checks NUMA
+	// cell alignment
+	serveNodeGetOpts     = knode.NodeGetOptions{KubeletConfig: true}
+	serveAgentNS         string
+	serveMetricsAddr     string
+	serveAPIAddr         string
+	serveRefreshInterval time.Duration
+	serveExporterOpts    = exporter.Options{}
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve node, pod and NUMA cell data as Prometheus metrics and a JSON API",
+	Long: `Serve node, pod and NUMA cell data as Prometheus metrics and a JSON API.
+
+Refreshes Node, Pod and NUMA cell data from the cluster on an interval and
+serves the capacity, allocatable, reserved, request floor, request ceiling
+and used amount of each resource of every Node and NUMA cell as gauges, the
+same numbers the node command shows. CPU is in cores and memory in bytes.
+Unknown amounts, such as the usage of a Node no kwiz agent reports on, are
+omitted.
+
+If --api-addr is specified, also serves the refreshed data as a read-only
+JSON API, with the same schema as the node and pod commands' JSON output:
+
+  GET /v1/nodes?selector=<label selector>
+  GET /v1/nodes/<name>
+  GET /v1/pods?namespace=<namespace>&selector=<label selector>
+      &field-selector=<field selector>&node=<node>&sort-by=<key>&top=<n>
+  GET /v1/fit?cpu=<quantity>&memory=<quantity>&selector=<label selector>
+  GET /v1/fit?namespace=<namespace>&pod=<name>&selector=<label selector>
+
+/v1/fit shows whether a Pod with the supplied requests, or an existing Pod,
+fits on each Node and why not. Responses carry an ETag and honor
+If-None-Match.`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveMetricsAddr, "metrics-addr", defaultServeMetricsAddr, serveMetricsAddrDesc)
+	serveCmd.Flags().StringVar(&serveAPIAddr, "api-addr", "", serveAPIAddrDesc)
+	serveCmd.Flags().DurationVar(&serveRefreshInterval, "refresh-interval", defaultServeRefreshInterval, serveRefreshIntervalDesc)
+	serveCmd.Flags().StringVar(&serveExporterOpts.Cluster, "cluster", "", serveClusterDesc)
+	serveCmd.Flags().StringSliceVar(&serveExporterOpts.LabelKeys, "group-label", []string{defaultNodePoolLabel}, serveGroupLabelDesc)
+	serveCmd.Flags().StringVar(&serveAgentNS, "agent-namespace", kagent.DefaultNamespace, agentReportNamespaceDesc)
+	cmdutil.AddLabelSelectorFlagVar(serveCmd, &serveNodeGetOpts.LabelSelector)
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveMetricsAddr == "" && serveAPIAddr == "" {
+		return fmt.Errorf("at least one of --metrics-addr or --api-addr is required")
+	}
+	if err := serveExporterOpts.Validate(); err != nil {
+		return err
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	exp := exporter.New(serveExporterOpts)
+	srv := api.NewServer()
+	go func() {
+		ticker := time.NewTicker(serveRefreshInterval)
+		defer ticker.Stop()
+		for {
+			// A failure to refresh should not stop the server, which keeps
+			// serving the data of the last successful refresh
+			if err := refreshServe(ctx, conn, exp, srv); err != nil {
+				exp.RecordError()
+				fmt.Fprintf(os.Stderr, "failed to refresh: %s\n", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Each address gets its own listener. The metrics and the API share one
+	// if their addresses are the same.
+	muxes := map[string]*http.ServeMux{}
+	for _, h := range []struct {
+		addr    string
+		pattern string
+		handler http.Handler
+	}{
+		{serveMetricsAddr, "/metrics", exp},
+		{serveAPIAddr, "/v1/", srv},
+	} {
+		if h.addr == "" {
+			continue
+		}
+		mux, ok := muxes[h.addr]
+		if !ok {
+			mux = http.NewServeMux()
+			muxes[h.addr] = mux
+		}
+		mux.Handle(h.pattern, h.handler)
+	}
+	errs := make(chan error, len(muxes))
+	for addr, mux := range muxes {
+		go func(addr string, mux *http.ServeMux) {
+			errs <- http.ListenAndServe(addr, mux)
+		}(addr, mux)
+	}
+	return <-errs
+}
+
+// refreshServe reads the Nodes and Pods in the cluster, along with the NUMA
+// topology, CPU and memory assignments and usage reported by the kwiz agent,
+// and updates the served data. The metrics are updated as soon as the Nodes
+// are read, so a failure to read the Pods does not leave them stale.
+//
+// Reading the agent reports, the Nodes and the Pods each get a third of the
+// refresh interval, so a slow phase cannot use up the time of the next one.
+func refreshServe(
+	ctx context.Context,
+	conn *kconnect.Connection,
+	exp *exporter.Exporter,
+	srv *api.Server,
+) error {
+	phaseTimeout := serveRefreshInterval / 3
+	// The reports are read once, for both the Nodes and the Pods
+	opts := serveNodeGetOpts
+	phaseCtx, cancel := context.WithTimeout(ctx, phaseTimeout)
+	reports, err := getAgentReports(phaseCtx, conn, serveAgentNS)
+	cancel()
+	if err != nil {
+		return err
+	}
+	opts.AgentReports = reports
+	phaseCtx, cancel = context.WithTimeout(ctx, phaseTimeout)
+	nodes, err := knode.Get(phaseCtx, conn, &opts)
+	cancel()
+	if err != nil {
+		return err
+	}
+	printWarnings(kubeletConfigWarnings(nodes))
+	if err := exp.Update(nodes); err != nil {
+		return err
+	}
+	phaseCtx, cancel = context.WithTimeout(ctx, phaseTimeout)
+	pods, err := kpod.Get(phaseCtx, conn, &kpod.PodGetOptions{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	kagent.AttachAssignments(pods, opts.AgentReports)
+	kagent.AttachUsage(pods, opts.AgentReports)
+	srv.Update(nodes, pods)
+	return nil
+}
@@ -0,0 +1,183 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/prometheus"
+	"github.com/jaypipes/kwiz/pkg/throttle"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	prometheusURLDesc = `Base URL of a Prometheus-compatible HTTP API to query
+for usage, e.g. http://prometheus.monitoring:9090. If empty, usage published
+by the kwiz agent is used instead.`
+	throttleWindowDesc = `Window to query throttling counters over when
+--prometheus-url is specified. Counters from the kwiz agent are always since
+each Pod started.`
+	defaultThrottleWindow = time.Hour
+)
+
+var (
+	throttleGetOpts       = kpod.PodGetOptions{}
+	throttleAllNamespaces bool
+	throttleAgentNS       string
+	throttlePrometheusURL string
+	throttleWindow        time.Duration
+	throttleTop           int
+)
+
+// throttleCmd represents the throttle command
+var throttleCmd = &cobra.Command{
+	Use:     "throttle",
+	Short:   "Show CPU throttling of pods with CPU limits",
+	Aliases: []string{"throttling"},
+	Long: `Show CPU throttling of pods with CPU limits.
+
+Pods are ranked by the number of CFS periods in which they used their whole
+CPU quota and were throttled. The suggested ceiling is a CPU limit under which
+the Pod would not have been throttled, assuming the default 100ms CFS period.`,
+	RunE: showThrottleSummary,
+}
+
+func init() {
+	throttleCmd.Flags().StringVarP(&throttleGetOpts.Namespace, "namespace", "n", "", podNamespaceDesc)
+	throttleCmd.Flags().BoolVarP(&throttleAllNamespaces, "all-namespaces", "A", false, podAllNamespacesDesc)
+	cmdutil.AddLabelSelectorFlagVar(throttleCmd, &throttleGetOpts.LabelSelector)
+	throttleCmd.Flags().StringVar(&throttleGetOpts.Node, "node", "", podNodeDesc)
+	throttleCmd.Flags().StringVar(&throttleAgentNS, "agent-namespace", kagent.DefaultNamespace, agentReportNamespaceDesc)
+	throttleCmd.Flags().StringVar(&throttlePrometheusURL, "prometheus-url", "", prometheusURLDesc)
+	throttleCmd.Flags().DurationVar(&throttleWindow, "window", defaultThrottleWindow, throttleWindowDesc)
+	throttleCmd.Flags().IntVar(&throttleTop, "top", 0, podTopDesc)
+	rootCmd.AddCommand(throttleCmd)
+}
+
+func showThrottleSummary(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+
+	if throttleAllNamespaces {
+		throttleGetOpts.Namespace = ""
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	pods, err := kpod.Get(ctx, conn, &throttleGetOpts)
+	if err != nil {
+		return err
+	}
+	if throttlePrometheusURL != "" {
+		client := prometheus.NewClient(throttlePrometheusURL)
+		promCtx, promCancel := prometheusContext(ctx)
+		defer promCancel()
+		throttling, err := prometheus.Throttling(promCtx, client, throttleWindow)
+		if err != nil {
+			return err
+		}
+		attachThrottling(pods, throttling)
+	} else {
+		reports, err := getAgentReports(ctx, conn, throttleAgentNS)
+		if err != nil {
+			return err
+		}
+		kagent.AttachUsage(pods, reports)
+	}
+	entries := throttle.Rank(pods)
+	if throttleTop > 0 && len(entries) > throttleTop {
+		entries = entries[:throttleTop]
+	}
+
+	switch outputFormat {
+	case outputFormatHuman:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{
+			"NAMESPACE", "POD", "CEILING", "PERIODS", "THROTTLED",
+			"THROTTLED TIME", "SUGGESTED CEILING",
+		})
+		table.SetColumnAlignment([]int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+		})
+		table.SetRowLine(true)
+		for _, e := range entries {
+			throttledTime := time.Duration(e.Throttling.ThrottledSeconds * float64(time.Second))
+			table.Rich([]string{
+				e.Pod.Namespace,
+				e.Pod.Name,
+				cpuRequestString(e.Ceiling),
+				fmt.Sprintf("%.0f", e.Throttling.Periods),
+				fmt.Sprintf(
+					"%.0f (%.2f%%)",
+					e.Throttling.ThrottledPeriods, e.ThrottledRatio*100,
+				),
+				throttledTime.Round(time.Second).String(),
+				cpuRequestString(e.SuggestedCeiling),
+			}, []tablewriter.Colors{
+				{}, {}, {}, {}, throttledColor(e.ThrottledRatio), {}, {},
+			})
+		}
+		table.Render()
+	}
+	return nil
+}
+
+// throttledColor returns the color to show a Pod's throttled ratio in. Being
+// throttled in even a small fraction of periods can add noticeable latency,
+// so the thresholds are far lower than those of colorByPct.
+func throttledColor(ratio float64) tablewriter.Colors {
+	if ratio > 0.25 {
+		return twColorRedNormal
+	} else if ratio > 0.05 {
+		return twColorYellowNormal
+	}
+	return twColorGreenNormal
+}
+
+// attachThrottling sets the throttling counters of each of the supplied Pods
+// from the supplied map, keyed by "<namespace>/<name>"
+func attachThrottling(pods []*types.Pod, throttling map[string]*types.CPUThrottling) {
+	for _, p := range pods {
+		t, ok := throttling[p.Namespace+"/"+p.Name]
+		if !ok {
+			continue
+		}
+		if p.Usage == nil {
+			p.Usage = &types.PodUsage{PodUID: p.UID}
+		}
+		p.Usage.Throttling = t
+	}
+}
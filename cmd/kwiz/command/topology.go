@@ -0,0 +1,228 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/fit"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kagent "github.com/jaypipes/kwiz/pkg/kube/agent"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	klimitrange "github.com/jaypipes/kwiz/pkg/kube/limitrange"
+	knode "github.com/jaypipes/kwiz/pkg/kube/node"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/topology"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	topologyManifestDesc = `Path to a YAML or JSON manifest of Pods or workloads
+with Pod templates (e.g. Deployments) whose admission to each Node to
+simulate.`
+	topologyPolicyDesc = `Topology Manager policy to simulate: none, best-effort,
+restricted or single-numa-node. All policies are simulated if empty.`
+	topologyScopeDesc = `Topology Manager scope to simulate: container or pod.
+All scopes are simulated if empty.`
+	topologyFromKubeletDesc = `If true, simulates the Topology Manager policy and
+scope each Node's kubelet is configured with instead of --policy and
+--scope, and only aligns CPU or memory if the kubelet's CPU or memory
+manager policy is static.`
+)
+
+var (
+	topologyNodeGetOpts = knode.NodeGetOptions{}
+	topologyAgentNS     string
+	topologyManifest    string
+	topologyPolicy      string
+	topologyScope       string
+	topologyFromKubelet bool
+)
+
+// topologyCmd represents the topology command
+var topologyCmd = &cobra.Command{
+	Use:   "topology",
+	Short: "Simulate Topology Manager admission of pods on nodes",
+	Long: `Simulate Topology Manager admission of pods on nodes.
+
+For each Pod in a manifest and each Node with known NUMA cells, shows whether
+the kubelet Topology Manager would admit the Pod and which NUMA cells it would
+land on, or why it would be rejected with a TopologyAffinityError. Nodes
+whose NUMA cells are unknown, e.g. because neither NodeResourceTopology nor
+the kwiz agent reports them, are shown as unknown.`,
+	Aliases: []string{"tm"},
+	RunE:    showTopologySimulation,
+}
+
+func init() {
+	topologyCmd.Flags().StringVar(&topologyManifest, "manifest", "", topologyManifestDesc)
+	topologyCmd.MarkFlagRequired("manifest")
+	topologyCmd.Flags().StringVar(&topologyPolicy, "policy", "", topologyPolicyDesc)
+	topologyCmd.Flags().StringVar(&topologyScope, "scope", "", topologyScopeDesc)
+	topologyCmd.Flags().BoolVar(&topologyFromKubelet, "from-kubelet", false, topologyFromKubeletDesc)
+	topologyCmd.Flags().StringVar(&topologyAgentNS, "agent-namespace", kagent.DefaultNamespace, agentReportNamespaceDesc)
+	cmdutil.AddLabelSelectorFlagVar(topologyCmd, &topologyNodeGetOpts.LabelSelector)
+	rootCmd.AddCommand(topologyCmd)
+}
+
+func showTopologySimulation(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+
+	manifest, err := os.ReadFile(topologyManifest)
+	if err != nil {
+		return err
+	}
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	ranges, err := klimitrange.Get(ctx, conn)
+	if err != nil {
+		return err
+	}
+	pods, err := kpod.FromManifest(manifest, klimitrange.ByNamespace(ranges))
+	if err != nil {
+		return err
+	}
+	topologyNodeGetOpts.KubeletConfig = topologyFromKubelet
+	topologyNodeGetOpts.AgentReports, err = getAgentReports(ctx, conn, topologyAgentNS)
+	if err != nil {
+		return err
+	}
+	nodes, err := knode.Get(ctx, conn, &topologyNodeGetOpts)
+	if err != nil {
+		return err
+	}
+	printWarnings(kubeletConfigWarnings(nodes))
+
+	switch outputFormat {
+	case outputFormatHuman:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{
+			"POD", "NODE", "POLICY", "SCOPE", "ADMITTED", "NUMA CELLS", "REASON",
+		})
+		table.SetColumnAlignment([]int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+		})
+		table.SetRowLine(true)
+		for _, pod := range pods {
+			for _, node := range nodes {
+				if !fit.FreeKnown(node.NUMACells) {
+					colors := make([]tablewriter.Colors, 7)
+					colors[4] = twColorYellowNormal
+					table.Rich([]string{
+						pod.Namespace + "/" + pod.Name,
+						node.Name,
+						"-",
+						"-",
+						"unknown",
+						"-",
+						"the NUMA cells of the node or their free amounts are unknown",
+					}, colors)
+					continue
+				}
+				results, err := simulateTopology(pod, node)
+				if err != nil {
+					return err
+				}
+				for _, res := range results {
+					colors := make([]tablewriter.Colors, 7)
+					admitted := "yes"
+					if !res.Admitted {
+						admitted = "no"
+						colors[4] = twColorRedNormal
+					}
+					table.Rich([]string{
+						pod.Namespace + "/" + pod.Name,
+						node.Name,
+						res.Policy,
+						res.Scope,
+						admitted,
+						cellsString(res.Cells),
+						res.Reason,
+					}, colors)
+				}
+			}
+		}
+		table.Render()
+	}
+	return nil
+}
+
+// simulateTopology returns the results of simulating the Topology Manager
+// admitting the supplied Pod to the supplied Node with the policies and
+// scopes selected on the command line
+func simulateTopology(
+	pod *types.Pod,
+	node *types.Node,
+) ([]*topology.Result, error) {
+	policy, scope := topologyPolicy, topologyScope
+	managers := topology.Managers{StaticCPU: true, StaticMemory: true}
+	if topologyFromKubelet && node.KubeletConfig != nil {
+		policy = node.KubeletConfig.TopologyManagerPolicy
+		scope = node.KubeletConfig.TopologyManagerScope
+		managers = topology.ManagersFromKubelet(node.KubeletConfig)
+	}
+	policies, scopes := topology.Policies, topology.Scopes
+	if policy != "" {
+		policies = []string{policy}
+	}
+	if scope != "" {
+		scopes = []string{scope}
+	}
+	results := []*topology.Result{}
+	for _, p := range policies {
+		for _, s := range scopes {
+			res, err := topology.SimulateWith(pod, node.NUMACells, p, s, managers)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+// cellsString returns a comma-separated list of NUMA cell IDs, or "-" if
+// there are none
+func cellsString(cells []int) string {
+	if len(cells) == 0 {
+		return "-"
+	}
+	ids := make([]string, len(cells))
+	for x, id := range cells {
+		ids[x] = strconv.Itoa(id)
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,125 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	kwcontext "github.com/jaypipes/kwiz/pkg/context"
+	"github.com/jaypipes/kwiz/pkg/kube"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	kworkload "github.com/jaypipes/kwiz/pkg/kube/workload"
+)
+
+const (
+	// maxWorkloadNodesShown is the maximum number of Node names shown for
+	// each workload
+	maxWorkloadNodesShown = 3
+)
+
+// workloadCmd represents the workload command
+var workloadCmd = &cobra.Command{
+	Use:     "workload",
+	Short:   "Show resource summary by workload",
+	Aliases: []string{"workloads", "wl"},
+	RunE:    showWorkloadResourceSummary,
+}
+
+func init() {
+	rootCmd.AddCommand(workloadCmd)
+}
+
+func showWorkloadResourceSummary(cmd *cobra.Command, args []string) error {
+	var cancel context.CancelFunc
+
+	ctx := kwcontext.New()
+	cfg, err := kube.Config(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := kconnect.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	kwcontext.RegisterConnection(ctx, conn)
+
+	ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	workloads, err := kworkload.Get(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case outputFormatHuman:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAutoMergeCellsByColumnIndex([]int{0, 1, 2, 7})
+		table.SetHeader([]string{
+			"NAMESPACE", "WORKLOAD", "REPLICAS", "RESOURCE",
+			"PER REPLICA (REQ/LIM)", "TOTAL FLOOR", "TOTAL CEIL", "NODES",
+		})
+		table.SetColumnAlignment([]int{
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_LEFT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_RIGHT,
+			tablewriter.ALIGN_LEFT,
+		})
+		table.SetRowLine(true)
+		for _, w := range workloads {
+			name := w.Kind + "/" + w.Name
+			replicas := fmt.Sprintf("%d", len(w.Pods))
+			nodes := fmt.Sprintf("%d", len(w.Nodes))
+			if len(w.Nodes) > 0 {
+				shown := w.Nodes[:min(len(w.Nodes), maxWorkloadNodesShown)]
+				nodes += " (" + strings.Join(shown, ", ")
+				if len(w.Nodes) > maxWorkloadNodesShown {
+					nodes += ", ..."
+				}
+				nodes += ")"
+			}
+
+			cpu := w.PerReplica.CPU
+			totCPU := w.ResourceRequests.CPU
+			table.Append([]string{
+				w.Namespace,
+				name,
+				replicas,
+				"CPU",
+				cpuRequestString(cpu.Floor) + " / " + cpuRequestString(cpu.Ceiling),
+				cpuRequestString(totCPU.Floor),
+				cpuRequestString(totCPU.Ceiling),
+				nodes,
+			})
+
+			mem := w.PerReplica.Memory
+			totMem := w.ResourceRequests.Memory
+			table.Append([]string{
+				w.Namespace,
+				name,
+				replicas,
+				"Memory",
+				memRequestString(mem.Floor) + " / " + memRequestString(mem.Ceiling),
+				memRequestString(totMem.Floor),
+				memRequestString(totMem.Ceiling),
+				nodes,
+			})
+		}
+		table.Render()
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/cgroup"
+	"github.com/jaypipes/kwiz/pkg/checkpoint"
+	"github.com/jaypipes/kwiz/pkg/podresources"
+	"github.com/jaypipes/kwiz/pkg/sysfs"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// memoryTypeRegular is the memory manager's memory type for regular
+	// (non-hugepage) memory
+	memoryTypeRegular = "memory"
+	// staleIntervals is the number of publishing intervals after which a
+	// report is stale, e.g. because its agent died or its Node was replaced
+	staleIntervals = 3
+)
+
+// Report contains the NUMA topology and allocations of a single Node, as
+// seen by the kwiz agent running on that Node
+type Report struct {
+	// Node is the name of the Kubernetes Node the report describes
+	Node string `json:"node"`
+	// Generated is when the report was collected
+	Generated time.Time `json:"generated"`
+	// Interval is how often the agent publishes a new report. 0 if the
+	// agent publishes a single report.
+	Interval time.Duration `json:"interval"`
+	// NUMACells contains the NUMA cells of the Node
+	NUMACells []types.NUMACell `json:"numaCells"`
+	// Assignments contains the CPUs and NUMA-local memory exclusively
+	// assigned to each container on the Node
+	Assignments []types.ContainerAssignment `json:"assignments"`
+	// Usage contains the actual resource usage of each Pod on the Node, as
+	// read from the Pods' cgroups
+	Usage []types.PodUsage `json:"usage"`
+	// Warnings contains the problems the agent worked around while
+	// collecting the report, e.g. falling back to the kubelet's checkpoints
+	Warnings []string `json:"warnings"`
+}
+
+// Stale returns true if the report should have been replaced by a newer
+// one at the supplied time, i.e. it was generated more than a few of the
+// agent's publishing intervals earlier. A single report is never stale.
+func (r *Report) Stale(now time.Time) bool {
+	if r.Interval <= 0 {
+		return false
+	}
+	return now.Sub(r.Generated) > staleIntervals*r.Interval
+}
+
+// CollectOptions controls where Collect reads the host's topology,
+// kubelet state and cgroups from, and how long it samples usage for
+type CollectOptions struct {
+	// SysfsRoot is the directory sysfs is mounted at. sysfs.DefaultRoot if
+	// empty.
+	SysfsRoot string
+	// KubeletDir is the kubelet's root directory, containing its CPU and
+	// memory manager checkpoints. checkpoint.DefaultKubeletDir if empty.
+	KubeletDir string
+	// PodResourcesSocket is the path of the unix socket the kubelet serves
+	// its PodResources API on. podresources.DefaultSocket if empty.
+	PodResourcesSocket string
+	// CgroupRoot is the directory the cgroup hierarchy is mounted at.
+	// cgroup.DefaultRoot if empty.
+	CgroupRoot string
+	// UsageWindow is the interval over which Pods' CPU usage is averaged.
+	// Pod usage is not collected if 0, and the CPU used in each NUMA cell
+	// is unknown (-1).
+	UsageWindow time.Duration
+}
+
+// Collect returns a Report for the named Node by reading the local host's
+// NUMA topology and the kubelet's CPU and memory manager checkpoints. If the
+// kubelet's PodResources API socket exists, the assignments it returns,
+// which include devices, are used instead of the ones in the checkpoints,
+// unless the API cannot be queried. If a usage window is set, Pods' cgroups
+// are sampled at the start and end of the window to collect their usage.
+func Collect(
+	ctx context.Context,
+	nodeName string,
+	opts *CollectOptions,
+) (*Report, error) {
+	cells, err := sysfs.NUMACells(opts.SysfsRoot)
+	if err != nil {
+		return nil, err
+	}
+	cpuState, memState, err := checkpoint.Read(opts.KubeletDir)
+	if err != nil {
+		return nil, err
+	}
+	if cpuState != nil {
+		addAllocatedCPUs(cells, cpuState.Assignments)
+	}
+	if memState != nil {
+		addMemoryState(cells, memState)
+	}
+	assignments := checkpoint.Assignments(cpuState, memState)
+
+	socket := opts.PodResourcesSocket
+	if socket == "" {
+		socket = podresources.DefaultSocket
+	}
+	warnings := []string{}
+	if _, err := os.Stat(socket); err == nil {
+		var warning string
+		if assignments, warning = collectPodResources(ctx, socket, cells, assignments); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	usage := []types.PodUsage{}
+	if opts.UsageWindow > 0 {
+		if usage, err = collectUsage(ctx, opts.CgroupRoot, opts.UsageWindow, cells); err != nil {
+			return nil, err
+		}
+		addCellUsage(cells, usage)
+	} else {
+		for x := range cells {
+			cells[x].Resources.CPU.Used = -1
+		}
+	}
+	return &Report{
+		Node:        nodeName,
+		Generated:   time.Now().UTC(),
+		NUMACells:   cells,
+		Assignments: assignments,
+		Usage:       usage,
+		Warnings:    warnings,
+	}, nil
+}
+
+// collectUsage returns the usage of each Pod from two samples of the Pods'
+// cgroups taken the supplied window apart
+func collectUsage(
+	ctx context.Context,
+	root string,
+	window time.Duration,
+	cells []types.NUMACell,
+) ([]types.PodUsage, error) {
+	prev, err := cgroup.ReadPods(root)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(window):
+	}
+	cur, err := cgroup.ReadPods(root)
+	if err != nil {
+		return nil, err
+	}
+	return cgroup.Usage(prev, cur, time.Since(start), cells), nil
+}
+
+// addCellUsage sets the CPU used in each of the supplied NUMA cells to the
+// sum of the supplied Pods' CPU usage attributed to the cell. The memory
+// used in each cell, which includes system usage, is already known from
+// sysfs.
+func addCellUsage(cells []types.NUMACell, usage []types.PodUsage) {
+	for x := range cells {
+		cell := &cells[x]
+		cell.Resources.CPU.Used = 0
+		for _, u := range usage {
+			for _, cu := range u.NUMACells {
+				if cu.ID == cell.ID {
+					cell.Resources.CPU.Used += cu.CPU
+				}
+			}
+		}
+	}
+}
+
+// collectPodResources applies the allocations returned by the kubelet's
+// PodResources API on the supplied socket to the supplied NUMA cells and
+// returns its container assignments. If the API cannot be queried, e.g.
+// because the kubelet is restarting, the supplied checkpoint assignments
+// are returned instead, leaving the cells untouched, along with a warning
+// explaining why.
+func collectPodResources(
+	ctx context.Context,
+	socket string,
+	cells []types.NUMACell,
+	checkpointed []types.ContainerAssignment,
+) ([]types.ContainerAssignment, string) {
+	c, err := podresources.Connect(socket)
+	if err != nil {
+		return checkpointed, podResourcesWarning(err)
+	}
+	defer c.Close()
+	alloc, err := c.Allocatable(ctx)
+	if err != nil {
+		return checkpointed, podResourcesWarning(err)
+	}
+	assignments, err := c.List(ctx)
+	if err != nil {
+		return checkpointed, podResourcesWarning(err)
+	}
+	podresources.ApplyToCells(cells, alloc, assignments)
+	return assignments, ""
+}
+
+// podResourcesWarning returns the warning for the supplied error querying
+// the PodResources API
+func podResourcesWarning(err error) string {
+	return fmt.Sprintf(
+		"failed to query the PodResources API, using the kubelet checkpoints instead: %s",
+		err,
+	)
+}
+
+// addAllocatedCPUs sets the allocated CPUs and exclusively requested CPUs of
+// each of the supplied NUMA cells from the supplied CPU assignments
+func addAllocatedCPUs(cells []types.NUMACell, assignments []types.ContainerAssignment) {
+	allocated := map[int]bool{}
+	for _, a := range assignments {
+		for _, cpu := range a.CPUs {
+			allocated[cpu] = true
+		}
+	}
+	for x := range cells {
+		cell := &cells[x]
+		cell.AllocatedCPUs = []int{}
+		for _, core := range cell.Cores {
+			for _, cpu := range core.CPUs {
+				if allocated[cpu] {
+					cell.AllocatedCPUs = append(cell.AllocatedCPUs, cpu)
+				}
+			}
+		}
+		sort.Ints(cell.AllocatedCPUs)
+		cell.Resources.CPU.ExclusiveRequestedFloor = float64(len(cell.AllocatedCPUs))
+	}
+}
+
+// addMemoryState sets the allocatable, reserved and exclusively requested
+// memory of each of the supplied NUMA cells from the memory manager's
+// accounting of regular memory in the cell
+func addMemoryState(cells []types.NUMACell, memState *checkpoint.MemoryManagerState) {
+	for _, mc := range memState.NUMACells {
+		table, ok := mc.Memory[memoryTypeRegular]
+		if !ok {
+			continue
+		}
+		for x := range cells {
+			if cells[x].ID != mc.ID {
+				continue
+			}
+			mem := &cells[x].Resources.Memory
+			mem.Allocatable = table.Allocatable
+			mem.Reserved = table.SystemReserved
+			mem.ExclusiveRequestedFloor = table.Reserved
+		}
+	}
+}
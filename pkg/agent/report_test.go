@@ -0,0 +1,42 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package agent_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/agent"
+)
+
+func TestReportStale(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tcs := []struct {
+		name   string
+		report agent.Report
+		exp    bool
+	}{
+		{
+			"fresh",
+			agent.Report{Generated: now.Add(-2 * time.Minute), Interval: time.Minute},
+			false,
+		},
+		{
+			"three intervals old",
+			agent.Report{Generated: now.Add(-4 * time.Minute), Interval: time.Minute},
+			true,
+		},
+		{
+			"single report",
+			agent.Report{Generated: now.Add(-24 * time.Hour)},
+			false,
+		},
+	}
+	for _, tc := range tcs {
+		if got := tc.report.Stale(now); got != tc.exp {
+			t.Fatalf("%s: expected stale %v but got %v", tc.name, tc.exp, got)
+		}
+	}
+}
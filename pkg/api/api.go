@@ -0,0 +1,374 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/jaypipes/kwiz/pkg/fit"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// ContentType is the content type of every response body
+	ContentType = "application/json"
+	// pathNodes is the path of the Node list. A Node is at
+	// pathNodes + "/<name>".
+	pathNodes = "/v1/nodes"
+	// pathPods is the path of the Pod list
+	pathPods = "/v1/pods"
+	// pathFit is the path of the Pod fit check
+	pathFit = "/v1/fit"
+)
+
+// Server is an http.Handler serving a read-only JSON API of the Nodes and
+// Pods of its most recent refresh. The returned objects are the same as the
+// node and pod commands print with the JSON output format.
+//
+// Endpoints and their query parameters, which match the CLI flags:
+//
+//	GET /v1/nodes?selector=<label selector>
+//	GET /v1/nodes/<name>
+//	GET /v1/pods?namespace=<namespace>&selector=<label selector>
+//	    &field-selector=<field selector>&node=<node>&sort-by=<key>&top=<n>
+//	GET /v1/fit?cpu=<quantity>&memory=<quantity>&selector=<label selector>
+//	GET /v1/fit?namespace=<namespace>&pod=<name>&selector=<label selector>
+//
+// /v1/fit returns, for every Node matching the selector, whether a Pod with
+// the supplied CPU and memory requests, or the supplied existing Pod, fits on
+// the Node and why not.
+//
+// Every response has an ETag derived from its body. A request whose
+// If-None-Match header matches the ETag gets a 304 Not Modified response.
+type Server struct {
+	mu          sync.RWMutex
+	nodes       []*types.Node
+	pods        []*types.Pod
+	lastRefresh time.Time
+}
+
+// errorResponse is the body of a response to a request that failed
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer returns a new Server. It responds with 503 Service Unavailable
+// until its first Update.
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Update replaces the Nodes and Pods served by the Server
+func (s *Server) Update(nodes []*types.Node, pods []*types.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes = nodes
+	s.pods = pods
+	s.lastRefresh = time.Now()
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	s.mu.RLock()
+	nodes, pods, lastRefresh := s.nodes, s.pods, s.lastRefresh
+	s.mu.RUnlock()
+	if lastRefresh.IsZero() {
+		writeError(w, http.StatusServiceUnavailable, "cluster data not loaded yet")
+		return
+	}
+	w.Header().Set("Last-Modified", lastRefresh.UTC().Format(http.TimeFormat))
+
+	query := r.URL.Query()
+	var res interface{}
+	var err error
+	switch path := strings.TrimSuffix(r.URL.Path, "/"); {
+	case path == pathNodes:
+		res, err = filterNodes(nodes, query.Get("selector"))
+	case strings.HasPrefix(path, pathNodes+"/"):
+		name := strings.TrimPrefix(path, pathNodes+"/")
+		res, err = findNode(nodes, name)
+	case path == pathPods:
+		res, err = filterPods(pods, query)
+	case path == pathFit:
+		res, err = checkFit(nodes, pods, query)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown path %q", r.URL.Path))
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, r, res)
+}
+
+// filterNodes returns the supplied Nodes matching the supplied label
+// selector, or all of them if the selector is empty
+func filterNodes(nodes []*types.Node, selector string) ([]*types.Node, error) {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	res := []*types.Node{}
+	for _, node := range nodes {
+		if sel.Matches(labels.Set(node.Labels)) {
+			res = append(res, node)
+		}
+	}
+	return res, nil
+}
+
+// findNode returns the Node with the supplied name
+func findNode(nodes []*types.Node, name string) (*types.Node, error) {
+	for _, node := range nodes {
+		if node.Name == name {
+			return node, nil
+		}
+	}
+	return nil, NotFound("node", name)
+}
+
+// podFields returns the values of the fields of the supplied Pod that a
+// field selector may filter on, the same ones the API server supports for
+// Pods that kwiz keeps
+func podFields(pod *types.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":      pod.Name,
+		"metadata.namespace": pod.Namespace,
+		"spec.nodeName":      pod.Node,
+		"status.phase":       pod.Phase,
+	}
+}
+
+// filterPods returns the supplied Pods in the namespace, matching the label
+// selector and field selector and running on the Node in the "namespace",
+// "selector", "field-selector" and "node" query parameters, sorted by the
+// "sort-by" query parameter and limited to the number of Pods in the "top"
+// query parameter. Empty parameters do not filter, sort or limit.
+func filterPods(pods []*types.Pod, query url.Values) ([]*types.Pod, error) {
+	sel, err := labels.Parse(query.Get("selector"))
+	if err != nil {
+		return nil, err
+	}
+	fieldSel, err := fields.ParseSelector(query.Get("field-selector"))
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range fieldSel.Requirements() {
+		if _, ok := podFields(&types.Pod{})[req.Field]; !ok {
+			return nil, fmt.Errorf("unsupported field selector field %q", req.Field)
+		}
+	}
+	sortBy := query.Get("sort-by")
+	if sortBy != "" && !slices.Contains(kpod.SortKeys, sortBy) {
+		return nil, fmt.Errorf(
+			"invalid sort key %q. choices are: %s",
+			sortBy, strings.Join(kpod.SortKeys, ", "),
+		)
+	}
+	top := 0
+	if val := query.Get("top"); val != "" {
+		if top, err = strconv.Atoi(val); err != nil {
+			return nil, fmt.Errorf("invalid top %q: %w", val, err)
+		}
+	}
+	namespace, node := query.Get("namespace"), query.Get("node")
+	res := []*types.Pod{}
+	for _, pod := range pods {
+		if namespace != "" && pod.Namespace != namespace {
+			continue
+		}
+		if node != "" && pod.Node != node {
+			continue
+		}
+		if sel.Matches(labels.Set(pod.Labels)) && fieldSel.Matches(podFields(pod)) {
+			res = append(res, pod)
+		}
+	}
+	kpod.Sort(res, sortBy)
+	if top > 0 && len(res) > top {
+		res = res[:top]
+	}
+	return res, nil
+}
+
+// checkFit returns the result of fit.Check on every Node matching the
+// "selector" query parameter for either the existing Pod named by the "pod"
+// and "namespace" query parameters or a Pod requesting the CPU and memory in
+// the "cpu" and "memory" query parameters. An existing Pod is checked
+// against its own Node as if it were not running there.
+func checkFit(
+	nodes []*types.Node,
+	pods []*types.Pod,
+	query url.Values,
+) ([]fit.NodeResult, error) {
+	nodes, err := filterNodes(nodes, query.Get("selector"))
+	if err != nil {
+		return nil, err
+	}
+	var pod *types.Pod
+	if name := query.Get("pod"); name != "" {
+		namespace := query.Get("namespace")
+		if namespace == "" {
+			namespace = "default"
+		}
+		for _, p := range pods {
+			if p.Namespace == namespace && p.Name == name {
+				pod = p
+				break
+			}
+		}
+		if pod == nil {
+			return nil, NotFound("pod", namespace+"/"+name)
+		}
+		for x, node := range nodes {
+			if node.Name == pod.Node {
+				nodes[x] = withoutPod(node, pod)
+			}
+		}
+		return fit.CheckNodes(pod, nodes), nil
+	}
+	if query.Get("cpu") == "" && query.Get("memory") == "" {
+		return nil, fmt.Errorf("one of pod, cpu or memory is required")
+	}
+	// The Pod has no limits
+	reqs := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Ceiling: -1},
+		Memory: types.ResourceRequest{Ceiling: -1},
+	}
+	pod = &types.Pod{ResourceRequests: reqs, AdjustedResourceRequests: reqs}
+	for _, r := range []struct {
+		key   string
+		floor *float64
+	}{
+		{"cpu", &pod.ResourceRequests.CPU.Floor},
+		{"memory", &pod.ResourceRequests.Memory.Floor},
+	} {
+		val := query.Get(r.key)
+		if val == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", r.key, val, err)
+		}
+		*r.floor = q.AsApproximateFloat64()
+	}
+	return fit.CheckNodes(pod, nodes), nil
+}
+
+// withoutPod returns a copy of the supplied Node without the requests of the
+// supplied Pod running on it: the Pod's request floors and, in the Node's
+// NUMA cells, the CPUs and memory the kubelet assigned to its containers
+func withoutPod(node *types.Node, pod *types.Pod) *types.Node {
+	res := *node
+	for _, r := range []struct {
+		amounts *types.ResourceAmounts
+		floor   float64
+	}{
+		{&res.Resources.CPU, pod.ResourceRequests.CPU.Floor},
+		{&res.Resources.Memory, pod.ResourceRequests.Memory.Floor},
+		{&res.Resources.Pods, 1},
+	} {
+		// -1 means unknown, so never go below 0
+		if r.amounts.RequestedFloor != -1 {
+			r.amounts.RequestedFloor = max(r.amounts.RequestedFloor-r.floor, 0)
+		}
+	}
+	res.NUMACells = append([]types.NUMACell{}, node.NUMACells...)
+	for x := range res.NUMACells {
+		cell := &res.NUMACells[x]
+		cpus := map[int]bool{}
+		for _, core := range cell.Cores {
+			for _, cpu := range core.CPUs {
+				cpus[cpu] = true
+			}
+		}
+		for _, a := range pod.Assignments {
+			for _, cpu := range a.CPUs {
+				if cpus[cpu] && cell.Resources.CPU.RequestedFloor > 0 {
+					cell.Resources.CPU.RequestedFloor--
+				}
+			}
+			for _, m := range a.Memory {
+				if m.Type == "memory" && slices.Equal(m.NUMACells, []int{cell.ID}) &&
+					cell.Resources.Memory.RequestedFloor != -1 {
+					cell.Resources.Memory.RequestedFloor = max(
+						cell.Resources.Memory.RequestedFloor-m.Size, 0,
+					)
+				}
+			}
+		}
+	}
+	return &res
+}
+
+// writeJSON writes the supplied value as the JSON body of a response with an
+// ETag derived from the body, or an empty 304 Not Modified response if the
+// request's If-None-Match header matches the ETag
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+// etagMatches returns true if the supplied If-None-Match header value
+// matches the supplied ETag
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError writes a JSON error response with the supplied status code
+func writeError(w http.ResponseWriter, status int, msg string) {
+	body, _ := json.Marshal(errorResponse{Error: msg})
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,217 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/api"
+	"github.com/jaypipes/kwiz/pkg/fit"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func testServer() *api.Server {
+	node := func(name string, pool string, cpuFloor float64) *types.Node {
+		return &types.Node{
+			Name:   name,
+			Labels: map[string]string{"pool": pool},
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: cpuFloor},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+				Pods:   types.ResourceAmounts{Allocatable: 110},
+			},
+		}
+	}
+	pod := func(ns string, name string, node string, cpu float64) *types.Pod {
+		return &types.Pod{
+			Namespace: ns,
+			Name:      name,
+			Node:      node,
+			Labels:    map[string]string{"app": name},
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: cpu, Ceiling: -1},
+			},
+		}
+	}
+	s := api.NewServer()
+	s.Update(
+		[]*types.Node{node("a", "general", 2), node("b", "gpu", 7)},
+		[]*types.Pod{
+			pod("default", "web", "a", 2),
+			pod("batch", "job", "b", 7),
+			pod("batch", "pending", "", 4),
+		},
+	)
+	return s
+}
+
+// get performs a GET request against the supplied Server and decodes the
+// JSON response body into the supplied value, if not nil
+func get(
+	t *testing.T,
+	s *api.Server,
+	target string,
+	header http.Header,
+	v interface{},
+) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("unexpected error decoding %s: %s", rec.Body.String(), err)
+		}
+	}
+	return rec
+}
+
+func TestServerNotLoaded(t *testing.T) {
+	rec := get(t, api.NewServer(), "/v1/nodes", nil, nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 before the first refresh but got %d", rec.Code)
+	}
+}
+
+func TestServerNodes(t *testing.T) {
+	s := testServer()
+	nodes := []*types.Node{}
+	rec := get(t, s, "/v1/nodes?selector=pool%3Dgpu", nil, &nodes)
+	if rec.Code != http.StatusOK || len(nodes) != 1 || nodes[0].Name != "b" {
+		t.Fatalf("expected only node b but got %d %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != api.ContentType {
+		t.Fatalf("expected content type %q but got %q", api.ContentType, ct)
+	}
+
+	node := types.Node{}
+	rec = get(t, s, "/v1/nodes/a", nil, &node)
+	if rec.Code != http.StatusOK || node.Name != "a" || node.Resources.CPU.RequestedFloor != 2 {
+		t.Fatalf("expected node a but got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec = get(t, s, "/v1/nodes/c", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown node but got %d", rec.Code)
+	}
+	if rec = get(t, s, "/v1/nodes?selector=%3D%3D", nil, nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid selector but got %d", rec.Code)
+	}
+}
+
+func TestServerPods(t *testing.T) {
+	s := testServer()
+	tcs := []struct {
+		query string
+		exp   []string
+	}{
+		{"", []string{"web", "job", "pending"}},
+		{"?namespace=batch", []string{"job", "pending"}},
+		{"?namespace=batch&selector=app%21%3Dpending", []string{"job"}},
+		{"?node=a", []string{"web"}},
+		{"?field-selector=spec.nodeName%3D", []string{"pending"}},
+		{"?field-selector=metadata.namespace%21%3Dbatch", []string{"web"}},
+		{"?sort-by=name", []string{"job", "pending", "web"}},
+		{"?sort-by=cpu-floor&top=2", []string{"job", "pending"}},
+	}
+	for _, tc := range tcs {
+		pods := []*types.Pod{}
+		get(t, s, "/v1/pods"+tc.query, nil, &pods)
+		got := []string{}
+		for _, p := range pods {
+			got = append(got, p.Name)
+		}
+		if !reflect.DeepEqual(got, tc.exp) {
+			t.Fatalf("expected %v for %q but got %v", tc.exp, tc.query, got)
+		}
+	}
+	for _, query := range []string{
+		"?field-selector=spec.hostname%3Da",
+		"?sort-by=age",
+		"?top=many",
+	} {
+		if rec := get(t, s, "/v1/pods"+query, nil, nil); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q but got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestServerFit(t *testing.T) {
+	s := testServer()
+	res := []fit.NodeResult{}
+	get(t, s, "/v1/fit?cpu=2&memory=1Gi", nil, &res)
+	exp := []fit.NodeResult{
+		{Node: "a", Fits: true, Reasons: []string{}},
+		{Node: "b", Fits: false, Reasons: []string{fit.ReasonInsufficientCPU}},
+	}
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, res)
+	}
+
+	res = []fit.NodeResult{}
+	get(t, s, "/v1/fit?namespace=batch&pod=pending&selector=pool%3Dgeneral", nil, &res)
+	exp = []fit.NodeResult{{Node: "a", Fits: true, Reasons: []string{}}}
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, res)
+	}
+
+	// The job's own requests are not counted against its Node
+	res = []fit.NodeResult{}
+	get(t, s, "/v1/fit?namespace=batch&pod=job", nil, &res)
+	exp = []fit.NodeResult{
+		{Node: "a", Fits: false, Reasons: []string{fit.ReasonInsufficientCPU}},
+		{Node: "b", Fits: true, Reasons: []string{}},
+	}
+	if !reflect.DeepEqual(res, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, res)
+	}
+	nodes := []*types.Node{}
+	get(t, s, "/v1/nodes", nil, &nodes)
+	if nodes[1].Resources.CPU.RequestedFloor != 7 {
+		t.Fatalf("expected the served Node to be unchanged but got %+v", nodes[1].Resources.CPU)
+	}
+
+	for target, code := range map[string]int{
+		"/v1/fit":               http.StatusBadRequest,
+		"/v1/fit?cpu=lots":      http.StatusBadRequest,
+		"/v1/fit?pod=missing":   http.StatusNotFound,
+		"/v1/unknown?cpu=1":     http.StatusNotFound,
+		"/v1/nodes/a/b?cpu=200": http.StatusNotFound,
+	} {
+		if rec := get(t, s, target, nil, nil); rec.Code != code {
+			t.Fatalf("expected %d for %s but got %d", code, target, rec.Code)
+		}
+	}
+}
+
+func TestServerETag(t *testing.T) {
+	s := testServer()
+	rec := get(t, s, "/v1/nodes", nil, nil)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+	rec = get(t, s, "/v1/nodes", http.Header{"If-None-Match": {etag}}, nil)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty 304 but got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = get(t, s, "/v1/nodes?selector=pool%3Dgpu", http.Header{"If-None-Match": {etag}}, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a different response but got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/nodes", nil)
+	post := httptest.NewRecorder()
+	s.ServeHTTP(post, req)
+	if post.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST but got %d", post.Code)
+	}
+}
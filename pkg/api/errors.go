@@ -0,0 +1,25 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package api
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned when a requested Node or Pod is not in the
+	// data of the Server's most recent refresh.
+	ErrNotFound = fmt.Errorf(
+		"%w: not found",
+		kwerrors.RuntimeError,
+	)
+)
+
+// NotFound returns ErrNotFound with some further context
+func NotFound(kind string, name string) error {
+	return fmt.Errorf("%w: %s %s", ErrNotFound, kind, name)
+}
@@ -0,0 +1,374 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jaypipes/kwiz/pkg/cpuset"
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+const (
+	// DefaultRoot is the default mount point of the cgroup hierarchy
+	DefaultRoot = "/sys/fs/cgroup"
+)
+
+var (
+	// ErrInvalidCgroup is returned when a cgroup file cannot be read or has
+	// unexpected contents.
+	ErrInvalidCgroup = fmt.Errorf(
+		"%w: invalid cgroup",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidCgroup returns ErrInvalidCgroup with some further context
+func InvalidCgroup(path string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidCgroup, path, reason)
+}
+
+var (
+	// kubepodsDirs are the names of the kubelet's top-level cgroup for Pods
+	// with the systemd and cgroupfs cgroup drivers
+	kubepodsDirs = []string{"kubepods.slice", "kubepods"}
+	// podDirRegex matches the name of a Pod's cgroup with the systemd (e.g.
+	// "kubepods-burstable-pod1b2c_3d4e.slice") and cgroupfs (e.g.
+	// "pod1b2c-3d4e") cgroup drivers, capturing the Pod's UID
+	podDirRegex = regexp.MustCompile(`(?:^|-)pod([0-9a-fA-F_-]+)(?:\.slice)?$`)
+	// v1CPUAcctDirs are the names the cpuacct controller may be mounted at
+	// in a cgroup v1 hierarchy
+	v1CPUAcctDirs = []string{"cpu,cpuacct", "cpuacct,cpu", "cpuacct"}
+)
+
+// PodStats contains the cumulative CPU counters and current memory usage of
+// a single Pod's cgroup
+type PodStats struct {
+	// PodUID is the UID of the Pod
+	PodUID string
+	// CPUUsageSeconds is the total CPU time consumed by the Pod
+	CPUUsageSeconds float64
+	// Periods is the number of CFS enforcement periods that have elapsed
+	// while the Pod had a CPU limit
+	Periods int64
+	// ThrottledPeriods is the number of CFS enforcement periods in which the
+	// Pod was throttled
+	ThrottledPeriods int64
+	// ThrottledSeconds is the total time the Pod was throttled for
+	ThrottledSeconds float64
+	// MemoryUsage is the number of bytes of memory charged to the Pod,
+	// including reclaimable page cache
+	MemoryUsage float64
+	// MemoryWorkingSet is MemoryUsage less inactive file-backed memory, the
+	// same working set the kubelet evicts on
+	MemoryWorkingSet float64
+	// CPUs contains the logical CPUs the Pod may run on. Empty if unknown.
+	CPUs []int
+	// MemoryByNUMACell contains the number of bytes of the Pod's memory
+	// allocated from each NUMA cell, keyed by NUMA cell ID. Empty if
+	// unknown.
+	MemoryByNUMACell map[int]float64
+}
+
+// IsV2 returns true if the cgroup hierarchy mounted at the supplied root
+// directory (DefaultRoot if empty) is a cgroup v2 unified hierarchy
+func IsV2(root string) bool {
+	if root == "" {
+		root = DefaultRoot
+	}
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+// ReadPods returns a map, keyed by Pod UID, of the stats of every Pod cgroup
+// under the cgroup hierarchy mounted at the supplied root directory
+// (DefaultRoot if empty). Both cgroup v1 and v2 hierarchies, created with
+// either the systemd or the cgroupfs cgroup driver, are supported.
+func ReadPods(root string) (map[string]*PodStats, error) {
+	if root == "" {
+		root = DefaultRoot
+	}
+	v2 := IsV2(root)
+	// Pod cgroups are discovered in the memory controller's hierarchy with
+	// cgroup v1, and the same relative paths are read in the others
+	discoverRoot := root
+	if !v2 {
+		discoverRoot = filepath.Join(root, "memory")
+	}
+	podPaths, err := podCgroups(discoverRoot)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*PodStats, len(podPaths))
+	for uid, rel := range podPaths {
+		var stats *PodStats
+		if v2 {
+			stats, err = readV2(filepath.Join(root, rel))
+		} else {
+			stats, err = readV1(root, rel)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stats.PodUID = uid
+		res[uid] = stats
+	}
+	return res, nil
+}
+
+// podCgroups returns a map, keyed by Pod UID, of the paths relative to the
+// supplied root of the cgroups of all Pods. Guaranteed Pods' cgroups are
+// directly under the kubelet's top-level cgroup and Burstable and
+// BestEffort Pods' cgroups are under a cgroup for their QoS class.
+func podCgroups(root string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, kubepods := range kubepodsDirs {
+		entries, err := os.ReadDir(filepath.Join(root, kubepods))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			rel := filepath.Join(kubepods, entry.Name())
+			if uid, ok := podUID(entry.Name()); ok {
+				res[uid] = rel
+				continue
+			}
+			qosEntries, err := os.ReadDir(filepath.Join(root, rel))
+			if err != nil {
+				return nil, err
+			}
+			for _, qosEntry := range qosEntries {
+				if !qosEntry.IsDir() {
+					continue
+				}
+				if uid, ok := podUID(qosEntry.Name()); ok {
+					res[uid] = filepath.Join(rel, qosEntry.Name())
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// podUID returns the Pod UID in the supplied Pod cgroup directory name, and
+// false if the name is not that of a Pod cgroup. The systemd cgroup driver
+// replaces the dashes in the UID with underscores.
+func podUID(name string) (string, bool) {
+	m := podDirRegex.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	return strings.ReplaceAll(m[1], "_", "-"), true
+}
+
+// readV2 returns the stats of the cgroup v2 cgroup at the supplied path
+func readV2(dir string) (*PodStats, error) {
+	stats := &PodStats{}
+	cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stats.CPUUsageSeconds = float64(cpuStat["usage_usec"]) / 1e6
+	stats.Periods = cpuStat["nr_periods"]
+	stats.ThrottledPeriods = cpuStat["nr_throttled"]
+	stats.ThrottledSeconds = float64(cpuStat["throttled_usec"]) / 1e6
+
+	current, err := readInt(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	memStat, err := readKeyValues(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stats.MemoryUsage = float64(current)
+	stats.MemoryWorkingSet = workingSet(current, memStat["inactive_file"])
+
+	if stats.CPUs, err = readCPUs(filepath.Join(dir, "cpuset.cpus.effective")); err != nil {
+		return nil, err
+	}
+	numaStat, err := readNUMAStat(filepath.Join(dir, "memory.numa_stat"))
+	if err != nil {
+		return nil, err
+	}
+	stats.MemoryByNUMACell = map[int]float64{}
+	for id, anon := range numaStat["anon"] {
+		file := numaStat["file"][id]
+		stats.MemoryByNUMACell[id] = workingSet(anon+file, numaStat["inactive_file"][id])
+	}
+	return stats, nil
+}
+
+// readV1 returns the stats of the cgroup v1 cgroup at the supplied path,
+// relative to each controller's hierarchy, under the supplied root
+func readV1(root string, rel string) (*PodStats, error) {
+	stats := &PodStats{}
+	for _, ctrl := range v1CPUAcctDirs {
+		dir := filepath.Join(root, ctrl, rel)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		usage, err := readInt(filepath.Join(dir, "cpuacct.usage"))
+		if err != nil {
+			return nil, err
+		}
+		cpuStat, err := readKeyValues(filepath.Join(dir, "cpu.stat"))
+		if err != nil {
+			return nil, err
+		}
+		stats.CPUUsageSeconds = float64(usage) / 1e9
+		stats.Periods = cpuStat["nr_periods"]
+		stats.ThrottledPeriods = cpuStat["nr_throttled"]
+		stats.ThrottledSeconds = float64(cpuStat["throttled_time"]) / 1e9
+		break
+	}
+
+	dir := filepath.Join(root, "memory", rel)
+	usage, err := readInt(filepath.Join(dir, "memory.usage_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	memStat, err := readKeyValues(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stats.MemoryUsage = float64(usage)
+	stats.MemoryWorkingSet = workingSet(usage, memStat["total_inactive_file"])
+
+	numaStat, err := readNUMAStat(filepath.Join(dir, "memory.numa_stat"))
+	if err != nil {
+		return nil, err
+	}
+	// Unlike cgroup v2, cgroup v1 reports the per-NUMA node amounts in pages
+	pageSize := int64(os.Getpagesize())
+	stats.MemoryByNUMACell = map[int]float64{}
+	for id, anon := range numaStat["hierarchical_anon"] {
+		pages := anon + numaStat["hierarchical_file"][id]
+		stats.MemoryByNUMACell[id] = float64(pages * pageSize)
+	}
+
+	stats.CPUs, err = readCPUs(filepath.Join(root, "cpuset", rel, "cpuset.effective_cpus"))
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// workingSet returns the supplied memory usage less the supplied inactive
+// file-backed memory, floored at 0
+func workingSet(usage int64, inactiveFile int64) float64 {
+	return float64(max(usage-inactiveFile, 0))
+}
+
+// readInt returns the integer contents of the supplied file
+func readInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, InvalidCgroup(path, err.Error())
+	}
+	v, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, InvalidCgroup(path, err.Error())
+	}
+	return v, nil
+}
+
+// readCPUs returns the cpuset in the supplied file, or nil if the file does
+// not exist because the cpuset controller is not enabled for the cgroup
+func readCPUs(path string) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, InvalidCgroup(path, err.Error())
+	}
+	cpus, err := cpuset.Parse(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, InvalidCgroup(path, err.Error())
+	}
+	return cpus, nil
+}
+
+// readKeyValues returns the integer values in the supplied flat keyed file,
+// such as cpu.stat or memory.stat, keyed by name
+func readKeyValues(path string) (map[string]int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, InvalidCgroup(path, err.Error())
+	}
+	res := map[string]int64{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, InvalidCgroup(path, fmt.Sprintf("invalid value of %s", fields[0]))
+		}
+		res[fields[0]] = v
+	}
+	return res, nil
+}
+
+// readNUMAStat returns the per-NUMA cell values in the supplied
+// memory.numa_stat file, keyed by name and then by NUMA cell ID. Lines look
+// like "anon N0=1024 N1=0" with cgroup v2 and "total=1024 N0=1024 N1=0" with
+// cgroup v1. The file does not exist on hosts without NUMA support, in
+// which case an empty map is returned.
+func readNUMAStat(path string) (map[string]map[int]int64, error) {
+	res := map[string]map[int]int64{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return res, nil
+		}
+		return nil, InvalidCgroup(path, err.Error())
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		// The cgroup v1 format puts the name and total in the first field
+		name, _, _ := strings.Cut(fields[0], "=")
+		values := map[int]int64{}
+		for _, f := range fields[1:] {
+			node, value, ok := strings.Cut(f, "=")
+			if !ok || !strings.HasPrefix(node, "N") {
+				continue
+			}
+			id, err := strconv.Atoi(strings.TrimPrefix(node, "N"))
+			if err != nil {
+				return nil, InvalidCgroup(path, fmt.Sprintf("invalid NUMA node %q", node))
+			}
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, InvalidCgroup(path, fmt.Sprintf("invalid value of %s", f))
+			}
+			values[id] = v
+		}
+		res[name] = values
+	}
+	return res, nil
+}
@@ -0,0 +1,164 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cgroup_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/cgroup"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+const (
+	guaranteedUID = "5f7e0b1c-8d2a-4f43-9a7e-2c6b9d1e0a11"
+	burstableUID  = "9b2d4c6e-1a3f-4e5b-8c7d-0f1e2d3c4b5a"
+)
+
+var testCgroupDir = filepath.Join("..", "..", "test", "testdata", "cgroup")
+
+func TestReadPods(t *testing.T) {
+	v1PageSize := float64(os.Getpagesize())
+	tcs := []struct {
+		name        string
+		v2          bool
+		guaranteedN map[int]float64
+		burstableN  map[int]float64
+	}{
+		{
+			"v2",
+			true,
+			map[int]float64{0: 3.5 * unit.Gi, 1: 0},
+			map[int]float64{0: 384 * unit.Mi, 1: 512 * unit.Mi},
+		},
+		{
+			"v1",
+			false,
+			map[int]float64{0: 1048576 * v1PageSize, 1: 0},
+			map[int]float64{},
+		},
+	}
+	for _, tc := range tcs {
+		root := filepath.Join(testCgroupDir, tc.name)
+		if cgroup.IsV2(root) != tc.v2 {
+			t.Fatalf("%s: expected IsV2 to be %v", tc.name, tc.v2)
+		}
+		pods, err := cgroup.ReadPods(root)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		exp := map[string]*cgroup.PodStats{
+			guaranteedUID: {
+				PodUID:           guaranteedUID,
+				CPUUsageSeconds:  120,
+				MemoryUsage:      4 * unit.Gi,
+				MemoryWorkingSet: 3.5 * unit.Gi,
+				CPUs:             []int{1, 2, 9, 10},
+				MemoryByNUMACell: tc.guaranteedN,
+			},
+			burstableUID: {
+				PodUID:           burstableUID,
+				CPUUsageSeconds:  50,
+				Periods:          1000,
+				ThrottledPeriods: 250,
+				ThrottledSeconds: 12.5,
+				MemoryUsage:      unit.Gi,
+				MemoryWorkingSet: 896 * unit.Mi,
+				CPUs:             []int{},
+				MemoryByNUMACell: tc.burstableN,
+			},
+		}
+		for x := 0; x < 32; x++ {
+			exp[burstableUID].CPUs = append(exp[burstableUID].CPUs, x)
+		}
+		if !reflect.DeepEqual(pods, exp) {
+			for uid, p := range pods {
+				t.Logf("%s: %s: %+v", tc.name, uid, *p)
+			}
+			t.Fatalf("%s: unexpected Pod stats", tc.name)
+		}
+	}
+}
+
+func TestReadPodsInvalid(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "kubepods", "pod"+guaranteedUID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{
+		"cgroup.controllers":                               "cpu memory",
+		"kubepods/pod" + guaranteedUID + "/cpu.stat":       "usage_usec lots",
+		"kubepods/pod" + guaranteedUID + "/memory.current": "0",
+		"kubepods/pod" + guaranteedUID + "/memory.stat":    "",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := cgroup.ReadPods(root)
+	if !errors.Is(err, cgroup.ErrInvalidCgroup) {
+		t.Fatalf("expected ErrInvalidCgroup but got %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	cur, err := cgroup.ReadPods(filepath.Join(testCgroupDir, "v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The guaranteed Pod used 2 cores over the last 10 seconds and the
+	// burstable Pod 0.5 cores. A Pod that started since the previous sample
+	// is omitted.
+	prev := map[string]*cgroup.PodStats{
+		guaranteedUID: {CPUUsageSeconds: 100},
+		burstableUID:  {CPUUsageSeconds: 45},
+	}
+	cur["new"] = &cgroup.PodStats{PodUID: "new", CPUUsageSeconds: 1}
+	cells := []types.NUMACell{{ID: 0}, {ID: 1}}
+	for x := 0; x < 32; x++ {
+		cell := &cells[x/16]
+		cell.Cores = append(cell.Cores, types.CPUCore{ID: x, CPUs: []int{x}})
+	}
+
+	got := cgroup.Usage(prev, cur, 10*time.Second, cells)
+	exp := []types.PodUsage{
+		{
+			PodUID: guaranteedUID,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Used: 2},
+				Memory: types.ResourceAmounts{Used: 3.5 * unit.Gi},
+			},
+			NUMACells: []types.NUMACellUsage{
+				{ID: 0, CPU: 2, Memory: 3.5 * unit.Gi},
+			},
+			Throttling: &types.CPUThrottling{},
+		},
+		{
+			PodUID: burstableUID,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Used: 0.5},
+				Memory: types.ResourceAmounts{Used: 896 * unit.Mi},
+			},
+			NUMACells: []types.NUMACellUsage{
+				{ID: 0, CPU: 0.25, Memory: 384 * unit.Mi},
+				{ID: 1, CPU: 0.25, Memory: 512 * unit.Mi},
+			},
+			Throttling: &types.CPUThrottling{
+				Periods:          1000,
+				ThrottledPeriods: 250,
+				ThrottledSeconds: 12.5,
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+}
@@ -0,0 +1,91 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cgroup
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+// Usage returns the usage of each Pod, sorted by Pod UID, from two samples
+// of Pod stats taken the supplied duration apart. CPU usage is the average
+// over the interval between the samples. Memory usage is the working set at
+// the time of the current sample and throttling is the Pod's counters since
+// it started. Pods missing from the previous sample, having just started,
+// are omitted.
+//
+// If the supplied NUMA cells have a known CPU topology, each Pod's CPU
+// usage is attributed to the NUMA cells in proportion to the number of the
+// Pod's CPUs in each. Memory usage is attributed to the NUMA cells it was
+// allocated from.
+func Usage(
+	prev map[string]*PodStats,
+	cur map[string]*PodStats,
+	elapsed time.Duration,
+	cells []types.NUMACell,
+) []types.PodUsage {
+	res := []types.PodUsage{}
+	if elapsed <= 0 {
+		return res
+	}
+	cellCPUs := map[int]int{}
+	for _, cell := range cells {
+		for _, core := range cell.Cores {
+			for _, cpu := range core.CPUs {
+				cellCPUs[cpu] = cell.ID
+			}
+		}
+	}
+	for uid, c := range cur {
+		p, ok := prev[uid]
+		if !ok {
+			continue
+		}
+		// The counter goes backwards if the Pod's cgroup was recreated
+		// between samples
+		cpu := max(c.CPUUsageSeconds-p.CPUUsageSeconds, 0) / elapsed.Seconds()
+		u := types.PodUsage{
+			PodUID: uid,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Used: cpu},
+				Memory: types.ResourceAmounts{Used: c.MemoryWorkingSet},
+			},
+			NUMACells: []types.NUMACellUsage{},
+			Throttling: &types.CPUThrottling{
+				Periods:          float64(c.Periods),
+				ThrottledPeriods: float64(c.ThrottledPeriods),
+				ThrottledSeconds: c.ThrottledSeconds,
+			},
+		}
+		cpusByCell := map[int]int{}
+		total := 0
+		for _, id := range c.CPUs {
+			if cellID, ok := cellCPUs[id]; ok {
+				cpusByCell[cellID]++
+				total++
+			}
+		}
+		for _, cell := range cells {
+			cu := types.NUMACellUsage{
+				ID:     cell.ID,
+				Memory: c.MemoryByNUMACell[cell.ID],
+			}
+			if total > 0 {
+				cu.CPU = cpu * float64(cpusByCell[cell.ID]) / float64(total)
+			}
+			if cu.CPU == 0 && cu.Memory == 0 {
+				continue
+			}
+			u.NUMACells = append(u.NUMACells, cu)
+		}
+		res = append(res, u)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].PodUID < res[j].PodUID
+	})
+	return res
+}
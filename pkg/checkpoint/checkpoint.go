@@ -0,0 +1,82 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package checkpoint
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// DefaultKubeletDir is the default root directory of the kubelet, which
+	// contains its checkpoint files
+	DefaultKubeletDir = "/var/lib/kubelet"
+)
+
+// Read returns the CPU manager and memory manager state read from the
+// checkpoint files in the supplied kubelet root directory
+// (DefaultKubeletDir if empty). A nil state is returned for a checkpoint
+// file that does not exist, e.g. because the kubelet's memory manager is
+// not enabled.
+func Read(kubeletDir string) (*CPUManagerState, *MemoryManagerState, error) {
+	if kubeletDir == "" {
+		kubeletDir = DefaultKubeletDir
+	}
+	var cpuState *CPUManagerState
+	var memState *MemoryManagerState
+	data, err := os.ReadFile(filepath.Join(kubeletDir, CPUManagerStateFile))
+	if err == nil {
+		if cpuState, err = ParseCPUManagerState(data); err != nil {
+			return nil, nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	data, err = os.ReadFile(filepath.Join(kubeletDir, MemoryManagerStateFile))
+	if err == nil {
+		if memState, err = ParseMemoryManagerState(data); err != nil {
+			return nil, nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	return cpuState, memState, nil
+}
+
+// Assignments returns the CPU and memory assignments of the supplied CPU
+// manager and memory manager states (either of which may be nil) merged
+// into one assignment per container, sorted by Pod UID and container name.
+func Assignments(
+	cpuState *CPUManagerState,
+	memState *MemoryManagerState,
+) []types.ContainerAssignment {
+	res := []types.ContainerAssignment{}
+	if cpuState != nil {
+		res = append(res, cpuState.Assignments...)
+	}
+	if memState == nil {
+		return res
+	}
+	for _, ma := range memState.Assignments {
+		merged := false
+		for x := range res {
+			a := &res[x]
+			if a.PodUID == ma.PodUID && a.ContainerName == ma.ContainerName {
+				a.Memory = ma.Memory
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			res = append(res, ma)
+		}
+	}
+	sortAssignments(res)
+	return res
+}
@@ -0,0 +1,150 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package checkpoint_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/checkpoint"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+var testKubeletDir = filepath.Join("..", "..", "test", "testdata", "kubelet")
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join(testKubeletDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseCPUManagerState(t *testing.T) {
+	// This checkpoint and its checksum are from the kubelet's own tests
+	st, err := checkpoint.ParseCPUManagerState([]byte(
+		`{"policyName": "none", "defaultCPUSet": "4-6", "entries": {}, "checksum": 354655845}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.PolicyName != "none" || !reflect.DeepEqual(st.DefaultCPUSet, []int{4, 5, 6}) {
+		t.Fatalf("unexpected CPU manager state: %+v", st)
+	}
+
+	st, err = checkpoint.ParseCPUManagerState(readFixture(t, "cpu_manager_state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []types.ContainerAssignment{
+		{
+			PodUID:        "5f7e0b1c-8d2a-4f43-9a7e-2c6b9d1e0a11",
+			ContainerName: "app",
+			CPUs:          []int{1, 2, 9, 10},
+		},
+		{
+			PodUID:        "9b2d4c6e-1a3f-4e5b-8c7d-0f1e2d3c4b5a",
+			ContainerName: "db",
+			CPUs:          []int{16, 17},
+		},
+		{
+			PodUID:        "9b2d4c6e-1a3f-4e5b-8c7d-0f1e2d3c4b5a",
+			ContainerName: "proxy",
+			CPUs:          []int{18},
+		},
+	}
+	if !reflect.DeepEqual(st.Assignments, exp) {
+		t.Fatalf("expected assignments %+v but got %+v", exp, st.Assignments)
+	}
+}
+
+func TestParseCPUManagerStateV1(t *testing.T) {
+	st, err := checkpoint.ParseCPUManagerState(readFixture(t, "cpu_manager_state_v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []types.ContainerAssignment{
+		{ContainerID: "4a6f1c2b3d", CPUs: []int{1, 2}},
+	}
+	if !reflect.DeepEqual(st.Assignments, exp) {
+		t.Fatalf("expected assignments %+v but got %+v", exp, st.Assignments)
+	}
+}
+
+func TestParseCPUManagerStateCorrupt(t *testing.T) {
+	data := strings.Replace(string(readFixture(t, "cpu_manager_state")), `"18"`, `"19"`, 1)
+	_, err := checkpoint.ParseCPUManagerState([]byte(data))
+	if !errors.Is(err, checkpoint.ErrCorruptCheckpoint) {
+		t.Fatalf("expected ErrCorruptCheckpoint but got %v", err)
+	}
+	data = strings.Replace(string(readFixture(t, "cpu_manager_state_v1")), `"1-2"`, `"1-3"`, 1)
+	_, err = checkpoint.ParseCPUManagerState([]byte(data))
+	if !errors.Is(err, checkpoint.ErrCorruptCheckpoint) {
+		t.Fatalf("expected ErrCorruptCheckpoint for v1 layout but got %v", err)
+	}
+	_, err = checkpoint.ParseCPUManagerState([]byte(`{"entries": 1}`))
+	if !errors.Is(err, checkpoint.ErrInvalidCheckpoint) {
+		t.Fatalf("expected ErrInvalidCheckpoint but got %v", err)
+	}
+}
+
+func TestParseMemoryManagerState(t *testing.T) {
+	st, err := checkpoint.ParseMemoryManagerState(readFixture(t, "memory_manager_state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.PolicyName != "Static" || len(st.NUMACells) != 2 {
+		t.Fatalf("unexpected memory manager state: %+v", st)
+	}
+	mem := st.NUMACells[0].Memory["memory"]
+	if mem.Total != 64*unit.Gi || mem.Reserved != 4*unit.Gi || mem.Free != 59*unit.Gi {
+		t.Fatalf("unexpected memory table for NUMA cell 0: %+v", mem)
+	}
+	exp := []types.ContainerAssignment{
+		{
+			PodUID:        "5f7e0b1c-8d2a-4f43-9a7e-2c6b9d1e0a11",
+			ContainerName: "app",
+			Memory: []types.MemoryBlock{
+				{NUMACells: []int{0}, Type: "memory", Size: 4 * unit.Gi},
+				{NUMACells: []int{0}, Type: "hugepages-1Gi", Size: 2 * unit.Gi},
+			},
+		},
+	}
+	if !reflect.DeepEqual(st.Assignments, exp) {
+		t.Fatalf("expected assignments %+v but got %+v", exp, st.Assignments)
+	}
+
+	data := strings.Replace(string(readFixture(t, "memory_manager_state")), `"numberOfAssignments": 2`, `"numberOfAssignments": 1`, 1)
+	_, err = checkpoint.ParseMemoryManagerState([]byte(data))
+	if !errors.Is(err, checkpoint.ErrCorruptCheckpoint) {
+		t.Fatalf("expected ErrCorruptCheckpoint but got %v", err)
+	}
+}
+
+func TestReadAndAssignments(t *testing.T) {
+	cpuState, memState, err := checkpoint.Read(testKubeletDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignments := checkpoint.Assignments(cpuState, memState)
+	if len(assignments) != 3 {
+		t.Fatalf("expected 3 container assignments but got %d", len(assignments))
+	}
+	app := assignments[0]
+	if app.ContainerName != "app" || len(app.CPUs) != 4 || len(app.Memory) != 2 {
+		t.Fatalf("expected app container with CPUs and memory but got %+v", app)
+	}
+
+	// Missing checkpoint files are not an error
+	cpuState, memState, err = checkpoint.Read(t.TempDir())
+	if err != nil || cpuState != nil || memState != nil {
+		t.Fatalf("expected no state and no error but got %v, %v, %v", cpuState, memState, err)
+	}
+}
@@ -0,0 +1,146 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/dump"
+
+	"github.com/jaypipes/kwiz/pkg/checkpoint/internal/checksum"
+	"github.com/jaypipes/kwiz/pkg/checkpoint/internal/state"
+	"github.com/jaypipes/kwiz/pkg/cpuset"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// CPUManagerStateFile is the name of the kubelet's CPU manager
+	// checkpoint file in the kubelet's root directory
+	CPUManagerStateFile = "cpu_manager_state"
+)
+
+// CPUManagerState contains the state of the kubelet's CPU manager
+type CPUManagerState struct {
+	// PolicyName is the CPU manager policy that wrote the checkpoint
+	PolicyName string
+	// DefaultCPUSet contains the IDs of the logical CPUs in the shared pool
+	DefaultCPUSet []int
+	// Assignments contains the CPUs exclusively assigned to each container,
+	// sorted by Pod UID and container name (or container ID for the v1
+	// layout)
+	Assignments []types.ContainerAssignment
+}
+
+// ParseCPUManagerState accepts the contents of a kubelet cpu_manager_state
+// checkpoint, in either the v2 layout (entries keyed by Pod UID and
+// container name) or the older v1 layout (entries keyed by container ID),
+// verifies its checksum and returns the CPU manager state.
+func ParseCPUManagerState(data []byte) (*CPUManagerState, error) {
+	v2 := state.CPUManagerCheckpoint{}
+	if err := json.Unmarshal(data, &v2); err == nil {
+		return cpuManagerStateFromV2(&v2)
+	}
+	v1 := state.CPUManagerCheckpointV1{}
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return nil, InvalidCheckpoint(CPUManagerStateFile, err.Error())
+	}
+	return cpuManagerStateFromV1(&v1)
+}
+
+// cpuManagerStateFromV2 verifies the checksum of a v2 CPU manager
+// checkpoint and returns its state
+func cpuManagerStateFromV2(cp *state.CPUManagerCheckpoint) (*CPUManagerState, error) {
+	if cp.Checksum != 0 {
+		ck := cp.Checksum
+		cp.Checksum = 0
+		if checksum.New(cp) != ck {
+			return nil, CorruptCheckpoint(CPUManagerStateFile)
+		}
+		cp.Checksum = ck
+	}
+	res, err := newCPUManagerState(cp.PolicyName, cp.DefaultCPUSet)
+	if err != nil {
+		return nil, err
+	}
+	for podUID, containers := range cp.Entries {
+		for name, cpus := range containers {
+			ids, err := cpuset.Parse(cpus)
+			if err != nil {
+				return nil, InvalidCheckpoint(CPUManagerStateFile, err.Error())
+			}
+			res.Assignments = append(res.Assignments, types.ContainerAssignment{
+				PodUID:        podUID,
+				ContainerName: name,
+				CPUs:          ids,
+			})
+		}
+	}
+	sortAssignments(res.Assignments)
+	return res, nil
+}
+
+// cpuManagerStateFromV1 verifies the checksum of a v1 CPU manager
+// checkpoint and returns its state
+func cpuManagerStateFromV1(cp *state.CPUManagerCheckpointV1) (*CPUManagerState, error) {
+	if cp.Checksum != 0 {
+		ck := cp.Checksum
+		cp.Checksum = 0
+		// The kubelet computed v1 checksums when the type was named
+		// CPUManagerCheckpoint
+		dumped := strings.Replace(
+			dump.ForHash(cp), "CPUManagerCheckpointV1", "CPUManagerCheckpoint", 1,
+		)
+		if checksum.Checksum(checksum.Sum(dumped)) != ck {
+			return nil, CorruptCheckpoint(CPUManagerStateFile)
+		}
+		cp.Checksum = ck
+	}
+	res, err := newCPUManagerState(cp.PolicyName, cp.DefaultCPUSet)
+	if err != nil {
+		return nil, err
+	}
+	for containerID, cpus := range cp.Entries {
+		ids, err := cpuset.Parse(cpus)
+		if err != nil {
+			return nil, InvalidCheckpoint(CPUManagerStateFile, err.Error())
+		}
+		res.Assignments = append(res.Assignments, types.ContainerAssignment{
+			ContainerID: containerID,
+			CPUs:        ids,
+		})
+	}
+	sortAssignments(res.Assignments)
+	return res, nil
+}
+
+// newCPUManagerState returns a CPUManagerState with no assignments
+func newCPUManagerState(policy string, defaultCPUSet string) (*CPUManagerState, error) {
+	cpus, err := cpuset.Parse(defaultCPUSet)
+	if err != nil {
+		return nil, InvalidCheckpoint(CPUManagerStateFile, err.Error())
+	}
+	return &CPUManagerState{
+		PolicyName:    policy,
+		DefaultCPUSet: cpus,
+		Assignments:   []types.ContainerAssignment{},
+	}, nil
+}
+
+// sortAssignments sorts assignments by Pod UID, container name and
+// container ID
+func sortAssignments(assignments []types.ContainerAssignment) {
+	sort.Slice(assignments, func(i, j int) bool {
+		a, b := assignments[i], assignments[j]
+		if a.PodUID != b.PodUID {
+			return a.PodUID < b.PodUID
+		}
+		if a.ContainerName != b.ContainerName {
+			return a.ContainerName < b.ContainerName
+		}
+		return a.ContainerID < b.ContainerID
+	})
+}
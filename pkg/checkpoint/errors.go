@@ -0,0 +1,36 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package checkpoint
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrInvalidCheckpoint is returned when a kubelet checkpoint cannot be
+	// parsed.
+	ErrInvalidCheckpoint = fmt.Errorf(
+		"%w: invalid kubelet checkpoint",
+		kwerrors.RuntimeError,
+	)
+	// ErrCorruptCheckpoint is returned when the checksum of a kubelet
+	// checkpoint does not match its contents.
+	ErrCorruptCheckpoint = fmt.Errorf(
+		"%w: corrupt kubelet checkpoint",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidCheckpoint returns ErrInvalidCheckpoint with some further context
+func InvalidCheckpoint(name string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidCheckpoint, name, reason)
+}
+
+// CorruptCheckpoint returns ErrCorruptCheckpoint with some further context
+func CorruptCheckpoint(name string) error {
+	return fmt.Errorf("%w: %s: checksum mismatch", ErrCorruptCheckpoint, name)
+}
@@ -0,0 +1,30 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+// Package checksum mirrors the kubelet's checkpointmanager/checksum package.
+// The kubelet hashes a dump of each checkpoint's Go value, which includes
+// package-qualified type names, so the package and type names here must
+// match the kubelet's exactly.
+package checksum
+
+import (
+	"hash/fnv"
+
+	"k8s.io/apimachinery/pkg/util/dump"
+)
+
+// Checksum is the checksum of a kubelet checkpoint
+type Checksum uint64
+
+// New returns the Checksum of the supplied checkpoint object
+func New(obj interface{}) Checksum {
+	return Checksum(Sum(dump.ForHash(obj)))
+}
+
+// Sum returns the FNV-32a hash of a dumped checkpoint object
+func Sum(dumped string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(dumped))
+	return hash.Sum32()
+}
@@ -0,0 +1,75 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+// Package state mirrors the checkpoint types of the kubelet's cpumanager and
+// memorymanager state packages. The kubelet hashes a dump of each
+// checkpoint's Go value, which includes package-qualified type names, so the
+// package, type and field names here must match the kubelet's exactly.
+package state
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/jaypipes/kwiz/pkg/checkpoint/internal/checksum"
+)
+
+// CPUManagerCheckpoint is the v2 layout of the cpu_manager_state
+// checkpoint, with entries keyed by Pod UID and container name
+type CPUManagerCheckpoint struct {
+	PolicyName    string                       `json:"policyName"`
+	DefaultCPUSet string                       `json:"defaultCpuSet"`
+	Entries       map[string]map[string]string `json:"entries,omitempty"`
+	Checksum      checksum.Checksum            `json:"checksum"`
+}
+
+// CPUManagerCheckpointV1 is the v1 layout of the cpu_manager_state
+// checkpoint, with entries keyed by container ID. The kubelet computed its
+// checksum when the type was still named CPUManagerCheckpoint.
+type CPUManagerCheckpointV1 struct {
+	PolicyName    string            `json:"policyName"`
+	DefaultCPUSet string            `json:"defaultCpuSet"`
+	Entries       map[string]string `json:"entries,omitempty"`
+	Checksum      checksum.Checksum `json:"checksum"`
+}
+
+// MemoryManagerCheckpoint is the layout of the memory_manager_state
+// checkpoint
+type MemoryManagerCheckpoint struct {
+	PolicyName   string                     `json:"policyName"`
+	MachineState NUMANodeMap                `json:"machineState"`
+	Entries      ContainerMemoryAssignments `json:"entries,omitempty"`
+	Checksum     checksum.Checksum          `json:"checksum"`
+}
+
+// NUMANodeMap contains the memory state of each NUMA node, keyed by NUMA
+// node ID
+type NUMANodeMap map[int]*NUMANodeState
+
+// NUMANodeState contains the memory state of a single NUMA node
+type NUMANodeState struct {
+	NumberOfAssignments int                              `json:"numberOfAssignments"`
+	MemoryMap           map[v1.ResourceName]*MemoryTable `json:"memoryMap"`
+	Cells               []int                            `json:"cells"`
+}
+
+// MemoryTable contains the amounts of a single memory type in a NUMA node
+type MemoryTable struct {
+	TotalMemSize   uint64 `json:"total"`
+	SystemReserved uint64 `json:"systemReserved"`
+	Allocatable    uint64 `json:"allocatable"`
+	Reserved       uint64 `json:"reserved"`
+	Free           uint64 `json:"free"`
+}
+
+// ContainerMemoryAssignments contains the memory blocks assigned to each
+// container, keyed by Pod UID and container name
+type ContainerMemoryAssignments map[string]map[string][]Block
+
+// Block is an amount of a single memory type assigned to a container from a
+// set of NUMA nodes
+type Block struct {
+	NUMAAffinity []int           `json:"numaAffinity"`
+	Type         v1.ResourceName `json:"type"`
+	Size         uint64          `json:"size"`
+}
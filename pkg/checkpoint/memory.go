@@ -0,0 +1,129 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/jaypipes/kwiz/pkg/checkpoint/internal/checksum"
+	"github.com/jaypipes/kwiz/pkg/checkpoint/internal/state"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// MemoryManagerStateFile is the name of the kubelet's memory manager
+	// checkpoint file in the kubelet's root directory
+	MemoryManagerStateFile = "memory_manager_state"
+)
+
+// MemoryManagerState contains the state of the kubelet's memory manager
+type MemoryManagerState struct {
+	// PolicyName is the memory manager policy that wrote the checkpoint
+	PolicyName string
+	// NUMACells contains the memory state of each NUMA cell, sorted by ID
+	NUMACells []NUMACellMemory
+	// Assignments contains the memory exclusively assigned to each
+	// container, sorted by Pod UID and container name
+	Assignments []types.ContainerAssignment
+}
+
+// NUMACellMemory contains the memory manager's state of a single NUMA cell
+type NUMACellMemory struct {
+	// ID is the NUMA cell ID
+	ID int
+	// Cells contains the IDs of the NUMA cells whose memory is assigned
+	// together with this cell's to the same containers
+	Cells []int
+	// Assignments is the number of containers assigned memory from the cell
+	Assignments int
+	// Memory contains the amounts of each type of memory in the cell, keyed
+	// by type, e.g. "memory" or "hugepages-1Gi"
+	Memory map[string]MemoryTable
+}
+
+// MemoryTable contains the memory manager's accounting of a single type of
+// memory in a NUMA cell, in bytes
+type MemoryTable struct {
+	// Total is the total amount of memory
+	Total float64
+	// SystemReserved is the amount reserved for the system
+	SystemReserved float64
+	// Allocatable is the amount that may be assigned to containers
+	Allocatable float64
+	// Reserved is the amount assigned to containers
+	Reserved float64
+	// Free is the amount of allocatable memory not assigned to containers
+	Free float64
+}
+
+// ParseMemoryManagerState accepts the contents of a kubelet
+// memory_manager_state checkpoint, verifies its checksum and returns the
+// memory manager state.
+func ParseMemoryManagerState(data []byte) (*MemoryManagerState, error) {
+	cp := state.MemoryManagerCheckpoint{}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, InvalidCheckpoint(MemoryManagerStateFile, err.Error())
+	}
+	if cp.Checksum != 0 {
+		ck := cp.Checksum
+		cp.Checksum = 0
+		if checksum.New(&cp) != ck {
+			return nil, CorruptCheckpoint(MemoryManagerStateFile)
+		}
+		cp.Checksum = ck
+	}
+	res := &MemoryManagerState{
+		PolicyName:  cp.PolicyName,
+		NUMACells:   []NUMACellMemory{},
+		Assignments: []types.ContainerAssignment{},
+	}
+	for id, ns := range cp.MachineState {
+		if ns == nil {
+			continue
+		}
+		cell := NUMACellMemory{
+			ID:          id,
+			Cells:       ns.Cells,
+			Assignments: ns.NumberOfAssignments,
+			Memory:      map[string]MemoryTable{},
+		}
+		for memType, table := range ns.MemoryMap {
+			if table == nil {
+				continue
+			}
+			cell.Memory[string(memType)] = MemoryTable{
+				Total:          float64(table.TotalMemSize),
+				SystemReserved: float64(table.SystemReserved),
+				Allocatable:    float64(table.Allocatable),
+				Reserved:       float64(table.Reserved),
+				Free:           float64(table.Free),
+			}
+		}
+		res.NUMACells = append(res.NUMACells, cell)
+	}
+	sort.Slice(res.NUMACells, func(i, j int) bool {
+		return res.NUMACells[i].ID < res.NUMACells[j].ID
+	})
+	for podUID, containers := range cp.Entries {
+		for name, blocks := range containers {
+			a := types.ContainerAssignment{
+				PodUID:        podUID,
+				ContainerName: name,
+				Memory:        make([]types.MemoryBlock, len(blocks)),
+			}
+			for x, b := range blocks {
+				a.Memory[x] = types.MemoryBlock{
+					NUMACells: b.NUMAAffinity,
+					Type:      string(b.Type),
+					Size:      float64(b.Size),
+				}
+			}
+			res.Assignments = append(res.Assignments, a)
+		}
+	}
+	sortAssignments(res.Assignments)
+	return res, nil
+}
@@ -0,0 +1,89 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cost
+
+import (
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+// ForNode returns the hourly `Cost` of the supplied Node.
+//
+// A Node's price is split between its CPU and memory according to the
+// Prices' CPUWeight. The cost of an amount of a resource is then the
+// resource's share of the price multiplied by the amount's fraction of the
+// resource's capacity.
+func (p *Prices) ForNode(n *types.Node) types.Cost {
+	hourly, ok := p.NodeHourly(n)
+	if !ok {
+		return types.Cost{}
+	}
+	cpu := n.Resources.CPU
+	mem := n.Resources.Memory
+	reqCPU := min(cpu.RequestedFloor, cpu.Allocatable)
+	reqMem := min(mem.RequestedFloor, mem.Allocatable)
+	c := types.Cost{
+		Hourly:      hourly,
+		Reserved:    p.price(hourly, n, cpu.Reserved, mem.Reserved),
+		Allocatable: p.price(hourly, n, cpu.Allocatable, mem.Allocatable),
+		Requested:   p.price(hourly, n, reqCPU, reqMem),
+	}
+	c.Idle = c.Allocatable - c.Requested
+	return c
+}
+
+// ForPod returns the hourly cost of the request floor of the supplied Pod
+// when running on the supplied Node.
+func (p *Prices) ForPod(pod *types.Pod, n *types.Node) float64 {
+	hourly, ok := p.NodeHourly(n)
+	if !ok {
+		return 0
+	}
+	return p.price(
+		hourly, n,
+		max(pod.ResourceRequests.CPU.Floor, 0),
+		max(pod.ResourceRequests.Memory.Floor, 0),
+	)
+}
+
+// ByNamespace returns a map, keyed by namespace, of the hourly cost of the
+// request floor of all supplied Pods that are running on one of the supplied
+// Nodes.
+func (p *Prices) ByNamespace(
+	nodes []*types.Node,
+	pods []*types.Pod,
+) map[string]float64 {
+	byName := make(map[string]*types.Node, len(nodes))
+	for _, n := range nodes {
+		byName[n.Name] = n
+	}
+	res := map[string]float64{}
+	for _, pod := range pods {
+		n, ok := byName[pod.Node]
+		if !ok {
+			continue
+		}
+		res[pod.Namespace] += p.ForPod(pod, n)
+	}
+	return res
+}
+
+// price returns the portion of a Node's hourly price that is attributed to
+// the supplied amounts of CPU and memory.
+func (p *Prices) price(
+	hourly float64,
+	n *types.Node,
+	cpu float64,
+	mem float64,
+) float64 {
+	w := p.cpuWeight()
+	res := float64(0)
+	if n.Resources.CPU.Capacity > 0 {
+		res += hourly * w * (cpu / n.Resources.CPU.Capacity)
+	}
+	if n.Resources.Memory.Capacity > 0 {
+		res += hourly * (1 - w) * (mem / n.Resources.Memory.Capacity)
+	}
+	return res
+}
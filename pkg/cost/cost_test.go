@@ -0,0 +1,144 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cost_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/cost"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func floatEquals(a, b float64) bool {
+	return math.Abs(a-b) < 0.000001
+}
+
+func TestNodeHourly(t *testing.T) {
+	p := &cost.Prices{
+		Default: 0.1,
+		InstanceTypes: map[string]float64{
+			"m5.xlarge": 0.2,
+		},
+		Labels: []cost.LabelPrice{
+			{Key: "node-pool", Value: "gpu", Hourly: 2.5},
+		},
+	}
+
+	tcs := []struct {
+		node *types.Node
+		exp  float64
+	}{
+		{&types.Node{Name: "a", InstanceType: "m5.xlarge"}, 0.2},
+		{&types.Node{Name: "b", InstanceType: "unknown"}, 0.1},
+		{
+			&types.Node{
+				Name:         "gpu",
+				InstanceType: "m5.xlarge",
+				Labels:       map[string]string{"node-pool": "gpu"},
+			},
+			2.5,
+		},
+	}
+
+	for _, tc := range tcs {
+		got, ok := p.NodeHourly(tc.node)
+		if !ok {
+			t.Fatalf("expected price for node %s", tc.node.Name)
+		}
+		if got != tc.exp {
+			t.Fatalf("expected %.2f but got %.2f", tc.exp, got)
+		}
+	}
+
+	p.Default = 0
+	if _, ok := p.NodeHourly(&types.Node{Name: "c", InstanceType: "unknown"}); ok {
+		t.Fatalf("expected no price for unknown instance type")
+	}
+}
+
+func TestForNode(t *testing.T) {
+	p := &cost.Prices{
+		InstanceTypes: map[string]float64{
+			"m5.xlarge": 1.0,
+		},
+	}
+	got := p.ForNode(&types.Node{
+		Name:         "a",
+		InstanceType: "m5.xlarge",
+		Resources: types.Resources{
+			CPU: types.ResourceAmounts{
+				Capacity:       4,
+				Allocatable:    3,
+				Reserved:       1,
+				RequestedFloor: 2,
+			},
+			Memory: types.ResourceAmounts{
+				Capacity:       16 * unit.Gi,
+				Allocatable:    12 * unit.Gi,
+				Reserved:       4 * unit.Gi,
+				RequestedFloor: 6 * unit.Gi,
+			},
+		},
+	})
+
+	// CPU and memory are each 25% reserved, 75% allocatable. Requested is
+	// 50% of CPU capacity and 37.5% of memory capacity.
+	exp := types.Cost{
+		Hourly:      1.0,
+		Reserved:    0.25,
+		Allocatable: 0.75,
+		Requested:   0.4375,
+		Idle:        0.3125,
+	}
+	if !floatEquals(got.Hourly, exp.Hourly) ||
+		!floatEquals(got.Reserved, exp.Reserved) ||
+		!floatEquals(got.Allocatable, exp.Allocatable) ||
+		!floatEquals(got.Requested, exp.Requested) ||
+		!floatEquals(got.Idle, exp.Idle) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+}
+
+func TestByNamespace(t *testing.T) {
+	p := &cost.Prices{Default: 1.0}
+	nodes := []*types.Node{
+		{
+			Name: "a",
+			Resources: types.Resources{
+				CPU: types.ResourceAmounts{
+					Capacity:    4,
+					Allocatable: 3,
+				},
+				Memory: types.ResourceAmounts{
+					Capacity:    16 * unit.Gi,
+					Allocatable: 12 * unit.Gi,
+				},
+			},
+		},
+	}
+	pods := []*types.Pod{
+		{
+			Node:      "a",
+			Namespace: "web",
+			ResourceRequests: types.ResourceRequests{
+				CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+				Memory: types.ResourceRequest{Floor: 4 * unit.Gi, Ceiling: -1},
+			},
+		},
+		{
+			// Pending Pods are not on any Node and cost nothing
+			Namespace: "web",
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 1, Ceiling: -1},
+			},
+		},
+	}
+	got := p.ByNamespace(nodes, pods)
+	if !floatEquals(got["web"], 0.25) {
+		t.Fatalf("expected 0.25 but got %f", got["web"])
+	}
+}
@@ -0,0 +1,25 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cost
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrInvalidPrices is returned when a prices file cannot be parsed or
+	// contains invalid values.
+	ErrInvalidPrices = fmt.Errorf(
+		"%w: invalid prices",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidPrices returns ErrInvalidPrices with some further context
+func InvalidPrices(path string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidPrices, path, reason)
+}
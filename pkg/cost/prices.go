@@ -0,0 +1,102 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cost
+
+import (
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// defaultCPUWeight is the fraction of a Node's price attributed to CPU
+	// when the prices file does not specify one.
+	defaultCPUWeight = 0.5
+)
+
+// Prices maps the shape of a Node to an hourly price. It is typically loaded
+// from a local YAML or JSON file, for example:
+//
+//	currency: USD
+//	cpuWeight: 0.6
+//	default: 0.10
+//	instanceTypes:
+//	  m5.xlarge: 0.192
+//	  m5.4xlarge: 0.768
+//	labels:
+//	- key: node-pool
+//	  value: gpu
+//	  hourly: 2.48
+type Prices struct {
+	// Currency is an informational currency code (e.g. "USD")
+	Currency string `json:"currency,omitempty"`
+	// CPUWeight is the fraction (0.0 to 1.0) of a Node's price that is
+	// attributed to its CPU. The remainder is attributed to its memory. If
+	// nil, 0.5 is used.
+	CPUWeight *float64 `json:"cpuWeight,omitempty"`
+	// Default is the hourly price of a Node that matches no other entry
+	Default float64 `json:"default,omitempty"`
+	// InstanceTypes maps a Node's instance type to its hourly price
+	InstanceTypes map[string]float64 `json:"instanceTypes,omitempty"`
+	// Labels contains hourly prices for Nodes having a specific label. Labels
+	// are evaluated in order and take precedence over InstanceTypes.
+	Labels []LabelPrice `json:"labels,omitempty"`
+}
+
+// LabelPrice is the hourly price of Nodes having a label with a specific
+// value
+type LabelPrice struct {
+	// Key is the label key
+	Key string `json:"key"`
+	// Value is the label value
+	Value string `json:"value"`
+	// Hourly is the hourly price of a matching Node
+	Hourly float64 `json:"hourly"`
+}
+
+// Load reads a prices file at the supplied path and returns a `Prices`
+func Load(path string) (*Prices, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Prices{}
+	if err = yaml.Unmarshal(b, p); err != nil {
+		return nil, InvalidPrices(path, err.Error())
+	}
+	if p.CPUWeight != nil && (*p.CPUWeight < 0 || *p.CPUWeight > 1) {
+		return nil, InvalidPrices(path, "cpuWeight must be between 0 and 1")
+	}
+	return p, nil
+}
+
+// NodeHourly returns the hourly price of the supplied Node and whether a
+// price was found for it.
+func (p *Prices) NodeHourly(n *types.Node) (float64, bool) {
+	for _, lp := range p.Labels {
+		if v, ok := n.Labels[lp.Key]; ok && v == lp.Value {
+			return lp.Hourly, true
+		}
+	}
+	if n.InstanceType != "" {
+		if hourly, ok := p.InstanceTypes[n.InstanceType]; ok {
+			return hourly, true
+		}
+	}
+	if p.Default > 0 {
+		return p.Default, true
+	}
+	return 0, false
+}
+
+// cpuWeight returns the fraction of a Node's price attributed to CPU
+func (p *Prices) cpuWeight() float64 {
+	if p.CPUWeight == nil {
+		return defaultCPUWeight
+	}
+	return *p.CPUWeight
+}
@@ -0,0 +1,84 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cpuset
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrInvalidCPUSet is returned when a cpuset string cannot be parsed.
+	ErrInvalidCPUSet = fmt.Errorf(
+		"%w: invalid cpuset",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidCPUSet returns ErrInvalidCPUSet with some further context
+func InvalidCPUSet(s string, reason string) error {
+	return fmt.Errorf("%w: %q: %s", ErrInvalidCPUSet, s, reason)
+}
+
+// Parse accepts a cpuset string in the Linux list format used by the kernel
+// and the kubelet, e.g. "0-3,8,10-11", and returns the sorted, unique CPU
+// IDs in the set. An empty string is an empty set.
+func Parse(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return []int{}, nil
+	}
+	seen := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, InvalidCPUSet(s, fmt.Sprintf("invalid CPU ID %q", first))
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < start {
+				return nil, InvalidCPUSet(s, fmt.Sprintf("invalid CPU range %q", part))
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			seen[cpu] = true
+		}
+	}
+	res := make([]int, 0, len(seen))
+	for cpu := range seen {
+		res = append(res, cpu)
+	}
+	sort.Ints(res)
+	return res, nil
+}
+
+// String accepts a slice of CPU IDs and returns the cpuset string in the
+// Linux list format, with consecutive IDs collapsed into ranges, e.g.
+// "0-3,8,10-11".
+func String(cpus []int) string {
+	sorted := append([]int{}, cpus...)
+	sort.Ints(sorted)
+	parts := []string{}
+	for x := 0; x < len(sorted); {
+		start := sorted[x]
+		end := start
+		for x++; x < len(sorted) && sorted[x] <= end+1; x++ {
+			end = sorted[x]
+		}
+		if start == end {
+			parts = append(parts, strconv.Itoa(start))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", start, end))
+		}
+	}
+	return strings.Join(parts, ",")
+}
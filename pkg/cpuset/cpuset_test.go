@@ -0,0 +1,63 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package cpuset_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/cpuset"
+)
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		val string
+		exp []int
+	}{
+		{"", []int{}},
+		{"0", []int{0}},
+		{"0-3", []int{0, 1, 2, 3}},
+		{"0,32", []int{0, 32}},
+		{"8,0-2, 10-11", []int{0, 1, 2, 8, 10, 11}},
+		{"1-2,2-3", []int{1, 2, 3}},
+	}
+
+	for _, tc := range tcs {
+		got, err := cpuset.Parse(tc.val)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.val, err)
+		}
+		if !slices.Equal(got, tc.exp) {
+			t.Fatalf("expected %v for %q but got %v", tc.exp, tc.val, got)
+		}
+	}
+
+	for _, val := range []string{"a", "3-1", "1-", "-1", "1,,2"} {
+		if _, err := cpuset.Parse(val); !errors.Is(err, cpuset.ErrInvalidCPUSet) {
+			t.Fatalf("expected ErrInvalidCPUSet for %q but got %v", val, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tcs := []struct {
+		val []int
+		exp string
+	}{
+		{[]int{}, ""},
+		{[]int{0}, "0"},
+		{[]int{3, 2, 1, 0}, "0-3"},
+		{[]int{0, 1, 2, 8, 10, 11}, "0-2,8,10-11"},
+		{[]int{0, 32}, "0,32"},
+	}
+
+	for _, tc := range tcs {
+		got := cpuset.String(tc.val)
+		if got != tc.exp {
+			t.Fatalf("expected %q for %v but got %q", tc.exp, tc.val, got)
+		}
+	}
+}
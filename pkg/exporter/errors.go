@@ -0,0 +1,28 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package exporter
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrLabelNameCollision is returned when two different Node label keys
+	// would be exposed as the same metric label name.
+	ErrLabelNameCollision = fmt.Errorf(
+		"%w: Node label keys collide",
+		kwerrors.RuntimeError,
+	)
+)
+
+// LabelNameCollision returns ErrLabelNameCollision with some further context
+func LabelNameCollision(key string, other string, name string) error {
+	return fmt.Errorf(
+		"%w: %q and %q are both exposed as %s",
+		ErrLabelNameCollision, key, other, name,
+	)
+}
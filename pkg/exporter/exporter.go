@@ -0,0 +1,320 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// ContentType is the content type of the Prometheus text exposition
+	// format written by Write
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// labelPrefix is prepended to the sanitized key of each Node label
+	// exposed as a metric label, as kube-state-metrics does
+	labelPrefix = "label_"
+)
+
+// family describes a gauge metric family and how to read its value from a
+// single resource's amounts
+type family struct {
+	name  string
+	help  string
+	value func(types.ResourceAmounts) float64
+}
+
+var families = []family{
+	{
+		name: "capacity",
+		help: "Total amount of the resource.",
+		value: func(a types.ResourceAmounts) float64 {
+			return a.Capacity
+		},
+	},
+	{
+		name: "allocatable",
+		help: "Amount of the resource that may be allocated to Pods.",
+		value: func(a types.ResourceAmounts) float64 {
+			return a.Allocatable
+		},
+	},
+	{
+		name: "reserved",
+		help: "Amount of the resource reserved for the system.",
+		value: func(a types.ResourceAmounts) float64 {
+			return a.Reserved
+		},
+	},
+	{
+		name: "request_floor",
+		help: "Amount of the resource requested by Pods.",
+		value: func(a types.ResourceAmounts) float64 {
+			return a.RequestedFloor
+		},
+	},
+	{
+		name: "request_ceiling",
+		help: "Maximum amount of the resource Pods may consume according to their limits. Equal to allocatable if any Pod has no limit.",
+		value: func(a types.ResourceAmounts) float64 {
+			// If any Pod has no limits, that means it can consume all of
+			// the resource...
+			if a.RequestedCeiling == -1 {
+				return a.Allocatable
+			}
+			return a.RequestedCeiling
+		},
+	},
+	{
+		name: "used",
+		help: "Amount of the resource actually being consumed.",
+		value: func(a types.ResourceAmounts) float64 {
+			return a.Used
+		},
+	},
+}
+
+// Options controls the labels of the written metrics
+type Options struct {
+	// Cluster is the value of the cluster label of every metric
+	Cluster string
+	// LabelKeys contains the keys of the Node labels to add to every metric
+	// as "label_<key>", with non-alphanumeric characters of the key replaced
+	// by underscores, e.g. "label_node_kubernetes_io_instance_type".
+	LabelKeys []string
+}
+
+// Validate returns ErrLabelNameCollision if two different LabelKeys would be
+// exposed as the same metric label name
+func (o Options) Validate() error {
+	keys := map[string]string{}
+	for _, key := range o.LabelKeys {
+		name := LabelName(key)
+		if other, ok := keys[name]; ok && other != key {
+			return LabelNameCollision(other, key, name)
+		}
+		keys[name] = key
+	}
+	return nil
+}
+
+// series is a single sample of a metric family
+type series struct {
+	labels string
+	value  float64
+}
+
+// Write writes gauges of the capacity, allocatable, reserved, requested
+// floor, requested ceiling and used amount of each resource of the supplied
+// Nodes, and of CPU and memory of their NUMA cells, to the supplied writer
+// in the Prometheus text exposition format. CPU is in cores and memory in
+// bytes. Node metrics are named "kwiz_node_<family>" and NUMA cell metrics
+// "kwiz_numa_cell_<family>". Unknown (-1) amounts, such as the usage of a
+// Node no kwiz agent reports on, are omitted.
+func Write(w io.Writer, nodes []*types.Node, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	nodeSeries := make([][]series, len(families))
+	cellSeries := make([][]series, len(families))
+	for _, node := range nodes {
+		base := baseLabels(node, opts)
+		for _, r := range []struct {
+			name    string
+			amounts types.ResourceAmounts
+		}{
+			{"cpu", node.Resources.CPU},
+			{"memory", node.Resources.Memory},
+			{"pods", node.Resources.Pods},
+		} {
+			labels := labelsString(append(base, [2]string{"resource", r.name}))
+			for x, f := range families {
+				nodeSeries[x] = appendKnown(nodeSeries[x], labels, f.value(r.amounts))
+			}
+		}
+		for _, cell := range node.NUMACells {
+			for _, r := range []struct {
+				name    string
+				amounts types.ResourceAmounts
+			}{
+				{"cpu", cell.Resources.CPU},
+				{"memory", cell.Resources.Memory},
+			} {
+				labels := labelsString(append(
+					base,
+					[2]string{"numa_cell", strconv.Itoa(cell.ID)},
+					[2]string{"resource", r.name},
+				))
+				for x, f := range families {
+					cellSeries[x] = appendKnown(cellSeries[x], labels, f.value(r.amounts))
+				}
+			}
+		}
+	}
+	for x, f := range families {
+		if err := writeFamily(w, "kwiz_node_"+f.name, "Node: "+f.help, nodeSeries[x]); err != nil {
+			return err
+		}
+	}
+	for x, f := range families {
+		if err := writeFamily(w, "kwiz_numa_cell_"+f.name, "NUMA cell: "+f.help, cellSeries[x]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendKnown appends a sample with the supplied labels and value to the
+// supplied samples, unless the value is unknown (-1)
+func appendKnown(samples []series, labels string, value float64) []series {
+	if value == -1 {
+		return samples
+	}
+	return append(samples, series{labels, value})
+}
+
+// writeFamily writes the HELP and TYPE lines and the samples of a single
+// gauge metric family
+func writeFamily(w io.Writer, name string, help string, samples []series) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if _, err := fmt.Fprintf(w, "%s{%s} %s\n", name, s.labels, formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baseLabels returns the name/value pairs of the labels common to every
+// metric of the supplied Node
+func baseLabels(node *types.Node, opts Options) [][2]string {
+	res := [][2]string{
+		{"cluster", opts.Cluster},
+		{"node", node.Name},
+	}
+	keys := append([]string{}, opts.LabelKeys...)
+	sort.Strings(keys)
+	// A key given more than once is only exposed once
+	keys = slices.Compact(keys)
+	for _, key := range keys {
+		res = append(res, [2]string{LabelName(key), node.Labels[key]})
+	}
+	return res
+}
+
+// labelsString returns the supplied label name/value pairs formatted for
+// the text exposition format, without the surrounding braces
+func labelsString(labels [][2]string) string {
+	var b strings.Builder
+	for x, l := range labels {
+		if x > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l[0])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(l[1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// LabelName returns the metric label name the supplied Node label key is
+// exposed as
+func LabelName(key string) string {
+	var b strings.Builder
+	b.WriteString(labelPrefix)
+	for _, r := range key {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// escapeLabelValue escapes backslashes, double quotes and newlines in a
+// label value as the text exposition format requires
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatValue formats a sample value for the text exposition format
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Exporter is an http.Handler serving the metrics written by Write for the
+// Nodes of its most recent refresh, along with the time of the last
+// successful refresh and the number of failed refreshes
+type Exporter struct {
+	opts          Options
+	mu            sync.RWMutex
+	body          []byte
+	lastRefresh   time.Time
+	refreshErrors int
+}
+
+// New returns a new Exporter labelling metrics according to the supplied
+// Options
+func New(opts Options) *Exporter {
+	return &Exporter{opts: opts}
+}
+
+// Update replaces the metrics served by the Exporter with those of the
+// supplied Nodes
+func (e *Exporter) Update(nodes []*types.Node) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, nodes, e.opts); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.body = buf.Bytes()
+	e.lastRefresh = time.Now()
+	return nil
+}
+
+// RecordError counts a failed refresh. The Exporter keeps serving the
+// metrics of the last successful refresh.
+func (e *Exporter) RecordError() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refreshErrors++
+}
+
+// ServeHTTP implements http.Handler
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	w.Header().Set("Content-Type", ContentType)
+	w.Write(e.body)
+	lastRefresh := float64(0)
+	if !e.lastRefresh.IsZero() {
+		lastRefresh = float64(e.lastRefresh.UnixNano()) / float64(time.Second)
+	}
+	fmt.Fprintf(
+		w,
+		"# HELP kwiz_last_refresh_timestamp_seconds Time of the last successful refresh of the metrics.\n"+
+			"# TYPE kwiz_last_refresh_timestamp_seconds gauge\n"+
+			"kwiz_last_refresh_timestamp_seconds %s\n"+
+			"# HELP kwiz_refresh_errors_total Number of failed refreshes of the metrics.\n"+
+			"# TYPE kwiz_refresh_errors_total counter\n"+
+			"kwiz_refresh_errors_total %d\n",
+		formatValue(lastRefresh), e.refreshErrors,
+	)
+}
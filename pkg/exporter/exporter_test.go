@@ -0,0 +1,170 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package exporter_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/exporter"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func TestWrite(t *testing.T) {
+	labels := `cluster="prod",node="worker-0",label_node_kubernetes_io_instance_type="m5.\"large\"",label_team=""`
+	tcs := []struct {
+		name  string
+		node  *types.Node
+		exp   []string
+		unexp []string
+	}{
+		{
+			name: "known",
+			node: &types.Node{
+				Name:   "worker-0",
+				Labels: map[string]string{"node.kubernetes.io/instance-type": `m5."large"`},
+				Resources: types.Resources{
+					CPU: types.ResourceAmounts{
+						Capacity: 4, Allocatable: 3.5, Reserved: 0.5,
+						RequestedFloor: 1.25, RequestedCeiling: -1, Used: 0.75,
+					},
+					Memory: types.ResourceAmounts{
+						Capacity: 16 * unit.Gi, Allocatable: 15 * unit.Gi, Reserved: unit.Gi,
+						RequestedFloor: 2 * unit.Gi, RequestedCeiling: 4 * unit.Gi,
+					},
+					Pods: types.ResourceAmounts{Capacity: 110, Allocatable: 110, RequestedFloor: 7, RequestedCeiling: 7, Used: 7},
+				},
+				NUMACells: []types.NUMACell{
+					{
+						ID: 1,
+						Resources: types.Resources{
+							CPU: types.ResourceAmounts{Capacity: 2, Allocatable: 2, RequestedFloor: 1, RequestedCeiling: 2},
+						},
+					},
+				},
+			},
+			exp: []string{
+				"# TYPE kwiz_node_capacity gauge\n",
+				`kwiz_node_capacity{` + labels + `,resource="cpu"} 4` + "\n",
+				`kwiz_node_allocatable{` + labels + `,resource="memory"} 16106127360` + "\n",
+				`kwiz_node_reserved{` + labels + `,resource="cpu"} 0.5` + "\n",
+				`kwiz_node_request_floor{` + labels + `,resource="pods"} 7` + "\n",
+				// An unbounded ceiling is reported as the allocatable amount
+				`kwiz_node_request_ceiling{` + labels + `,resource="cpu"} 3.5` + "\n",
+				`kwiz_node_used{` + labels + `,resource="cpu"} 0.75` + "\n",
+				`kwiz_numa_cell_request_floor{` + labels + `,numa_cell="1",resource="cpu"} 1` + "\n",
+				`kwiz_numa_cell_request_ceiling{` + labels + `,numa_cell="1",resource="memory"} 0` + "\n",
+			},
+		},
+		{
+			name: "unknown",
+			node: &types.Node{
+				Name:   "worker-0",
+				Labels: map[string]string{"node.kubernetes.io/instance-type": `m5."large"`},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Capacity: 4, Used: 0.75},
+					Memory: types.ResourceAmounts{Capacity: 16 * unit.Gi, Used: -1},
+				},
+				NUMACells: []types.NUMACell{
+					{
+						ID: 1,
+						Resources: types.Resources{
+							CPU: types.ResourceAmounts{Capacity: 2, RequestedFloor: -1},
+						},
+					},
+				},
+			},
+			exp: []string{
+				`kwiz_node_used{` + labels + `,resource="cpu"} 0.75` + "\n",
+			},
+			unexp: []string{
+				`kwiz_node_used{` + labels + `,resource="memory"}`,
+				`kwiz_numa_cell_request_floor{` + labels + `,numa_cell="1",resource="cpu"}`,
+				" -1\n",
+			},
+		},
+	}
+	opts := exporter.Options{
+		Cluster:   "prod",
+		LabelKeys: []string{"node.kubernetes.io/instance-type", "team"},
+	}
+	for _, tc := range tcs {
+		var buf bytes.Buffer
+		if err := exporter.Write(&buf, []*types.Node{tc.node}, opts); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tc.name, err)
+		}
+		out := buf.String()
+		for _, exp := range tc.exp {
+			if !strings.Contains(out, exp) {
+				t.Fatalf("%s: expected output to contain %q but got:\n%s", tc.name, exp, out)
+			}
+		}
+		for _, unexp := range tc.unexp {
+			if strings.Contains(out, unexp) {
+				t.Fatalf("%s: expected output not to contain %q but got:\n%s", tc.name, unexp, out)
+			}
+		}
+		if strings.Count(out, "# TYPE kwiz_node_used gauge") != 1 {
+			t.Fatalf("%s: expected a single TYPE line per metric family but got:\n%s", tc.name, out)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := exporter.Options{LabelKeys: []string{"team", "team"}}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("expected a repeated key to be valid but got %s", err)
+	}
+	var buf bytes.Buffer
+	if err := exporter.Write(&buf, []*types.Node{{Name: "worker-0", Resources: types.Resources{CPU: types.ResourceAmounts{Capacity: 4}}}}, opts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Count(buf.String(), `kwiz_node_capacity{cluster="",node="worker-0",label_team="",resource="cpu"}`) != 1 {
+		t.Fatalf("expected a repeated key to be exposed once but got:\n%s", buf.String())
+	}
+	opts = exporter.Options{LabelKeys: []string{"example.com/team", "example.com_team"}}
+	if err := opts.Validate(); !errors.Is(err, exporter.ErrLabelNameCollision) {
+		t.Fatalf("expected ErrLabelNameCollision but got %v", err)
+	}
+}
+
+func TestExporterServeHTTP(t *testing.T) {
+	e := exporter.New(exporter.Options{Cluster: "prod"})
+	e.RecordError()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	if strings.Contains(out, "kwiz_node_capacity") {
+		t.Fatalf("expected no Node metrics before the first refresh but got:\n%s", out)
+	}
+	for _, exp := range []string{
+		"kwiz_last_refresh_timestamp_seconds 0\n",
+		"kwiz_refresh_errors_total 1\n",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected output to contain %q but got:\n%s", exp, out)
+		}
+	}
+
+	if err := e.Update([]*types.Node{{Name: "worker-0", Resources: types.Resources{CPU: types.ResourceAmounts{Capacity: 4}}}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out = rec.Body.String()
+	if ct := rec.Header().Get("Content-Type"); ct != exporter.ContentType {
+		t.Fatalf("expected content type %q but got %q", exporter.ContentType, ct)
+	}
+	if !strings.Contains(out, `kwiz_node_capacity{cluster="prod",node="worker-0",resource="cpu"} 4`) {
+		t.Fatalf("expected Node metrics after a refresh but got:\n%s", out)
+	}
+	if strings.Contains(out, "kwiz_last_refresh_timestamp_seconds 0\n") {
+		t.Fatalf("expected the last refresh time to be set but got:\n%s", out)
+	}
+}
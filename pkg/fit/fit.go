@@ -0,0 +1,313 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package fit
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// ReasonInsufficientCPU means the Node does not have enough unrequested
+	// allocatable CPU for the Pod
+	ReasonInsufficientCPU = "Insufficient cpu"
+	// ReasonInsufficientMemory means the Node does not have enough
+	// unrequested allocatable memory for the Pod
+	ReasonInsufficientMemory = "Insufficient memory"
+	// ReasonTooManyPods means the Node is already running its maximum
+	// number of Pods
+	ReasonTooManyPods = "Too many pods"
+	// ReasonNodeSelectorMismatch means the Node does not have all the labels
+	// in the Pod's node selector
+	ReasonNodeSelectorMismatch = "didn't match Pod's node selector"
+	// ReasonNodeAffinityMismatch means the Node does not match any of the
+	// terms of the Pod's required node affinity
+	ReasonNodeAffinityMismatch = "didn't match Pod's node affinity"
+	// ReasonNoNUMACellFits means no single NUMA cell on the Node has enough
+	// unrequested CPU and memory for the Pod
+	ReasonNoNUMACellFits = "no single NUMA cell has room"
+)
+
+// Check returns the reasons the supplied Pod cannot be scheduled to the
+// supplied Node. An empty slice means the Pod fits on the Node.
+//
+// Only the Pod's request floor is considered, as that is what the scheduler
+// uses. The NUMA cell check is only done for Guaranteed Pods, if the Node's
+// NUMA cells are known and its kubelet's Topology Manager policy is
+// restricted or single-numa-node, as only then may the kubelet reject a Pod
+// whose resources cannot be aligned to a NUMA cell.
+func Check(pod *types.Pod, node *types.Node) []string {
+	reasons := []string{}
+	for key, val := range pod.NodeSelector {
+		if nodeVal, ok := node.Labels[key]; !ok || nodeVal != val {
+			reasons = append(reasons, ReasonNodeSelectorMismatch)
+			break
+		}
+	}
+	if !MatchesNodeAffinity(pod.RequiredNodeAffinity, node) {
+		reasons = append(reasons, ReasonNodeAffinityMismatch)
+	}
+	for _, taint := range node.Taints {
+		// PreferNoSchedule taints do not prevent scheduling
+		if taint.Effect != "NoSchedule" && taint.Effect != "NoExecute" {
+			continue
+		}
+		if !Tolerates(pod.Tolerations, taint) {
+			reasons = append(reasons, fmt.Sprintf(
+				"had untolerated taint {%s: %s}", taint.Key, taint.Value,
+			))
+		}
+	}
+	res := node.Resources
+	reqs := pod.ResourceRequests
+	if reqs.CPU.Floor > Free(res.CPU) {
+		reasons = append(reasons, ReasonInsufficientCPU)
+	}
+	if reqs.Memory.Floor > Free(res.Memory) {
+		reasons = append(reasons, ReasonInsufficientMemory)
+	}
+	if Free(res.Pods) < 1 {
+		reasons = append(reasons, ReasonTooManyPods)
+	}
+	if pod.QOSClass == types.QOSGuaranteed && alignsToNUMACell(node) &&
+		FreeKnown(node.NUMACells) && !FitsSingleNUMACell(reqs, node.NUMACells) {
+		reasons = append(reasons, ReasonNoNUMACellFits)
+	}
+	return reasons
+}
+
+// alignsToNUMACell returns true if the supplied Node's kubelet is known to
+// reject Pods whose resources cannot be aligned to a NUMA cell
+func alignsToNUMACell(node *types.Node) bool {
+	if node.KubeletConfig == nil {
+		return false
+	}
+	switch node.KubeletConfig.TopologyManagerPolicy {
+	case "restricted", "single-numa-node":
+		return true
+	}
+	return false
+}
+
+// MatchesNodeAffinity returns true if the supplied Node matches at least one
+// of the supplied terms of a Pod's required node affinity, or there are no
+// terms
+func MatchesNodeAffinity(terms []types.NodeSelectorTerm, node *types.Node) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	fields := map[string]string{"metadata.name": node.Name}
+	for _, term := range terms {
+		// An empty term matches no Nodes
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if matchesRequirements(term.MatchExpressions, node.Labels) &&
+			matchesRequirements(term.MatchFields, fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesRequirements returns true if the supplied label or field values
+// match all of the supplied node selector requirements
+func matchesRequirements(
+	reqs []types.NodeSelectorRequirement,
+	values map[string]string,
+) bool {
+	for _, req := range reqs {
+		val, ok := values[req.Key]
+		switch req.Operator {
+		case "In":
+			if !ok || !slices.Contains(req.Values, val) {
+				return false
+			}
+		case "NotIn":
+			if ok && slices.Contains(req.Values, val) {
+				return false
+			}
+		case "Exists":
+			if !ok {
+				return false
+			}
+		case "DoesNotExist":
+			if ok {
+				return false
+			}
+		case "Gt", "Lt":
+			if !ok || len(req.Values) != 1 {
+				return false
+			}
+			have, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return false
+			}
+			want, err := strconv.ParseInt(req.Values[0], 10, 64)
+			if err != nil {
+				return false
+			}
+			if (req.Operator == "Gt" && have <= want) ||
+				(req.Operator == "Lt" && have >= want) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NodeResult is the result of checking whether a Pod fits on a single Node
+type NodeResult struct {
+	// Node is the name of the Node
+	Node string `json:"node"`
+	// Fits is true if the Pod fits on the Node
+	Fits bool `json:"fits"`
+	// Reasons contains the reasons the Pod cannot be scheduled to the Node.
+	// Empty if the Pod fits.
+	Reasons []string `json:"reasons"`
+}
+
+// CheckNodes returns the result of Check for the supplied Pod on each of the
+// supplied Nodes, in the same order as the Nodes
+func CheckNodes(pod *types.Pod, nodes []*types.Node) []NodeResult {
+	res := make([]NodeResult, len(nodes))
+	for x, node := range nodes {
+		reasons := Check(pod, node)
+		res[x] = NodeResult{
+			Node:    node.Name,
+			Fits:    len(reasons) == 0,
+			Reasons: reasons,
+		}
+	}
+	return res
+}
+
+// FreeKnown returns true if there are NUMA cells and the unrequested CPU and
+// memory of every one of them is known
+func FreeKnown(cells []types.NUMACell) bool {
+	for _, cell := range cells {
+		if cell.Resources.CPU.RequestedFloor == -1 || cell.Resources.Memory.RequestedFloor == -1 {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+// FitsSingleNUMACell returns true if any one of the supplied NUMA cells has
+// enough unrequested CPU and memory for the request floor of the supplied
+// requests. Cells whose requested floor is unknown never fit.
+func FitsSingleNUMACell(reqs types.ResourceRequests, cells []types.NUMACell) bool {
+	for _, cell := range cells {
+		if !FreeKnown([]types.NUMACell{cell}) {
+			continue
+		}
+		if reqs.CPU.Floor <= Free(cell.Resources.CPU) &&
+			reqs.Memory.Floor <= Free(cell.Resources.Memory) {
+			return true
+		}
+	}
+	return false
+}
+
+// LargestAligned returns the largest CPU and memory request floor that fits
+// entirely in a single one of the supplied NUMA cells: the unrequested CPU
+// and memory of the cell with the most unrequested CPU, with ties broken by
+// unrequested memory. Returns false if there are no NUMA cells or the
+// unrequested amounts of any of them are unknown.
+func LargestAligned(cells []types.NUMACell) (types.ResourceRequests, bool) {
+	res := types.ResourceRequests{}
+	if !FreeKnown(cells) {
+		return res, false
+	}
+	for x, cell := range cells {
+		cpu := Free(cell.Resources.CPU)
+		mem := Free(cell.Resources.Memory)
+		if x == 0 || cpu > res.CPU.Floor ||
+			(cpu == res.CPU.Floor && mem > res.Memory.Floor) {
+			res.CPU = types.ResourceRequest{Floor: cpu, Ceiling: cpu}
+			res.Memory = types.ResourceRequest{Floor: mem, Ceiling: mem}
+		}
+	}
+	return res, true
+}
+
+// FreeCores returns the number of physical cores in the supplied NUMA cell
+// that are entirely free, i.e. none of their logical CPUs are exclusively
+// allocated or in the supplied set of reserved CPUs. This is the capacity
+// that matters for the full-pcpus-only CPU manager policy option.
+//
+// If the cell's allocated CPUs are unknown, the number of free cores is
+// estimated from the cell's unrequested CPUs, assuming they are packed onto
+// as few cores as possible. Returns false if the cell's CPU topology is
+// unknown, or neither its allocated CPUs nor its requested CPUs are known.
+func FreeCores(cell types.NUMACell, reserved []int) (float64, bool) {
+	threads := cell.ThreadsPerCore()
+	if threads == 0 {
+		return 0, false
+	}
+	if cell.AllocatedCPUs == nil {
+		if cell.Resources.CPU.RequestedFloor == -1 {
+			return 0, false
+		}
+		return math.Floor(Free(cell.Resources.CPU) / float64(threads)), true
+	}
+	busy := map[int]bool{}
+	for _, cpu := range cell.AllocatedCPUs {
+		busy[cpu] = true
+	}
+	for _, cpu := range reserved {
+		busy[cpu] = true
+	}
+	free := float64(0)
+	for _, core := range cell.Cores {
+		coreFree := true
+		for _, cpu := range core.CPUs {
+			if busy[cpu] {
+				coreFree = false
+				break
+			}
+		}
+		if coreFree {
+			free++
+		}
+	}
+	return free, true
+}
+
+// Free returns the amount of a resource's allocatable amount that has not
+// been requested by consumers, or 0 if the requested amount is unknown
+func Free(amounts types.ResourceAmounts) float64 {
+	if amounts.RequestedFloor == -1 {
+		return 0
+	}
+	return max(amounts.Allocatable-amounts.RequestedFloor, 0)
+}
+
+// Tolerates returns true if any of the supplied tolerations tolerates the
+// supplied taint.
+func Tolerates(tols []types.Toleration, taint types.Taint) bool {
+	for _, tol := range tols {
+		if tol.Effect != "" && tol.Effect != taint.Effect {
+			continue
+		}
+		switch tol.Operator {
+		case "Exists":
+			if tol.Key == "" || tol.Key == taint.Key {
+				return true
+			}
+		case "", "Equal":
+			if tol.Key == taint.Key && tol.Value == taint.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
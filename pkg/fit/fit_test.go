@@ -0,0 +1,477 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package fit_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/fit"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func TestCheck(t *testing.T) {
+	tcs := []struct {
+		name string
+		pod  *types.Pod
+		node *types.Node
+		exp  []string
+	}{
+		{
+			"fits",
+			&types.Pod{
+				Name: "pending",
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 2, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: 4 * unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{},
+		},
+		{
+			"insufficient",
+			&types.Pod{
+				Name: "pending",
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 6, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: 30 * unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{fit.ReasonInsufficientCPU, fit.ReasonInsufficientMemory},
+		},
+		{
+			"untolerated taint",
+			&types.Pod{
+				Name: "pending",
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Taints: []types.Taint{
+					{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"},
+					{Key: "soft", Value: "yes", Effect: "PreferNoSchedule"},
+				},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{"had untolerated taint {dedicated: gpu}"},
+		},
+		{
+			"tolerated taint",
+			&types.Pod{
+				Name: "pending",
+				Tolerations: []types.Toleration{
+					{Key: "dedicated", Operator: "Exists"},
+				},
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Taints: []types.Taint{
+					{Key: "dedicated", Value: "gpu", Effect: "NoSchedule"},
+					{Key: "soft", Value: "yes", Effect: "PreferNoSchedule"},
+				},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{},
+		},
+		{
+			"node selector",
+			&types.Pod{
+				Name:         "pending",
+				NodeSelector: map[string]string{"disktype": "hdd"},
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{fit.ReasonNodeSelectorMismatch},
+		},
+		{
+			"node affinity",
+			&types.Pod{
+				Name: "pending",
+				RequiredNodeAffinity: []types.NodeSelectorTerm{
+					{
+						MatchExpressions: []types.NodeSelectorRequirement{
+							{Key: "disktype", Operator: "In", Values: []string{"hdd", "nvme"}},
+						},
+					},
+					{
+						MatchFields: []types.NodeSelectorRequirement{
+							{Key: "metadata.name", Operator: "NotIn", Values: []string{"worker"}},
+						},
+					},
+				},
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{fit.ReasonNodeAffinityMismatch},
+		},
+		{
+			"any node affinity term",
+			&types.Pod{
+				Name: "pending",
+				RequiredNodeAffinity: []types.NodeSelectorTerm{
+					{
+						MatchExpressions: []types.NodeSelectorRequirement{
+							{Key: "disktype", Operator: "In", Values: []string{"hdd", "nvme"}},
+						},
+					},
+					{
+						MatchExpressions: []types.NodeSelectorRequirement{
+							{Key: "disktype", Operator: "Exists"},
+						},
+					},
+				},
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name:   "worker",
+				Labels: map[string]string{"disktype": "ssd"},
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+			},
+			[]string{},
+		},
+		{
+			// 4 CPUs are free on the node, but only 2 in each NUMA cell
+			"numa",
+			&types.Pod{
+				Name:     "pending",
+				QOSClass: types.QOSGuaranteed,
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 4, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name: "worker",
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+				NUMACells: []types.NUMACell{
+					{
+						ID: 0,
+						Resources: types.Resources{
+							CPU:    types.ResourceAmounts{Allocatable: 4, RequestedFloor: 2},
+							Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+						},
+					},
+					{
+						ID: 1,
+						Resources: types.Resources{
+							CPU:    types.ResourceAmounts{Allocatable: 4, RequestedFloor: 2},
+							Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+						},
+					},
+				},
+				KubeletConfig: &types.KubeletConfig{TopologyManagerPolicy: "single-numa-node"},
+			},
+			[]string{fit.ReasonNoNUMACellFits},
+		},
+		{
+			// Only Guaranteed Pods get NUMA-aligned resources
+			"numa burstable",
+			&types.Pod{
+				Name: "pending",
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 4, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name: "worker",
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+				NUMACells: []types.NUMACell{
+					{
+						ID: 0,
+						Resources: types.Resources{
+							CPU:    types.ResourceAmounts{Allocatable: 4, RequestedFloor: 2},
+							Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+						},
+					},
+					{
+						ID: 1,
+						Resources: types.Resources{
+							CPU:    types.ResourceAmounts{Allocatable: 4, RequestedFloor: 2},
+							Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+						},
+					},
+				},
+				KubeletConfig: &types.KubeletConfig{TopologyManagerPolicy: "single-numa-node"},
+			},
+			[]string{},
+		},
+		{
+			// The best-effort policy admits Pods that cannot be aligned
+			"numa best-effort policy",
+			&types.Pod{
+				Name:     "pending",
+				QOSClass: types.QOSGuaranteed,
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 4, Ceiling: -1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+				},
+			},
+			&types.Node{
+				Name: "worker",
+				Resources: types.Resources{
+					CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+					Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+					Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+				},
+				NUMACells: []types.NUMACell{
+					{
+						ID: 0,
+						Resources: types.Resources{
+							CPU:    types.ResourceAmounts{Allocatable: 4, RequestedFloor: 2},
+							Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+						},
+					},
+					{
+						ID: 1,
+						Resources: types.Resources{
+							CPU:    types.ResourceAmounts{Allocatable: 4, RequestedFloor: 2},
+							Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+						},
+					},
+				},
+				KubeletConfig: &types.KubeletConfig{TopologyManagerPolicy: "best-effort"},
+			},
+			[]string{},
+		},
+	}
+
+	for _, tc := range tcs {
+		got := fit.Check(tc.pod, tc.node)
+		if !reflect.DeepEqual(got, tc.exp) {
+			t.Fatalf("%s: expected %v but got %v", tc.name, tc.exp, got)
+		}
+	}
+}
+
+func TestCheckNodes(t *testing.T) {
+	nodes := []*types.Node{
+		{
+			Name: "worker",
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+				Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+			},
+		},
+		{
+			Name: "full",
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 8},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+				Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+			},
+		},
+	}
+	pod := &types.Pod{
+		Name: "pending",
+		ResourceRequests: types.ResourceRequests{
+			CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+			Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+		},
+	}
+	got := fit.CheckNodes(pod, nodes)
+	exp := []fit.NodeResult{
+		{Node: "worker", Fits: true, Reasons: []string{}},
+		{Node: "full", Fits: false, Reasons: []string{fit.ReasonInsufficientCPU}},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+}
+
+func TestLargestAligned(t *testing.T) {
+	if _, ok := fit.LargestAligned(nil); ok {
+		t.Fatalf("expected no largest aligned request without NUMA cells")
+	}
+	cells := []types.NUMACell{
+		{
+			ID: 0,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+				Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+			},
+		},
+		{
+			ID: 1,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+				Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi, RequestedFloor: 4 * unit.Gi},
+			},
+		},
+		{
+			ID: 2,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 6},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+			},
+		},
+	}
+	// 10 CPUs are free in total (4+4+2), but a Pod can only get 4 of them
+	// aligned. Cells 0 and 1 both have 4 free CPUs and cell 0 has more free
+	// memory.
+	got, ok := fit.LargestAligned(cells)
+	if !ok {
+		t.Fatalf("expected a largest aligned request")
+	}
+	if got.CPU.Floor != 4 || got.Memory.Floor != 16*unit.Gi {
+		t.Fatalf(
+			"expected 4 CPUs and 16Gi but got %.2f CPUs and %s",
+			got.CPU.Floor, unit.BytesToSizeString(got.Memory.Floor),
+		)
+	}
+}
+
+func TestUnknownRequestedFloor(t *testing.T) {
+	// A NUMA cell only known from an agent report, whose requested floor is
+	// unknown, must not look entirely free
+	cell := types.NUMACell{
+		ID:    0,
+		Cores: []types.CPUCore{{ID: 0, CPUs: []int{0, 1}}},
+		Resources: types.Resources{
+			CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: -1},
+			Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi, RequestedFloor: -1},
+		},
+	}
+	cells := []types.NUMACell{cell}
+	if fit.FreeKnown(cells) {
+		t.Fatalf("expected unknown free amounts")
+	}
+	if fit.FitsSingleNUMACell(types.ResourceRequests{}, cells) {
+		t.Fatalf("expected no fit in a cell with unknown free amounts")
+	}
+	if _, ok := fit.LargestAligned(cells); ok {
+		t.Fatalf("expected no largest aligned request with unknown free amounts")
+	}
+	if _, ok := fit.FreeCores(cell, nil); ok {
+		t.Fatalf("expected unknown free cores")
+	}
+	node := &types.Node{
+		Name: "worker",
+		Resources: types.Resources{
+			CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+			Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi, RequestedFloor: 8 * unit.Gi},
+			Pods:   types.ResourceAmounts{Allocatable: 110, RequestedFloor: 10},
+		},
+		NUMACells:     cells,
+		KubeletConfig: &types.KubeletConfig{TopologyManagerPolicy: "single-numa-node"},
+	}
+	pod := &types.Pod{
+		Name:     "pending",
+		QOSClass: types.QOSGuaranteed,
+		ResourceRequests: types.ResourceRequests{
+			CPU:    types.ResourceRequest{Floor: 1, Ceiling: -1},
+			Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+		},
+	}
+	if reasons := fit.Check(pod, node); len(reasons) != 0 {
+		t.Fatalf("expected the NUMA check to be skipped but got %v", reasons)
+	}
+}
+
+func TestFreeCores(t *testing.T) {
+	cell := types.NUMACell{
+		Resources: types.Resources{
+			CPU: types.ResourceAmounts{Allocatable: 8, RequestedFloor: 3},
+		},
+	}
+	if _, ok := fit.FreeCores(cell, nil); ok {
+		t.Fatalf("expected no free cores without a CPU topology")
+	}
+	cell.Cores = []types.CPUCore{
+		{ID: 0, CPUs: []int{0, 4}},
+		{ID: 1, CPUs: []int{1, 5}},
+		{ID: 2, CPUs: []int{2, 6}},
+		{ID: 3, CPUs: []int{3, 7}},
+	}
+	// 5 free logical CPUs with 2 threads per core is at most 2 whole cores
+	got, ok := fit.FreeCores(cell, nil)
+	if !ok || got != 2 {
+		t.Fatalf("expected an estimate of 2 free cores but got %.0f", got)
+	}
+	// 3 allocated CPUs spread across 3 cores only leave 1 whole core free
+	cell.AllocatedCPUs = []int{0, 5, 6}
+	got, _ = fit.FreeCores(cell, nil)
+	if got != 1 {
+		t.Fatalf("expected 1 free core but got %.0f", got)
+	}
+	got, _ = fit.FreeCores(cell, []int{7})
+	if got != 0 {
+		t.Fatalf("expected no free cores with CPU 7 reserved but got %.0f", got)
+	}
+}
@@ -0,0 +1,53 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package group
+
+import (
+	"sort"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+// KeyFunc returns the grouping key for a Pod
+type KeyFunc func(*types.Pod) string
+
+// ByNamespace groups the supplied Pods by their namespace
+func ByNamespace(pods []*types.Pod) []*types.PodGroup {
+	return By(pods, func(p *types.Pod) string {
+		return p.Namespace
+	})
+}
+
+// ByLabel groups the supplied Pods by the value of a label. Pods without the
+// label are grouped together under an empty name.
+func ByLabel(pods []*types.Pod, key string) []*types.PodGroup {
+	return By(pods, func(p *types.Pod) string {
+		return p.Labels[key]
+	})
+}
+
+// By groups the supplied Pods by the key returned from the supplied KeyFunc.
+// The returned groups are sorted by name.
+func By(pods []*types.Pod, keyFn KeyFunc) []*types.PodGroup {
+	byKey := map[string]*types.PodGroup{}
+	for _, p := range pods {
+		key := keyFn(p)
+		g, ok := byKey[key]
+		if !ok {
+			g = &types.PodGroup{Name: key}
+			byKey[key] = g
+		}
+		g.Pods = append(g.Pods, p)
+		g.ResourceRequests.Add(p.ResourceRequests)
+	}
+	groups := make([]*types.PodGroup, 0, len(byKey))
+	for _, g := range byKey {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
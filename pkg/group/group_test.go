@@ -0,0 +1,86 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package group_test
+
+import (
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/group"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+func TestByNamespace(t *testing.T) {
+	pods := []*types.Pod{
+		{
+			Namespace: "web",
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 1, Ceiling: 2},
+			},
+		},
+		{
+			Namespace: "db",
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 2, Ceiling: 4},
+			},
+		},
+		{
+			Namespace: "web",
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 0.5, Ceiling: 1},
+			},
+		},
+	}
+	groups := group.ByNamespace(pods)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups but got %d", len(groups))
+	}
+	web := groups[1]
+	if web.Name != "web" {
+		t.Fatalf("expected web but got %s", web.Name)
+	}
+	if len(web.Pods) != 2 {
+		t.Fatalf("expected 2 pods but got %d", len(web.Pods))
+	}
+	cpu := web.ResourceRequests.CPU
+	if cpu.Floor != 1.5 || cpu.Ceiling != 3 {
+		t.Fatalf("expected 1.5/3 but got %.2f/%.2f", cpu.Floor, cpu.Ceiling)
+	}
+}
+
+func TestByLabel(t *testing.T) {
+	pods := []*types.Pod{
+		{
+			Namespace: "web",
+			Labels:    map[string]string{"team": "blue"},
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 1, Ceiling: 2},
+			},
+		},
+		{
+			Namespace: "db",
+			Labels:    map[string]string{"team": "blue"},
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 2, Ceiling: -1},
+			},
+		},
+		{
+			Namespace: "web",
+			ResourceRequests: types.ResourceRequests{
+				CPU: types.ResourceRequest{Floor: 0.5, Ceiling: 1},
+			},
+		},
+	}
+	groups := group.ByLabel(pods, "team")
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups but got %d", len(groups))
+	}
+	if groups[0].Name != "" || len(groups[0].Pods) != 1 {
+		t.Fatalf("expected unlabeled group with 1 pod but got %+v", groups[0])
+	}
+	cpu := groups[1].ResourceRequests.CPU
+	if cpu.Floor != 3 || cpu.Ceiling != -1 {
+		t.Fatalf("expected 3/-1 but got %.2f/%.2f", cpu.Floor, cpu.Ceiling)
+	}
+}
@@ -0,0 +1,181 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/jaypipes/kwiz/pkg/agent"
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// DefaultNamespace is the default namespace the kwiz agent publishes
+	// its reports in
+	DefaultNamespace = "kube-system"
+	// configMapPrefix is the prefix of the name of the ConfigMap containing
+	// a Node's report. The Node name is appended.
+	configMapPrefix = "kwiz-node-"
+	// reportKey is the ConfigMap data key containing the JSON report
+	reportKey = "report.json"
+	// labelManagedBy is the label identifying the ConfigMaps published by
+	// the kwiz agent
+	labelManagedBy = "app.kubernetes.io/managed-by"
+	// managedBy is the value of labelManagedBy and the field manager used
+	// when applying ConfigMaps
+	managedBy = "kwiz-agent"
+	// labelNode is the label containing the name of the reported Node
+	labelNode = "kwiz.jaypipes.io/node"
+)
+
+var (
+	configMapGVK = schema.GroupVersionKind{
+		Kind: "ConfigMap",
+	}
+)
+
+// Publish creates or updates the ConfigMap containing the supplied report
+// in the supplied namespace
+func Publish(
+	ctx context.Context,
+	c *kconnect.Connection,
+	namespace string,
+	report *agent.Report,
+) error {
+	gvr, err := c.GVR(configMapGVK)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      configMapPrefix + report.Node,
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					labelManagedBy: managedBy,
+					labelNode:      report.Node,
+				},
+			},
+			"data": map[string]interface{}{
+				reportKey: string(data),
+			},
+		},
+	}
+	_, err = c.Client().Resource(gvr).Namespace(namespace).Apply(
+		ctx, obj.GetName(), obj, metav1.ApplyOptions{
+			FieldManager: managedBy,
+			Force:        true,
+		},
+	)
+	return err
+}
+
+// Get returns a map, keyed by Node name, of the reports published by the
+// kwiz agent in the supplied namespace. If the caller is not allowed to read
+// ConfigMaps in the namespace, an empty map is returned, since the agent is
+// optional. Invalid and stale reports are skipped, so one broken agent does
+// not hide the reports of every other Node, and a warning for each skipped
+// report is returned.
+func Get(
+	ctx context.Context,
+	c *kconnect.Connection,
+	namespace string,
+) (map[string]*agent.Report, []string, error) {
+	res := map[string]*agent.Report{}
+	warnings := []string{}
+	gvr, err := c.GVR(configMapGVK)
+	if err != nil {
+		return nil, nil, err
+	}
+	list, err := c.Client().Resource(gvr).Namespace(namespace).List(
+		ctx, metav1.ListOptions{
+			LabelSelector: labelManagedBy + "=" + managedBy,
+		},
+	)
+	if err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+			return res, warnings, nil
+		}
+		return nil, nil, err
+	}
+	now := time.Now()
+	for _, obj := range list.Items {
+		data, _, _ := unstructured.NestedString(obj.Object, "data", reportKey)
+		report := &agent.Report{}
+		if err = json.Unmarshal([]byte(data), report); err != nil {
+			warnings = append(warnings, fmt.Sprintf(
+				"ignoring invalid kwiz agent report in %s: %s", obj.GetName(), err,
+			))
+			continue
+		}
+		if report.Node == "" {
+			warnings = append(warnings, fmt.Sprintf(
+				"ignoring kwiz agent report with no node in %s", obj.GetName(),
+			))
+			continue
+		}
+		if report.Stale(now) {
+			warnings = append(warnings, fmt.Sprintf(
+				"ignoring stale kwiz agent report of node %s generated at %s",
+				report.Node, report.Generated.Format(time.RFC3339),
+			))
+			continue
+		}
+		res[report.Node] = report
+	}
+	return res, warnings, nil
+}
+
+// AttachAssignments sets the Assignments of each of the supplied Pods to the
+// container assignments with the Pod's UID, or namespace and name, in the
+// report of the Pod's Node
+func AttachAssignments(pods []*types.Pod, reports map[string]*agent.Report) {
+	for _, p := range pods {
+		report, ok := reports[p.Node]
+		if !ok {
+			continue
+		}
+		p.Assignments = nil
+		for _, a := range report.Assignments {
+			if (a.PodUID != "" && a.PodUID == p.UID) ||
+				(a.PodName != "" && a.PodNamespace == p.Namespace && a.PodName == p.Name) {
+				p.Assignments = append(p.Assignments, a)
+			}
+		}
+	}
+}
+
+// AttachUsage sets the Usage of each of the supplied Pods to the usage with
+// the Pod's UID in the report of the Pod's Node
+func AttachUsage(pods []*types.Pod, reports map[string]*agent.Report) {
+	for _, p := range pods {
+		report, ok := reports[p.Node]
+		if !ok {
+			continue
+		}
+		p.Usage = nil
+		for x := range report.Usage {
+			if report.Usage[x].PodUID == p.UID {
+				p.Usage = &report.Usage[x]
+				break
+			}
+		}
+	}
+}
@@ -24,6 +24,7 @@ type Connection struct {
 	mapper meta.RESTMapper
 	disco  discovery.CachedDiscoveryInterface
 	client dynamic.Interface
+	rest   rest.Interface
 }
 
 // Client() returns the Connection's dynamic Kubernetes client interface
@@ -34,6 +35,15 @@ func (c *Connection) Client() dynamic.Interface {
 	return c.client
 }
 
+// RESTClient() returns a REST client for the Kubernetes API server that may
+// be used to call non-resource paths, like a Node's kubelet proxy endpoints
+func (c *Connection) RESTClient() rest.Interface {
+	if c == nil || c.rest == nil {
+		return nil
+	}
+	return c.rest
+}
+
 // mappingFor returns a RESTMapper for a given resource type or kind
 func (c *Connection) mappingFor(typeOrKind string) (*meta.RESTMapping, error) {
 	fullySpecifiedGVR, groupResource := schema.ParseResourceArg(typeOrKind)
@@ -113,5 +123,6 @@ func Connect(cfg *rest.Config) (*Connection, error) {
 		mapper: expander,
 		disco:  disco,
 		client: c,
+		rest:   discoverer.RESTClient(),
 	}, nil
 }
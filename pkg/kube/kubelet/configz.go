@@ -0,0 +1,156 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	defaultCPUManagerPolicy      = "none"
+	defaultMemoryManagerPolicy   = "None"
+	defaultTopologyManagerPolicy = "none"
+	defaultTopologyManagerScope  = "container"
+	// configzWorkers is the number of kubelet configurations GetConfigs
+	// reads concurrently
+	configzWorkers = 16
+	// configzTimeout is how long GetConfigs waits for a single kubelet's
+	// configuration, so that one unreachable kubelet does not hold up the
+	// rest
+	configzTimeout = 3 * time.Second
+)
+
+// configz is the envelope of the kubelet's /configz response
+type configz struct {
+	KubeletConfig *kubeletConfig `json:"kubeletconfig"`
+}
+
+// kubeletConfig contains the fields of the kubelet's KubeletConfiguration
+// that kwiz cares about
+type kubeletConfig struct {
+	CPUManagerPolicy        string            `json:"cpuManagerPolicy"`
+	CPUManagerPolicyOptions map[string]string `json:"cpuManagerPolicyOptions"`
+	MemoryManagerPolicy     string            `json:"memoryManagerPolicy"`
+	TopologyManagerPolicy   string            `json:"topologyManagerPolicy"`
+	TopologyManagerScope    string            `json:"topologyManagerScope"`
+	ReservedSystemCPUs      string            `json:"reservedSystemCPUs"`
+	KubeReserved            map[string]string `json:"kubeReserved"`
+	SystemReserved          map[string]string `json:"systemReserved"`
+	EvictionHard            map[string]string `json:"evictionHard"`
+	ReservedMemory          []reservedMemory  `json:"reservedMemory"`
+}
+
+// reservedMemory is a single entry in the kubelet's reservedMemory list
+type reservedMemory struct {
+	NUMANode int               `json:"numaNode"`
+	Limits   map[string]string `json:"limits"`
+}
+
+// GetConfig returns the kubelet configuration of the named Node, read from
+// the kubelet's /configz endpoint through the API server's Node proxy.
+func GetConfig(
+	ctx context.Context,
+	c *kconnect.Connection,
+	nodeName string,
+) (*types.KubeletConfig, error) {
+	data, err := c.RESTClient().Get().
+		AbsPath("/api/v1/nodes", nodeName, "proxy", "configz").
+		DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ConfigFromConfigz(nodeName, data)
+}
+
+// GetConfigs returns the kubelet configurations of the named Nodes, keyed by
+// Node name, reading up to configzWorkers of them at a time with a separate
+// timeout for each. The errors reading the configuration of any Nodes are
+// returned keyed by Node name. The kubelet of a Node may be unreachable
+// through the API server's Node proxy, e.g. if the Node is NotReady.
+func GetConfigs(
+	ctx context.Context,
+	c *kconnect.Connection,
+	nodeNames []string,
+) (map[string]*types.KubeletConfig, map[string]error) {
+	configs := make(map[string]*types.KubeletConfig, len(nodeNames))
+	errs := map[string]error{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	names := make(chan string)
+	for x := 0; x < min(configzWorkers, len(nodeNames)); x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				callCtx, cancel := context.WithTimeout(ctx, configzTimeout)
+				cfg, err := GetConfig(callCtx, c, name)
+				cancel()
+				mu.Lock()
+				if err != nil {
+					errs[name] = err
+				} else {
+					configs[name] = cfg
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, name := range nodeNames {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+	return configs, errs
+}
+
+// ConfigFromConfigz accepts the raw response of a kubelet's /configz
+// endpoint and returns the kubelet configuration, with the kubelet's
+// defaults filled in for unset policies.
+func ConfigFromConfigz(
+	nodeName string,
+	data []byte,
+) (*types.KubeletConfig, error) {
+	var cz configz
+	if err := json.Unmarshal(data, &cz); err != nil {
+		return nil, InvalidConfigz(nodeName, err.Error())
+	}
+	if cz.KubeletConfig == nil {
+		return nil, InvalidConfigz(nodeName, "missing kubeletconfig")
+	}
+	kc := cz.KubeletConfig
+	res := &types.KubeletConfig{
+		CPUManagerPolicy:        defaultString(kc.CPUManagerPolicy, defaultCPUManagerPolicy),
+		CPUManagerPolicyOptions: kc.CPUManagerPolicyOptions,
+		MemoryManagerPolicy:     defaultString(kc.MemoryManagerPolicy, defaultMemoryManagerPolicy),
+		TopologyManagerPolicy:   defaultString(kc.TopologyManagerPolicy, defaultTopologyManagerPolicy),
+		TopologyManagerScope:    defaultString(kc.TopologyManagerScope, defaultTopologyManagerScope),
+		ReservedSystemCPUs:      kc.ReservedSystemCPUs,
+		KubeReserved:            kc.KubeReserved,
+		SystemReserved:          kc.SystemReserved,
+		EvictionHard:            kc.EvictionHard,
+		ReservedMemory:          make([]types.ReservedMemory, len(kc.ReservedMemory)),
+	}
+	for x, rm := range kc.ReservedMemory {
+		res.ReservedMemory[x] = types.ReservedMemory{
+			NUMACellID: rm.NUMANode,
+			Limits:     rm.Limits,
+		}
+	}
+	return res, nil
+}
+
+// defaultString returns s, or def if s is empty
+func defaultString(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
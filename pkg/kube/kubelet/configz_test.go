@@ -0,0 +1,77 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package kubelet_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kkubelet "github.com/jaypipes/kwiz/pkg/kube/kubelet"
+)
+
+func TestConfigFromConfigz(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "test", "testdata", "kubelet-configz.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kc, err := kkubelet.ConfigFromConfigz("worker-0", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kc.CPUManagerPolicy != "static" {
+		t.Fatalf("expected CPU manager policy static but got %s", kc.CPUManagerPolicy)
+	}
+	if kc.CPUManagerPolicyOptions["full-pcpus-only"] != "true" {
+		t.Fatalf("expected full-pcpus-only option but got %v", kc.CPUManagerPolicyOptions)
+	}
+	if kc.MemoryManagerPolicy != "Static" {
+		t.Fatalf("expected memory manager policy Static but got %s", kc.MemoryManagerPolicy)
+	}
+	if kc.TopologyManagerPolicy != "single-numa-node" {
+		t.Fatalf("expected topology manager policy single-numa-node but got %s", kc.TopologyManagerPolicy)
+	}
+	// topologyManagerScope is not set in the fixture and should be
+	// defaulted like the kubelet does
+	if kc.TopologyManagerScope != "container" {
+		t.Fatalf("expected topology manager scope container but got %s", kc.TopologyManagerScope)
+	}
+	if kc.ReservedSystemCPUs != "0,32" {
+		t.Fatalf("expected reserved system CPUs 0,32 but got %s", kc.ReservedSystemCPUs)
+	}
+	if kc.KubeReserved["memory"] != "1Gi" {
+		t.Fatalf("expected 1Gi kube-reserved memory but got %v", kc.KubeReserved)
+	}
+	if kc.EvictionHard["memory.available"] != "100Mi" {
+		t.Fatalf("expected 100Mi memory eviction threshold but got %v", kc.EvictionHard)
+	}
+	if len(kc.ReservedMemory) != 2 {
+		t.Fatalf("expected 2 reserved memory entries but got %d", len(kc.ReservedMemory))
+	}
+	if kc.ReservedMemory[1].NUMACellID != 1 || kc.ReservedMemory[1].Limits["memory"] != "1Gi" {
+		t.Fatalf("unexpected reserved memory for NUMA cell 1: %+v", kc.ReservedMemory[1])
+	}
+}
+
+func TestConfigFromConfigzDefaults(t *testing.T) {
+	kc, err := kkubelet.ConfigFromConfigz("worker-0", []byte(`{"kubeletconfig": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kc.CPUManagerPolicy != "none" || kc.MemoryManagerPolicy != "None" ||
+		kc.TopologyManagerPolicy != "none" || kc.TopologyManagerScope != "container" {
+		t.Fatalf("expected kubelet default policies but got %+v", kc)
+	}
+}
+
+func TestConfigFromConfigzInvalid(t *testing.T) {
+	for _, data := range []string{`not json`, `{"other": {}}`} {
+		_, err := kkubelet.ConfigFromConfigz("worker-0", []byte(data))
+		if !errors.Is(err, kkubelet.ErrInvalidConfigz) {
+			t.Fatalf("expected ErrInvalidConfigz for %q but got %v", data, err)
+		}
+	}
+}
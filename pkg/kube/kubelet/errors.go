@@ -0,0 +1,25 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package kubelet
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrInvalidConfigz is returned when the response from a kubelet's
+	// /configz endpoint cannot be parsed.
+	ErrInvalidConfigz = fmt.Errorf(
+		"%w: invalid kubelet configz response",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidConfigz returns ErrInvalidConfigz with some further context
+func InvalidConfigz(node string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidConfigz, node, reason)
+}
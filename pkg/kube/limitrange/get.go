@@ -0,0 +1,134 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package limitrange
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+var (
+	limitRangeGVK = schema.GroupVersionKind{
+		Kind: "LimitRange",
+	}
+)
+
+// Get returns a slice of `LimitRange` objects contained in a Kubernetes
+// cluster.
+func Get(
+	ctx context.Context,
+	c *kconnect.Connection,
+) ([]*types.LimitRange, error) {
+	gvrLimitRange, err := c.GVR(limitRangeGVK)
+	if err != nil {
+		return nil, err
+	}
+	opts := metav1.ListOptions{}
+	list, err := c.Client().Resource(gvrLimitRange).List(
+		ctx, opts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([]*types.LimitRange, len(list.Items))
+	for x, obj := range list.Items {
+		name, _, _ := unstructured.NestedString(obj.Object, "metadata", "name")
+		ns, _, _ := unstructured.NestedString(obj.Object, "metadata", "namespace")
+		defaults := types.ResourceRequests{
+			CPU:    types.ResourceRequest{Floor: -1, Ceiling: -1},
+			Memory: types.ResourceRequest{Floor: -1, Ceiling: -1},
+		}
+		limits, _, _ := unstructured.NestedSlice(obj.Object, "spec", "limits")
+		for _, l := range limits {
+			limit := l.(map[string]interface{})
+			limType, _, _ := unstructured.NestedString(limit, "type")
+			if limType != "Container" {
+				continue
+			}
+			defReqs, _, _ := unstructured.NestedStringMap(limit, "defaultRequest")
+			defLims, _, _ := unstructured.NestedStringMap(limit, "default")
+			if err = setDefaults(&defaults, defReqs, defLims); err != nil {
+				return nil, err
+			}
+		}
+		ranges[x] = &types.LimitRange{
+			Cluster:           "default",
+			Namespace:         ns,
+			Name:              name,
+			ContainerDefaults: defaults,
+		}
+	}
+	return ranges, nil
+}
+
+// ByNamespace returns a map, keyed by namespace, of the combined
+// `LimitRange` for each namespace. When multiple LimitRanges in a namespace
+// supply a default for the same amount, the first one wins, as only the
+// first default applied by the LimitRanger admission plugin has any effect.
+func ByNamespace(ranges []*types.LimitRange) map[string]*types.LimitRange {
+	res := map[string]*types.LimitRange{}
+	for _, lr := range ranges {
+		combined, ok := res[lr.Namespace]
+		if !ok {
+			clr := *lr
+			res[lr.Namespace] = &clr
+			continue
+		}
+		combined.Name += "," + lr.Name
+		combineDefault(&combined.ContainerDefaults.CPU, lr.ContainerDefaults.CPU)
+		combineDefault(&combined.ContainerDefaults.Memory, lr.ContainerDefaults.Memory)
+	}
+	return res
+}
+
+// combineDefault sets any amount missing (-1) in the destination to the
+// corresponding amount in the source.
+func combineDefault(dst *types.ResourceRequest, src types.ResourceRequest) {
+	if dst.Floor == -1 {
+		dst.Floor = src.Floor
+	}
+	if dst.Ceiling == -1 {
+		dst.Ceiling = src.Ceiling
+	}
+}
+
+// setDefaults sets the floor and ceiling defaults in the supplied
+// `ResourceRequests` from maps of resource name to quantity string for the
+// default requests and default limits of a LimitRange item.
+func setDefaults(
+	defaults *types.ResourceRequests,
+	defReqs map[string]string,
+	defLims map[string]string,
+) error {
+	for _, d := range []struct {
+		amounts map[string]string
+		resName string
+		dst     *float64
+	}{
+		{defReqs, "cpu", &defaults.CPU.Floor},
+		{defLims, "cpu", &defaults.CPU.Ceiling},
+		{defReqs, "memory", &defaults.Memory.Floor},
+		{defLims, "memory", &defaults.Memory.Ceiling},
+	} {
+		amt, ok := d.amounts[d.resName]
+		if !ok {
+			continue
+		}
+		q, err := resource.ParseQuantity(amt)
+		if err != nil {
+			return fmt.Errorf("invalid default %s %q: %w", d.resName, amt, err)
+		}
+		*d.dst = q.AsApproximateFloat64()
+	}
+	return nil
+}
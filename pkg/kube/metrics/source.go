@@ -0,0 +1,93 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	"github.com/jaypipes/kwiz/pkg/unit"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+var (
+	podMetricsGVK = schema.GroupVersionKind{
+		Kind: "PodMetrics",
+	}
+)
+
+// Source is a usage.Source reading the current usage of containers from
+// the resource metrics API served by metrics-server. metrics-server keeps no
+// history, so each container has a single sample regardless of the window.
+type Source struct {
+	c *kconnect.Connection
+}
+
+// NewSource returns a Source reading from the resource metrics API of the
+// supplied connection's cluster
+func NewSource(c *kconnect.Connection) *Source {
+	return &Source{c: c}
+}
+
+// ContainerUsage returns the current usage of every container in the
+// supplied scope
+func (s *Source) ContainerUsage(
+	ctx context.Context,
+	window time.Duration,
+	scope usage.Scope,
+) (map[usage.ContainerKey]*usage.Samples, error) {
+	gvr, err := s.c.GVR(podMetricsGVK)
+	if err != nil {
+		return nil, err
+	}
+	lopts := metav1.ListOptions{}
+	if scope.Pod != "" {
+		lopts.FieldSelector = "metadata.name=" + scope.Pod
+	}
+	list, err := s.c.Client().Resource(gvr).Namespace(scope.Namespace).List(ctx, lopts)
+	if err != nil {
+		return nil, err
+	}
+	res := map[usage.ContainerKey]*usage.Samples{}
+	for _, obj := range list.Items {
+		ns := obj.GetNamespace()
+		pod := obj.GetName()
+		// The end of the window metrics-server measured the usage over
+		ts := time.Now()
+		tsStr, _, _ := unstructured.NestedString(obj.Object, "timestamp")
+		if t, err := time.Parse(time.RFC3339, tsStr); err == nil {
+			ts = t
+		}
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "containers")
+		for _, c := range containers {
+			ctr := c.(map[string]interface{})
+			name, _, _ := unstructured.NestedString(ctr, "name")
+			cpuStr, _, _ := unstructured.NestedString(ctr, "usage", "cpu")
+			memStr, _, _ := unstructured.NestedString(ctr, "usage", "memory")
+			cpu, err := unit.CPUStringToCores(cpuStr)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"invalid CPU usage of container %s in %s/%s: %w",
+					name, ns, pod, err,
+				)
+			}
+			samples := &usage.Samples{CPU: []usage.Sample{{Time: ts, Value: cpu}}}
+			if memStr != "" {
+				samples.Memory = []usage.Sample{
+					{Time: ts, Value: unit.SizeStringToBytes(memStr)},
+				}
+			}
+			key := usage.ContainerKey{Namespace: ns, Pod: pod, Container: name}
+			res[key] = samples
+		}
+	}
+	return res, nil
+}
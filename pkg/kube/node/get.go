@@ -7,18 +7,28 @@ package node
 import (
 	"context"
 	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
+	"github.com/jaypipes/kwiz/pkg/agent"
 	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	kkubelet "github.com/jaypipes/kwiz/pkg/kube/kubelet"
 	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/reserved"
 	"github.com/jaypipes/kwiz/pkg/types"
 	"github.com/jaypipes/kwiz/pkg/unit"
 )
 
+const (
+	// labelInstanceType is the well-known label the cloud provider sets on a
+	// Node to indicate the machine's instance type
+	labelInstanceType = "node.kubernetes.io/instance-type"
+)
+
 var (
 	nodeGVK = schema.GroupVersionKind{
 		Kind: "Node",
@@ -30,6 +40,25 @@ type NodeGetOptions struct {
 	// '!='.(e.g. -l key1=value1,key2=value2). Matching objects must satisfy
 	// all of the specified label constraints.
 	LabelSelector string
+	// ApplyLimitRanges instructs kwiz to calculate each Node's
+	// AdjustedRequestedCeiling from Pod requests adjusted by the defaults of
+	// the LimitRanges in each Pod's namespace.
+	ApplyLimitRanges bool
+	// Name, if not empty, limits the returned Nodes to the Node with this
+	// name.
+	Name string
+	// KubeletConfig instructs kwiz to read each Node's kubelet configuration
+	// from the kubelet's /configz endpoint via the API server's Node proxy.
+	// The CPU manager policy in the configuration determines whether CPUs
+	// are exclusively requested, so a Node's CPU ExclusiveRequestedFloor is
+	// unknown (-1) without it. A Node whose kubelet configuration cannot be
+	// read is still returned, with a nil KubeletConfig and the reason in
+	// its KubeletConfigError.
+	KubeletConfig bool
+	// AgentReports contains the NUMA topology reports published by the kwiz
+	// agent, keyed by Node name, as returned by the kube agent package's Get.
+	// No Node's usage is known without them.
+	AgentReports map[string]*agent.Report
 }
 
 // Get returns a slice of `Node` objects contained in a Kubernetes cluster.
@@ -45,6 +74,9 @@ func Get(
 	lopts := metav1.ListOptions{
 		LabelSelector: opts.LabelSelector,
 	}
+	if opts.Name != "" {
+		lopts.FieldSelector = "metadata.name=" + opts.Name
+	}
 	list, err := c.Client().Resource(gvrNode).List(
 		ctx, lopts,
 	)
@@ -54,10 +86,32 @@ func Get(
 	nodes := make([]*types.Node, len(list.Items))
 	// Grab the entire set of Pods in the cluster and create a map, keyed by
 	// node name, of Pod structs.
-	pods, err := kpod.Get(ctx, c)
+	pods, err := kpod.Get(ctx, c, &kpod.PodGetOptions{
+		ApplyLimitRanges: opts.ApplyLimitRanges,
+	})
+	if err != nil {
+		return nil, err
+	}
+	nodeCells, err := numaCellsFromNRT(ctx, c)
 	if err != nil {
 		return nil, err
 	}
+	// The CPU and memory used on each Node, which only the kwiz agent
+	// reports
+	nodeUsed := map[string]types.Resources{}
+	for name, report := range opts.AgentReports {
+		nodeCells[name] = mergeAgentCells(nodeCells[name], report.NUMACells)
+		nodeUsed[name] = usedFromAgentCells(report.NUMACells)
+	}
+	var configs map[string]*types.KubeletConfig
+	var configErrs map[string]error
+	if opts.KubeletConfig {
+		names := make([]string, len(list.Items))
+		for x, obj := range list.Items {
+			names[x] = obj.GetName()
+		}
+		configs, configErrs = kkubelet.GetConfigs(ctx, c, names)
+	}
 	nodePods := make(map[string][]*types.Pod, len(nodes))
 	for _, p := range pods {
 		np, ok := nodePods[p.Node]
@@ -71,6 +125,8 @@ func Get(
 	for x, obj := range list.Items {
 		var nodeIP string
 		name, _, _ := unstructured.NestedString(obj.Object, "metadata", "name")
+		labels, _, _ := unstructured.NestedStringMap(obj.Object, "metadata", "labels")
+		taints := taintsFromRaw(obj.Object)
 		addresses, _, _ := unstructured.NestedSlice(obj.Object, "status", "addresses")
 		if len(addresses) > 0 {
 			for _, address := range addresses {
@@ -92,10 +148,17 @@ func Get(
 		}
 		cpuReserved := cpuCap - cpuAlloc
 		var cpuReqFloor float64 = 0
+		var cpuDSReqFloor float64 = 0
+		var cpuExclReqFloor float64 = 0
 		var cpuReqCeil float64 = 0
+		var cpuAdjReqCeil float64 = 0
 		if hasPods {
 			for _, p := range podsOnNode {
 				cpuReqFloor += p.ResourceRequests.CPU.Floor
+				if p.OwnerKind == "DaemonSet" {
+					cpuDSReqFloor += p.ResourceRequests.CPU.Floor
+				}
+				cpuExclReqFloor += p.ExclusiveCPUs
 				// If there is any Pod on the Node that has no limits set for
 				// this resource, it can potentially consume all of the
 				// resource on the Node. So, we treat ceiling == -1 specially.
@@ -104,6 +167,11 @@ func Get(
 				} else {
 					cpuReqCeil += p.ResourceRequests.CPU.Ceiling
 				}
+				if cpuAdjReqCeil == -1 || p.AdjustedResourceRequests.CPU.Ceiling == -1 {
+					cpuAdjReqCeil = -1
+				} else {
+					cpuAdjReqCeil += p.AdjustedResourceRequests.CPU.Ceiling
+				}
 			}
 		}
 		memCap, err := resourceCapacityFromRaw(obj.Object, "memory")
@@ -116,10 +184,15 @@ func Get(
 		}
 		memReserved := memCap - memAlloc
 		var memReqFloor float64 = 0
+		var memDSReqFloor float64 = 0
 		var memReqCeil float64 = 0
+		var memAdjReqCeil float64 = 0
 		if hasPods {
 			for _, p := range podsOnNode {
 				memReqFloor += p.ResourceRequests.Memory.Floor
+				if p.OwnerKind == "DaemonSet" {
+					memDSReqFloor += p.ResourceRequests.Memory.Floor
+				}
 				// If there is any Pod on the Node that has no limits set for
 				// this resource, it can potentially consume all of the
 				// resource on the Node. So, we treat ceiling == -1 specially.
@@ -128,6 +201,11 @@ func Get(
 				} else {
 					memReqCeil += p.ResourceRequests.Memory.Ceiling
 				}
+				if memAdjReqCeil == -1 || p.AdjustedResourceRequests.Memory.Ceiling == -1 {
+					memAdjReqCeil = -1
+				} else {
+					memAdjReqCeil += p.AdjustedResourceRequests.Memory.Ceiling
+				}
 			}
 		}
 		podCap, err := resourceCapacityFromRaw(obj.Object, "pods")
@@ -140,45 +218,144 @@ func Get(
 		}
 		podReserved := podCap - podAlloc
 		var podCount float64 = 0
+		var dsPodCount float64 = 0
 		if hasPods {
 			podCount = float64(len(podsOnNode))
+			for _, p := range podsOnNode {
+				if p.OwnerKind == "DaemonSet" {
+					dsPodCount++
+				}
+			}
 		}
 		nodeRes := types.Resources{
 			CPU: types.ResourceAmounts{
-				Capacity:         cpuCap,
-				Allocatable:      cpuAlloc,
-				Reserved:         cpuReserved,
-				RequestedFloor:   cpuReqFloor,
-				RequestedCeiling: cpuReqCeil,
+				Capacity:                 cpuCap,
+				Allocatable:              cpuAlloc,
+				Reserved:                 cpuReserved,
+				RequestedFloor:           cpuReqFloor,
+				DaemonSetRequestedFloor:  cpuDSReqFloor,
+				RequestedCeiling:         cpuReqCeil,
+				AdjustedRequestedCeiling: cpuAdjReqCeil,
 			},
 			Memory: types.ResourceAmounts{
-				Capacity:         memCap,
-				Allocatable:      memAlloc,
-				Reserved:         memReserved,
-				RequestedFloor:   memReqFloor,
-				RequestedCeiling: memReqCeil,
+				Capacity:                 memCap,
+				Allocatable:              memAlloc,
+				Reserved:                 memReserved,
+				RequestedFloor:           memReqFloor,
+				DaemonSetRequestedFloor:  memDSReqFloor,
+				RequestedCeiling:         memReqCeil,
+				AdjustedRequestedCeiling: memAdjReqCeil,
 			},
 			Pods: types.ResourceAmounts{
-				Capacity:         podCap,
-				Allocatable:      podAlloc,
-				Reserved:         podReserved,
-				RequestedFloor:   podCount,
-				RequestedCeiling: podCount,
-				Used:             podCount,
+				Capacity:                 podCap,
+				Allocatable:              podAlloc,
+				Reserved:                 podReserved,
+				RequestedFloor:           podCount,
+				DaemonSetRequestedFloor:  dsPodCount,
+				RequestedCeiling:         podCount,
+				AdjustedRequestedCeiling: podCount,
+				Used:                     podCount,
 			},
 		}
 		node := &types.Node{
-			Cluster:   "default",
-			Name:      name,
-			Address:   nodeIP,
-			Resources: nodeRes,
-			NUMACells: []types.NUMACell{},
+			Cluster:      "default",
+			Name:         name,
+			Address:      nodeIP,
+			InstanceType: labels[labelInstanceType],
+			Labels:       labels,
+			Taints:       taints,
+			Resources:    nodeRes,
+			NUMACells:    []types.NUMACell{},
+		}
+		if cells, ok := nodeCells[name]; ok {
+			node.NUMACells = cells
 		}
+		node.Resources.CPU.Used, node.Resources.Memory.Used = -1, -1
+		if used, ok := nodeUsed[name]; ok {
+			node.Resources.CPU.Used = used.CPU.Used
+			node.Resources.Memory.Used = used.Memory.Used
+		}
+		node.KubeletConfig = configs[name]
+		if err, ok := configErrs[name]; ok {
+			node.KubeletConfigError = err.Error()
+		}
+		if node.KubeletConfig != nil {
+			res := &node.Resources
+			res.CPU.ReservedBreakdown, err = reserved.CPU(node.KubeletConfig, res.CPU)
+			if err != nil {
+				return nil, err
+			}
+			res.Memory.ReservedBreakdown = reserved.Memory(
+				node.KubeletConfig, res.Memory, hugepagesCapacityFromRaw(obj.Object),
+			)
+		}
+		node.Resources.CPU.ExclusiveRequestedFloor = exclusiveCPUs(
+			node.KubeletConfig, cpuExclReqFloor,
+		)
 		nodes[x] = node
 	}
 	return nodes, nil
 }
 
+// exclusiveCPUs returns the number of CPUs exclusively requested on a Node
+// with the supplied kubelet configuration, given the number of whole CPUs
+// requested by its Guaranteed Pods. Those CPUs are only pinned to the Pods
+// under the static CPU manager policy. Returns -1 if the kubelet
+// configuration is unknown.
+func exclusiveCPUs(cfg *types.KubeletConfig, guaranteedCPUs float64) float64 {
+	if cfg == nil {
+		return -1
+	}
+	if cfg.CPUManagerPolicy != "static" {
+		return 0
+	}
+	return guaranteedCPUs
+}
+
+// usedFromAgentCells returns the CPU and memory used on a Node, the sums of
+// the amounts used in each of the supplied NUMA cells reported by the kwiz
+// agent. An amount is -1 if it is unknown in any cell.
+func usedFromAgentCells(cells []types.NUMACell) types.Resources {
+	res := types.Resources{}
+	for _, cell := range cells {
+		res.CPU.Add(types.ResourceAmounts{Used: cell.Resources.CPU.Used})
+		res.Memory.Add(types.ResourceAmounts{Used: cell.Resources.Memory.Used})
+	}
+	return res
+}
+
+// taintsFromRaw accepts a raw map of Kubernetes Node fields and returns the
+// Node's taints.
+func taintsFromRaw(obj map[string]interface{}) []types.Taint {
+	taints, _, _ := unstructured.NestedSlice(obj, "spec", "taints")
+	res := make([]types.Taint, len(taints))
+	for x, taint := range taints {
+		taintMap := taint.(map[string]interface{})
+		key, _, _ := unstructured.NestedString(taintMap, "key")
+		val, _, _ := unstructured.NestedString(taintMap, "value")
+		effect, _, _ := unstructured.NestedString(taintMap, "effect")
+		res[x] = types.Taint{
+			Key:    key,
+			Value:  val,
+			Effect: effect,
+		}
+	}
+	return res
+}
+
+// hugepagesCapacityFromRaw accepts a raw map of Kubernetes Node fields and
+// returns the total bytes of memory pre-allocated to hugepages of all sizes.
+func hugepagesCapacityFromRaw(obj map[string]interface{}) float64 {
+	capacity, _, _ := unstructured.NestedStringMap(obj, "status", "capacity")
+	res := float64(0)
+	for resType, amountStr := range capacity {
+		if strings.HasPrefix(resType, "hugepages-") && amountStr != "" {
+			res += unit.SizeStringToBytes(amountStr)
+		}
+	}
+	return res
+}
+
 // resourceCapacityFromRaw accepts a raw map of Kubernetes object fields and
 // returns the capacity of a requested resource type.
 func resourceCapacityFromRaw(
@@ -0,0 +1,205 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	kerrors "github.com/jaypipes/kwiz/pkg/kube/errors"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+const (
+	// nrtZoneTypeNode is the type of a NodeResourceTopology zone that
+	// represents a NUMA node/cell
+	nrtZoneTypeNode = "Node"
+)
+
+var (
+	nrtGVK = schema.GroupVersionKind{
+		Kind: "NodeResourceTopology",
+	}
+)
+
+// numaCellsFromNRT returns a map, keyed by Node name, of the NUMACells
+// described by the NodeResourceTopology objects in the cluster. These
+// objects are published by agents such as the NFD topology-updater or the
+// resource-topology-exporter. If the NodeResourceTopology CRD is not
+// installed in the cluster, an empty map is returned.
+func numaCellsFromNRT(
+	ctx context.Context,
+	c *kconnect.Connection,
+) (map[string][]types.NUMACell, error) {
+	res := map[string][]types.NUMACell{}
+	gvrNRT, err := c.GVR(nrtGVK)
+	if err != nil {
+		if errors.Is(err, kerrors.ErrResourceUnknown) {
+			return res, nil
+		}
+		return nil, err
+	}
+	list, err := c.Client().Resource(gvrNRT).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range list.Items {
+		name, _, _ := unstructured.NestedString(obj.Object, "metadata", "name")
+		zones, _, _ := unstructured.NestedSlice(obj.Object, "zones")
+		cells := []types.NUMACell{}
+		for _, z := range zones {
+			zone := z.(map[string]interface{})
+			zoneType, _, _ := unstructured.NestedString(zone, "type")
+			if zoneType != nrtZoneTypeNode {
+				continue
+			}
+			cell, err := numaCellFromNRTZone(zone)
+			if err != nil {
+				return nil, err
+			}
+			cells = append(cells, cell)
+		}
+		sort.Slice(cells, func(i, j int) bool {
+			return cells[i].ID < cells[j].ID
+		})
+		res[name] = cells
+	}
+	return res, nil
+}
+
+// numaCellFromNRTZone accepts a raw map of NodeResourceTopology zone fields
+// and returns a `NUMACell`. Zones are named "node-<ID>". NodeResourceTopology
+// exporters do not publish the socket, physical cores or allocated CPUs of a
+// zone, so these come from the kwiz agent's report, if any.
+func numaCellFromNRTZone(zone map[string]interface{}) (types.NUMACell, error) {
+	cell := types.NUMACell{SocketID: -1}
+	// NodeResourceTopology does not report usage
+	cell.Resources.CPU.Used, cell.Resources.Memory.Used = -1, -1
+	zoneName, _, _ := unstructured.NestedString(zone, "name")
+	id, err := strconv.Atoi(strings.TrimPrefix(zoneName, "node-"))
+	if err != nil {
+		return cell, fmt.Errorf("unexpected NUMA zone name %q: %w", zoneName, err)
+	}
+	cell.ID = id
+	resources, _, _ := unstructured.NestedSlice(zone, "resources")
+	for _, r := range resources {
+		resMap := r.(map[string]interface{})
+		resName, _, _ := unstructured.NestedString(resMap, "name")
+		var amounts *types.ResourceAmounts
+		switch resName {
+		case "cpu":
+			amounts = &cell.Resources.CPU
+		case "memory":
+			amounts = &cell.Resources.Memory
+		default:
+			continue
+		}
+		capacity, err := nrtQuantityFromRaw(resMap, "capacity", resName)
+		if err != nil {
+			return cell, err
+		}
+		alloc, err := nrtQuantityFromRaw(resMap, "allocatable", resName)
+		if err != nil {
+			return cell, err
+		}
+		avail, err := nrtQuantityFromRaw(resMap, "available", resName)
+		if err != nil {
+			return cell, err
+		}
+		amounts.Capacity = capacity
+		amounts.Allocatable = alloc
+		amounts.Reserved = capacity - alloc
+		// NodeResourceTopology only reports what is still available in the
+		// zone, so whatever is allocatable but not available has been
+		// requested by consumers.
+		amounts.RequestedFloor = alloc - avail
+		if resName == "cpu" {
+			// The topology exporter only subtracts CPUs that are exclusively
+			// assigned to containers from what is available in the zone.
+			amounts.ExclusiveRequestedFloor = amounts.RequestedFloor
+		}
+	}
+	return cell, nil
+}
+
+// nrtQuantityFromRaw accepts a raw map of NodeResourceTopology resource
+// fields and returns the amount of one of the resource's quantities.
+func nrtQuantityFromRaw(
+	res map[string]interface{},
+	field string,
+	resName string,
+) (float64, error) {
+	amt, found, _ := unstructured.NestedFieldNoCopy(res, field)
+	if !found {
+		return 0, nil
+	}
+	amtStr := fmt.Sprintf("%v", amt)
+	if resName == "memory" {
+		return unit.SizeStringToBytes(amtStr), nil
+	}
+	return unit.CPUStringToCores(amtStr)
+}
+
+// mergeAgentCells returns the supplied NodeResourceTopology NUMA cells with
+// the CPU topology, distances, hugepages, devices, allocated CPUs,
+// exclusively requested amounts and usage reported by the kwiz agent for the
+// same cells filled in. NUMA cells only known to the agent are added, with their
+// capacity as their allocatable amount and an unknown (-1) requested floor,
+// since the agent only knows the exclusive assignments in the cell and not
+// the requests of Pods in the shared pool.
+func mergeAgentCells(
+	nrtCells []types.NUMACell,
+	agentCells []types.NUMACell,
+) []types.NUMACell {
+	res := append([]types.NUMACell{}, nrtCells...)
+	for _, ac := range agentCells {
+		found := false
+		for x := range res {
+			cell := &res[x]
+			if cell.ID != ac.ID {
+				continue
+			}
+			found = true
+			cell.SocketID = ac.SocketID
+			cell.Cores = ac.Cores
+			cell.Distances = ac.Distances
+			cell.Hugepages = ac.Hugepages
+			cell.Devices = ac.Devices
+			if ac.AllocatedCPUs != nil {
+				cell.AllocatedCPUs = ac.AllocatedCPUs
+				cell.Resources.CPU.ExclusiveRequestedFloor = ac.Resources.CPU.ExclusiveRequestedFloor
+			}
+			cell.Resources.Memory.ExclusiveRequestedFloor = ac.Resources.Memory.ExclusiveRequestedFloor
+			cell.Resources.CPU.Used = ac.Resources.CPU.Used
+			cell.Resources.Memory.Used = ac.Resources.Memory.Used
+		}
+		if found {
+			continue
+		}
+		for _, amounts := range []*types.ResourceAmounts{
+			&ac.Resources.CPU, &ac.Resources.Memory,
+		} {
+			if amounts.Allocatable == 0 {
+				amounts.Allocatable = amounts.Capacity
+			}
+			amounts.RequestedFloor = -1
+		}
+		res = append(res, ac)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
@@ -6,14 +6,16 @@ package pod
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"strconv"
-	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	klimitrange "github.com/jaypipes/kwiz/pkg/kube/limitrange"
 	"github.com/jaypipes/kwiz/pkg/types"
 	"github.com/jaypipes/kwiz/pkg/unit"
 )
@@ -24,36 +26,145 @@ var (
 	}
 )
 
+type PodGetOptions struct {
+	// Namespace to list Pods in. If empty, Pods in all namespaces are listed.
+	Namespace string
+	// LabelSelector (label query) to filter on, supports '=', '==', and
+	// '!='.(e.g. -l key1=value1,key2=value2). Matching objects must satisfy
+	// all of the specified label constraints.
+	LabelSelector string
+	// FieldSelector (field query) to filter on, supports '=', '==', and
+	// '!='.(e.g. --field-selector key1=value1,key2=value2).
+	FieldSelector string
+	// Node is the name of a Node to list Pods on. If empty, Pods on all Nodes
+	// (and Pods not scheduled to any Node) are listed.
+	Node string
+	// ApplyLimitRanges instructs kwiz to read the LimitRanges in the cluster
+	// and calculate each Pod's AdjustedResourceRequests from the defaults of
+	// the LimitRanges in the Pod's namespace.
+	ApplyLimitRanges bool
+}
+
 // Get returns a slice of `Pod` objects contained in a Kubernetes cluster.
 func Get(
 	ctx context.Context,
 	c *kconnect.Connection,
+	opts *PodGetOptions,
 ) ([]*types.Pod, error) {
 	gvrPod, err := c.GVR(podGVK)
 	if err != nil {
 		return nil, err
 	}
-	opts := metav1.ListOptions{}
-	list, err := c.Client().Resource(gvrPod).List(
-		ctx, opts,
+	fieldSelector := opts.FieldSelector
+	if opts.Node != "" {
+		nodeSelector := "spec.nodeName=" + opts.Node
+		if fieldSelector != "" {
+			fieldSelector += "," + nodeSelector
+		} else {
+			fieldSelector = nodeSelector
+		}
+	}
+	lopts := metav1.ListOptions{
+		LabelSelector: opts.LabelSelector,
+		FieldSelector: fieldSelector,
+	}
+	list, err := c.Client().Resource(gvrPod).Namespace(opts.Namespace).List(
+		ctx, lopts,
 	)
 	if err != nil {
 		return nil, err
 	}
+	var nsLimitRanges map[string]*types.LimitRange
+	if opts.ApplyLimitRanges {
+		ranges, err := klimitrange.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		nsLimitRanges = klimitrange.ByNamespace(ranges)
+	}
 	pods := make([]*types.Pod, len(list.Items))
 	for x, obj := range list.Items {
-		name, _, _ := unstructured.NestedString(obj.Object, "metadata", "name")
-		nodeName, _, _ := unstructured.NestedString(obj.Object, "spec", "nodeName")
 		ns, _, _ := unstructured.NestedString(obj.Object, "metadata", "namespace")
-		cpuFloor, cpuCeil, err := resourceFloorCeilingFromRaw(obj.Object, "cpu")
+		spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+		pod, err := podFromRaw(obj.Object, spec, nsLimitRanges[ns])
+		if err != nil {
+			return nil, err
+		}
+		pods[x] = pod
+	}
+	return pods, nil
+}
+
+// podFromRaw accepts a raw map of Kubernetes object fields, the raw Pod spec
+// contained in that object and an optional LimitRange and returns a `Pod`.
+func podFromRaw(
+	obj map[string]interface{},
+	spec map[string]interface{},
+	lr *types.LimitRange,
+) (*types.Pod, error) {
+	name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+	uid, _, _ := unstructured.NestedString(obj, "metadata", "uid")
+	nodeName, _, _ := unstructured.NestedString(spec, "nodeName")
+	ns, _, _ := unstructured.NestedString(obj, "metadata", "namespace")
+	labels, _, _ := unstructured.NestedStringMap(obj, "metadata", "labels")
+	phase, _, _ := unstructured.NestedString(obj, "status", "phase")
+	nodeSelector, _, _ := unstructured.NestedStringMap(spec, "nodeSelector")
+	ownerKind, ownerName := ControllerFromRaw(obj)
+	cpuFloor, cpuCeil, err := resourceFloorCeilingFromRaw(spec, "cpu")
+	if err != nil {
+		return nil, err
+	}
+	memFloor, memCeil, err := resourceFloorCeilingFromRaw(spec, "memory")
+	if err != nil {
+		return nil, err
+	}
+	containers, err := containersFromRaw(spec)
+	if err != nil {
+		return nil, err
+	}
+	podResReq := types.ResourceRequests{
+		CPU: types.ResourceRequest{
+			Floor:   cpuFloor,
+			Ceiling: cpuCeil,
+		},
+		Memory: types.ResourceRequest{
+			Floor:   memFloor,
+			Ceiling: memCeil,
+		},
+	}
+	pod := &types.Pod{
+		Cluster:                  "default",
+		Name:                     name,
+		UID:                      uid,
+		Node:                     nodeName,
+		Namespace:                ns,
+		Phase:                    phase,
+		Labels:                   labels,
+		OwnerKind:                ownerKind,
+		OwnerName:                ownerName,
+		NodeSelector:             nodeSelector,
+		RequiredNodeAffinity:     requiredNodeAffinityFromRaw(spec),
+		Tolerations:              tolerationsFromRaw(spec),
+		QOSClass:                 podQOSClass(containers),
+		ExclusiveCPUs:            exclusiveCPUs(containers),
+		Containers:               containers,
+		ResourceRequests:         podResReq,
+		AdjustedResourceRequests: podResReq,
+	}
+	if lr != nil {
+		cpuFloor, cpuCeil, err = adjustedFloorCeilingFromRaw(
+			spec, "cpu", lr.ContainerDefaults.CPU,
+		)
 		if err != nil {
 			return nil, err
 		}
-		memFloor, memCeil, err := resourceFloorCeilingFromRaw(obj.Object, "memory")
+		memFloor, memCeil, err = adjustedFloorCeilingFromRaw(
+			spec, "memory", lr.ContainerDefaults.Memory,
+		)
 		if err != nil {
 			return nil, err
 		}
-		podResReq := types.ResourceRequests{
+		pod.AdjustedResourceRequests = types.ResourceRequests{
 			CPU: types.ResourceRequest{
 				Floor:   cpuFloor,
 				Ceiling: cpuCeil,
@@ -63,131 +174,397 @@ func Get(
 				Ceiling: memCeil,
 			},
 		}
-		pod := &types.Pod{
-			Cluster:          "default",
-			Name:             name,
-			Node:             nodeName,
-			Namespace:        ns,
-			ResourceRequests: podResReq,
+	}
+	return pod, nil
+}
+
+// containersFromRaw accepts a raw map of Pod spec fields and returns the
+// Pod's init, sidecar, app and ephemeral containers, in that order.
+func containersFromRaw(spec map[string]interface{}) ([]types.Container, error) {
+	res := []types.Container{}
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		ctrs, _, _ := unstructured.NestedSlice(spec, field)
+		for _, ctr := range ctrs {
+			ctrMap := ctr.(map[string]interface{})
+			name, _, _ := unstructured.NestedString(ctrMap, "name")
+			var ctrType types.ContainerType
+			switch field {
+			case "initContainers":
+				ctrType = types.ContainerTypeInit
+				restartPolicy, _, _ := unstructured.NestedString(ctrMap, "restartPolicy")
+				if restartPolicy == "Always" {
+					ctrType = types.ContainerTypeSidecar
+				}
+			case "containers":
+				ctrType = types.ContainerTypeApp
+			default:
+				ctrType = types.ContainerTypeEphemeral
+			}
+			reqs := types.ResourceRequests{}
+			for _, resType := range []string{"cpu", "memory"} {
+				rr := types.ResourceRequest{Floor: -1, Ceiling: -1}
+				amt, found, err := containerAmountFromRaw(ctrMap, "requests", resType)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					rr.Floor = amt
+				}
+				amt, found, err = containerAmountFromRaw(ctrMap, "limits", resType)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					rr.Ceiling = amt
+				}
+				if resType == "cpu" {
+					reqs.CPU = rr
+				} else {
+					reqs.Memory = rr
+				}
+			}
+			res = append(res, types.Container{
+				Name:             name,
+				Type:             ctrType,
+				ResourceRequests: reqs,
+				QOSClass:         containerQOSClass(reqs),
+			})
 		}
-		pods[x] = pod
 	}
-	return pods, nil
+	return res, nil
+}
+
+// containerQOSClass returns the QoS class a container's requests and limits
+// are compatible with.
+func containerQOSClass(reqs types.ResourceRequests) types.QOSClass {
+	cpu, mem := reqs.CPU, reqs.Memory
+	if cpu.Floor == -1 && cpu.Ceiling == -1 && mem.Floor == -1 && mem.Ceiling == -1 {
+		return types.QOSBestEffort
+	}
+	// If a limit is set but no request is, the API server defaults the
+	// request to the limit.
+	if cpu.Ceiling != -1 && mem.Ceiling != -1 &&
+		(cpu.Floor == -1 || cpu.Floor == cpu.Ceiling) &&
+		(mem.Floor == -1 || mem.Floor == mem.Ceiling) {
+		return types.QOSGuaranteed
+	}
+	return types.QOSBurstable
+}
+
+// podQOSClass returns the QoS class of a Pod with the supplied containers.
+// Ephemeral containers have no resources and do not affect the QoS class.
+func podQOSClass(containers []types.Container) types.QOSClass {
+	allGuaranteed, allBestEffort := true, true
+	for _, ctr := range containers {
+		if ctr.Type == types.ContainerTypeEphemeral {
+			continue
+		}
+		if ctr.QOSClass != types.QOSGuaranteed {
+			allGuaranteed = false
+		}
+		if ctr.QOSClass != types.QOSBestEffort {
+			allBestEffort = false
+		}
+	}
+	switch {
+	case allBestEffort:
+		return types.QOSBestEffort
+	case allGuaranteed:
+		return types.QOSGuaranteed
+	}
+	return types.QOSBurstable
+}
+
+// exclusiveCPUs returns the number of CPUs the static CPU manager policy
+// would grant exclusive use of to a Pod with the supplied containers: each
+// app or sidecar container of a Guaranteed Pod that requests a whole number
+// of CPUs gets that many exclusive CPUs. Init containers only run before the
+// app containers start and their CPUs are reused by them.
+func exclusiveCPUs(containers []types.Container) float64 {
+	if podQOSClass(containers) != types.QOSGuaranteed {
+		return 0
+	}
+	res := float64(0)
+	for _, ctr := range containers {
+		if ctr.Type != types.ContainerTypeApp && ctr.Type != types.ContainerTypeSidecar {
+			continue
+		}
+		cpu := ctr.ResourceRequests.CPU.Floor
+		if cpu == -1 {
+			// The API server defaults the request to the limit
+			cpu = ctr.ResourceRequests.CPU.Ceiling
+		}
+		if cpu > 0 && cpu == math.Trunc(cpu) {
+			res += cpu
+		}
+	}
+	return res
+}
+
+// requiredNodeAffinityFromRaw accepts a raw map of Pod spec fields and
+// returns the terms of the Pod's required node affinity.
+func requiredNodeAffinityFromRaw(spec map[string]interface{}) []types.NodeSelectorTerm {
+	terms, _, _ := unstructured.NestedSlice(
+		spec, "affinity", "nodeAffinity",
+		"requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms",
+	)
+	res := make([]types.NodeSelectorTerm, len(terms))
+	for x, term := range terms {
+		termMap, _ := term.(map[string]interface{})
+		res[x] = types.NodeSelectorTerm{
+			MatchExpressions: nodeSelectorRequirementsFromRaw(termMap, "matchExpressions"),
+			MatchFields:      nodeSelectorRequirementsFromRaw(termMap, "matchFields"),
+		}
+	}
+	return res
+}
+
+// nodeSelectorRequirementsFromRaw accepts a raw map of node selector term
+// fields and returns the requirements in the supplied field.
+func nodeSelectorRequirementsFromRaw(
+	term map[string]interface{},
+	field string,
+) []types.NodeSelectorRequirement {
+	reqs, _, _ := unstructured.NestedSlice(term, field)
+	res := make([]types.NodeSelectorRequirement, len(reqs))
+	for x, req := range reqs {
+		reqMap, _ := req.(map[string]interface{})
+		key, _, _ := unstructured.NestedString(reqMap, "key")
+		op, _, _ := unstructured.NestedString(reqMap, "operator")
+		vals, _, _ := unstructured.NestedStringSlice(reqMap, "values")
+		res[x] = types.NodeSelectorRequirement{
+			Key:      key,
+			Operator: op,
+			Values:   vals,
+		}
+	}
+	return res
+}
+
+// tolerationsFromRaw accepts a raw map of Pod spec fields and returns the
+// Pod's tolerations.
+func tolerationsFromRaw(spec map[string]interface{}) []types.Toleration {
+	tols, _, _ := unstructured.NestedSlice(spec, "tolerations")
+	res := make([]types.Toleration, len(tols))
+	for x, tol := range tols {
+		tolMap := tol.(map[string]interface{})
+		key, _, _ := unstructured.NestedString(tolMap, "key")
+		op, _, _ := unstructured.NestedString(tolMap, "operator")
+		val, _, _ := unstructured.NestedString(tolMap, "value")
+		effect, _, _ := unstructured.NestedString(tolMap, "effect")
+		res[x] = types.Toleration{
+			Key:      key,
+			Operator: op,
+			Value:    val,
+			Effect:   effect,
+		}
+	}
+	return res
+}
+
+// ControllerFromRaw accepts a raw map of Kubernetes object fields and
+// returns the kind and name of the object's controller, taken from the owner
+// reference with `controller: true`. Empty strings are returned if the
+// object has no controller.
+func ControllerFromRaw(obj map[string]interface{}) (string, string) {
+	refs, _, _ := unstructured.NestedSlice(obj, "metadata", "ownerReferences")
+	for _, ref := range refs {
+		refMap := ref.(map[string]interface{})
+		isController, _, _ := unstructured.NestedBool(refMap, "controller")
+		if !isController {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(refMap, "kind")
+		name, _, _ := unstructured.NestedString(refMap, "name")
+		return kind, name
+	}
+	return "", ""
 }
 
-// resourceFloorCeilingFromRaw accepts a raw map of Kubernetes object fields
-// and returns the floor and ceiling of a resource type's requests.
+// resourceFloorCeilingFromRaw accepts a raw map of Pod spec fields and
+// returns the floor and ceiling of a resource type's requests.
 func resourceFloorCeilingFromRaw(
-	obj map[string]interface{},
+	spec map[string]interface{},
+	resType string,
+) (float64, float64, error) {
+	return podFloorCeilingFromRaw(
+		spec, resType, false,
+		func(ctr map[string]interface{}) (float64, float64, bool, error) {
+			// The container's "requests" is the floor of requested resources.
+			req, _, err := containerAmountFromRaw(ctr, "requests", resType)
+			if err != nil {
+				return 0, 0, false, err
+			}
+			// The container's "limits" is the ceiling of requested resources.
+			lim, limFound, err := containerAmountFromRaw(ctr, "limits", resType)
+			if err != nil {
+				return 0, 0, false, err
+			}
+			return req, lim, limFound, nil
+		},
+	)
+}
+
+// adjustedFloorCeilingFromRaw accepts a raw map of Pod spec fields and a
+// LimitRange's default request (floor) and limit (ceiling) for a resource
+// type and returns the floor and ceiling of the resource type's requests
+// after applying the defaults the way the LimitRanger admission plugin
+// would:
+//
+//   - A container with no limit gets the default limit.
+//   - A container with no request gets the default request or, if there is
+//     no default request, its (possibly defaulted) limit.
+//
+// If any container still has no limit, the returned ceiling is -1.
+func adjustedFloorCeilingFromRaw(
+	spec map[string]interface{},
 	resType string,
+	defaults types.ResourceRequest,
 ) (float64, float64, error) {
-	floor, ceil := float64(0), float64(-1)
-	ctrs, _, _ := unstructured.NestedSlice(obj, "spec", "containers")
+	return podFloorCeilingFromRaw(
+		spec, resType, true,
+		func(ctr map[string]interface{}) (float64, float64, bool, error) {
+			lim, limFound, err := containerAmountFromRaw(ctr, "limits", resType)
+			if err != nil {
+				return 0, 0, false, err
+			}
+			if !limFound && defaults.Ceiling != -1 {
+				lim, limFound = defaults.Ceiling, true
+			}
+			req, reqFound, err := containerAmountFromRaw(ctr, "requests", resType)
+			if err != nil {
+				return 0, 0, false, err
+			}
+			if !reqFound {
+				if defaults.Floor != -1 {
+					req = defaults.Floor
+				} else if limFound {
+					req = lim
+				}
+			}
+			return req, lim, limFound, nil
+		},
+	)
+}
+
+// podFloorCeilingFromRaw accepts a raw map of Pod spec fields, a resource
+// type and a function returning a container's request, limit and whether
+// the limit was found, and returns the floor and ceiling of the Pod's
+// requests the way the scheduler computes them: the larger of the sum of
+// the app and sidecar containers' requests and the largest init container
+// request plus the requests of the sidecars started before it, plus the
+// Pod's overhead.
+//
+// The returned ceiling is -1 if no container has a limit or, if
+// allLimits is true, if any container has no limit.
+func podFloorCeilingFromRaw(
+	spec map[string]interface{},
+	resType string,
+	allLimits bool,
+	amounts func(ctr map[string]interface{}) (float64, float64, bool, error),
+) (float64, float64, error) {
+	ctrs, _, _ := unstructured.NestedSlice(spec, "containers")
 	if len(ctrs) == 0 {
 		return 0, 0, nil
 	}
-	for _, ctr := range ctrs {
-		// The container's "requests" is the floor of requested resources.
-		reqs, found, err := unstructured.NestedMap(ctr.(map[string]interface{}), "resources", "requests")
+	anyLimit, allLimit := false, true
+	floor, ceil := float64(0), float64(0)
+	sidecarFloor, sidecarCeil := float64(0), float64(0)
+	initFloor, initCeil := float64(0), float64(0)
+	inits, _, _ := unstructured.NestedSlice(spec, "initContainers")
+	for _, ctr := range inits {
+		ctrMap := ctr.(map[string]interface{})
+		req, lim, limFound, err := amounts(ctrMap)
 		if err != nil {
 			return 0, -1, err
 		}
-		if found || len(reqs) > 0 {
-			if amt, ok := reqs[resType]; ok {
-				switch resType {
-				case "memory":
-					{
-						// We need to convert any size strings for memory...
-						amtFloat := unit.SizeStringToBytes(amt.(string))
-						if err != nil {
-							return 0, -1, err
-						}
-						floor += amtFloat
-					}
-				case "cpu":
-					{
-						isMillicore := false
-						amtStr := strings.TrimSpace(amt.(string))
-						if amtStr[len(amtStr)-1] == 'm' {
-							isMillicore = true
-							amtStr = amtStr[0 : len(amtStr)-1]
-						}
-						amountInt, err := strconv.Atoi(amtStr)
-						if err != nil {
-							return 0, -1, err
-						}
-						if isMillicore {
-							floor += float64(amountInt) / 1000
-						} else {
-							floor += float64(amountInt)
-						}
-					}
-				default:
-					{
-						amountInt, err := strconv.Atoi(amt.(string))
-						if err != nil {
-							return 0, -1, err
-						}
-						floor += float64(amountInt)
-					}
-				}
-			}
+		anyLimit = anyLimit || limFound
+		allLimit = allLimit && limFound
+		restartPolicy, _, _ := unstructured.NestedString(ctrMap, "restartPolicy")
+		if restartPolicy == "Always" {
+			// Sidecars keep running alongside the init containers after
+			// them and the app containers
+			sidecarFloor += req
+			sidecarCeil += lim
+			initFloor = max(initFloor, sidecarFloor)
+			initCeil = max(initCeil, sidecarCeil)
+			continue
 		}
-		// The container's "limits" is the ceiling of requested resources.
-		limits, found, err := unstructured.NestedMap(ctr.(map[string]interface{}), "resources", "limits")
+		initFloor = max(initFloor, sidecarFloor+req)
+		initCeil = max(initCeil, sidecarCeil+lim)
+	}
+	for _, ctr := range ctrs {
+		req, lim, limFound, err := amounts(ctr.(map[string]interface{}))
 		if err != nil {
 			return 0, -1, err
 		}
-		if found || len(limits) > 0 {
-			if amt, ok := limits[resType]; ok {
-				switch resType {
-				case "memory":
-					{
-						// We need to convert any size strings for memory...
-						amtFloat := unit.SizeStringToBytes(amt.(string))
-						if err != nil {
-							return 0, -1, err
-						}
-						if ceil == -1 {
-							ceil = 0
-						}
-						ceil += amtFloat
-					}
-				case "cpu":
-					{
-						isMillicore := false
-						amtStr := strings.TrimSpace(amt.(string))
-						if amtStr[len(amtStr)-1] == 'm' {
-							isMillicore = true
-							amtStr = amtStr[0 : len(amtStr)-1]
-						}
-						amountInt, err := strconv.Atoi(amtStr)
-						if err != nil {
-							return 0, -1, err
-						}
-						if ceil == -1 {
-							ceil = 0
-						}
-						if isMillicore {
-							ceil += float64(amountInt) / 1000
-						} else {
-							ceil += float64(amountInt)
-						}
-					}
-				default:
-					{
-						amountInt, err := strconv.Atoi(amt.(string))
-						if err != nil {
-							return 0, -1, err
-						}
-						if ceil == -1 {
-							ceil = 0
-						}
-						ceil += float64(amountInt)
-					}
-				}
-			}
-		}
+		anyLimit = anyLimit || limFound
+		allLimit = allLimit && limFound
+		floor += req
+		ceil += lim
+	}
+	overheads, _, err := unstructured.NestedMap(spec, "overhead")
+	if err != nil {
+		return 0, -1, err
+	}
+	overhead, _, err := amountFromRaw(overheads, resType)
+	if err != nil {
+		return 0, -1, err
+	}
+	floor = max(floor+sidecarFloor, initFloor) + overhead
+	ceil = max(ceil+sidecarCeil, initCeil) + overhead
+	if !anyLimit || (allLimits && !allLimit) {
+		ceil = -1
 	}
 	return floor, ceil, nil
 }
+
+// containerAmountFromRaw accepts a raw map of container fields, a category
+// ("requests" or "limits") and a resource type and returns the amount of
+// that resource type in that category and whether the amount was found.
+func containerAmountFromRaw(
+	ctr map[string]interface{},
+	category string,
+	resType string,
+) (float64, bool, error) {
+	amounts, _, err := unstructured.NestedMap(ctr, "resources", category)
+	if err != nil {
+		return 0, false, err
+	}
+	return amountFromRaw(amounts, resType)
+}
+
+// amountFromRaw accepts a raw map of resource amounts keyed by resource
+// type and returns the amount of the supplied resource type and whether the
+// amount was found.
+func amountFromRaw(
+	amounts map[string]interface{},
+	resType string,
+) (float64, bool, error) {
+	amt, ok := amounts[resType]
+	if !ok {
+		return 0, false, nil
+	}
+	amtStr, ok := amt.(string)
+	if !ok {
+		// Manifests may contain unquoted integer amounts
+		amtStr = fmt.Sprintf("%v", amt)
+	}
+	switch resType {
+	case "memory":
+		// We need to convert any size strings for memory...
+		return unit.SizeStringToBytes(amtStr), true, nil
+	case "cpu":
+		cores, err := unit.CPUStringToCores(amtStr)
+		if err != nil {
+			return 0, false, err
+		}
+		return cores, true, nil
+	default:
+		amountInt, err := strconv.Atoi(amtStr)
+		if err != nil {
+			return 0, false, err
+		}
+		return float64(amountInt), true, nil
+	}
+}
@@ -0,0 +1,81 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package pod
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+var (
+	// ErrManifestNoPodSpec is returned when a manifest contains an object
+	// that has no Pod spec
+	ErrManifestNoPodSpec = fmt.Errorf(
+		"%w: manifest object has no pod spec",
+		kwerrors.RuntimeError,
+	)
+)
+
+// FromManifest reads a YAML or JSON manifest containing one or more Pods or
+// objects with a Pod template (e.g. Deployments, StatefulSets, Jobs) and
+// returns a `Pod` for each object. The returned Pods are not running on any
+// Node. The supplied map of LimitRanges, keyed by namespace, is used to
+// calculate each Pod's AdjustedResourceRequests; objects with no namespace
+// are treated as being in the "default" namespace.
+func FromManifest(
+	manifest []byte,
+	nsLimitRanges map[string]*types.LimitRange,
+) ([]*types.Pod, error) {
+	pods := []*types.Pod{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	for {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		kind, _, _ := unstructured.NestedString(obj, "kind")
+		ns, _, _ := unstructured.NestedString(obj, "metadata", "namespace")
+		if ns == "" {
+			ns = "default"
+			_ = unstructured.SetNestedField(obj, ns, "metadata", "namespace")
+		}
+		var spec map[string]interface{}
+		var found bool
+		switch kind {
+		case "Pod":
+			spec, found, _ = unstructured.NestedMap(obj, "spec")
+		case "CronJob":
+			spec, found, _ = unstructured.NestedMap(
+				obj, "spec", "jobTemplate", "spec", "template", "spec",
+			)
+		default:
+			spec, found, _ = unstructured.NestedMap(obj, "spec", "template", "spec")
+		}
+		if !found {
+			name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+			return nil, fmt.Errorf("%w: %s/%s", ErrManifestNoPodSpec, kind, name)
+		}
+		pod, err := podFromRaw(obj, spec, nsLimitRanges[ns])
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
@@ -0,0 +1,225 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package pod_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+const unboundedManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: unbounded
+  namespace: web
+spec:
+  initContainers:
+  - name: proxy
+    image: envoy
+    restartPolicy: Always
+    resources:
+      requests:
+        cpu: 500m
+        memory: 64Mi
+      limits:
+        cpu: 500m
+        memory: 64Mi
+  containers:
+  - name: app
+    image: nginx
+  - name: sidecar
+    image: envoy
+    resources:
+      requests:
+        cpu: 250m
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: other
+  namespace: batch
+spec:
+  containers:
+  - name: app
+    image: nginx
+`
+
+const initManifest = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: init
+  namespace: batch
+spec:
+  overhead:
+    cpu: 250m
+    memory: 120Mi
+  affinity:
+    nodeAffinity:
+      requiredDuringSchedulingIgnoredDuringExecution:
+        nodeSelectorTerms:
+        - matchExpressions:
+          - key: disktype
+            operator: In
+            values:
+            - ssd
+  initContainers:
+  - name: proxy
+    image: envoy
+    restartPolicy: Always
+    resources:
+      requests:
+        cpu: 100m
+        memory: 32Mi
+  - name: migrate
+    image: migrate
+    resources:
+      requests:
+        cpu: 2
+        memory: 1Gi
+  containers:
+  - name: app
+    image: nginx
+    resources:
+      requests:
+        cpu: 500m
+        memory: 128Mi
+`
+
+func TestFromManifest(t *testing.T) {
+	manifest, err := os.ReadFile(
+		filepath.Join("..", "..", "..", "test", "testdata", "nginx-deployment.yaml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %s", err)
+	}
+	pods, err := kpod.FromManifest(manifest, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("expected 1 pod but got %d", len(pods))
+	}
+	p := pods[0]
+	if p.Name != "nginx-deployment" || p.Namespace != "default" {
+		t.Fatalf("expected default/nginx-deployment but got %s/%s", p.Namespace, p.Name)
+	}
+	exp := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 0.1, Ceiling: 0.2},
+		Memory: types.ResourceRequest{Floor: 64 * unit.Mi, Ceiling: 128 * unit.Mi},
+	}
+	if p.ResourceRequests != exp {
+		t.Fatalf("expected %+v but got %+v", exp, p.ResourceRequests)
+	}
+	if p.AdjustedResourceRequests != exp {
+		t.Fatalf("expected %+v but got %+v", exp, p.AdjustedResourceRequests)
+	}
+}
+
+func TestFromManifestLimitRangeDefaults(t *testing.T) {
+	ranges := map[string]*types.LimitRange{
+		"web": {
+			Namespace: "web",
+			ContainerDefaults: types.ResourceRequests{
+				CPU:    types.ResourceRequest{Floor: -1, Ceiling: 1},
+				Memory: types.ResourceRequest{Floor: 64 * unit.Mi, Ceiling: 256 * unit.Mi},
+			},
+		},
+	}
+	pods, err := kpod.FromManifest([]byte(unboundedManifest), ranges)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(pods) != 2 {
+		t.Fatalf("expected 2 pods but got %d", len(pods))
+	}
+
+	web := pods[0]
+	// Only the proxy sidecar has limits
+	exp := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 0.75, Ceiling: 0.5},
+		Memory: types.ResourceRequest{Floor: 64 * unit.Mi, Ceiling: 64 * unit.Mi},
+	}
+	if web.ResourceRequests != exp {
+		t.Fatalf("expected %+v but got %+v", exp, web.ResourceRequests)
+	}
+	// The app container gets the default CPU limit and, with no default CPU
+	// request, a request equal to that limit. The sidecar keeps its own
+	// request and gets the default limit. The proxy keeps its own requests
+	// and limits.
+	exp = types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 1.75, Ceiling: 2.5},
+		Memory: types.ResourceRequest{Floor: 192 * unit.Mi, Ceiling: 576 * unit.Mi},
+	}
+	if web.AdjustedResourceRequests != exp {
+		t.Fatalf("expected %+v but got %+v", exp, web.AdjustedResourceRequests)
+	}
+
+	expCtrs := []struct {
+		name    string
+		ctrType types.ContainerType
+		qos     types.QOSClass
+	}{
+		{"proxy", types.ContainerTypeSidecar, types.QOSGuaranteed},
+		{"app", types.ContainerTypeApp, types.QOSBestEffort},
+		{"sidecar", types.ContainerTypeApp, types.QOSBurstable},
+	}
+	if len(web.Containers) != len(expCtrs) {
+		t.Fatalf("expected %d containers but got %d", len(expCtrs), len(web.Containers))
+	}
+	for x, exp := range expCtrs {
+		ctr := web.Containers[x]
+		if ctr.Name != exp.name || ctr.Type != exp.ctrType || ctr.QOSClass != exp.qos {
+			t.Fatalf("expected %+v but got %+v", exp, ctr)
+		}
+	}
+
+	// There is no LimitRange in the batch namespace
+	batch := pods[1]
+	if batch.AdjustedResourceRequests != batch.ResourceRequests {
+		t.Fatalf(
+			"expected %+v but got %+v",
+			batch.ResourceRequests, batch.AdjustedResourceRequests,
+		)
+	}
+}
+
+func TestFromManifestSchedulingFields(t *testing.T) {
+	pods, err := kpod.FromManifest([]byte(initManifest), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("expected 1 pod but got %d", len(pods))
+	}
+	// The migrate init container runs alongside the proxy sidecar started
+	// before it and requests more than the app container and the sidecar.
+	// The overhead is added on top.
+	exp := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 2.35, Ceiling: -1},
+		Memory: types.ResourceRequest{Floor: 1176 * unit.Mi, Ceiling: -1},
+	}
+	if pods[0].ResourceRequests != exp {
+		t.Fatalf("expected %+v but got %+v", exp, pods[0].ResourceRequests)
+	}
+	expAffinity := []types.NodeSelectorTerm{
+		{
+			MatchExpressions: []types.NodeSelectorRequirement{
+				{Key: "disktype", Operator: "In", Values: []string{"ssd"}},
+			},
+			MatchFields: []types.NodeSelectorRequirement{},
+		},
+	}
+	if !reflect.DeepEqual(pods[0].RequiredNodeAffinity, expAffinity) {
+		t.Fatalf("expected %+v but got %+v", expAffinity, pods[0].RequiredNodeAffinity)
+	}
+}
@@ -0,0 +1,72 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package pod
+
+import (
+	"sort"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+var (
+	// SortKeys contains the keys Sort can sort Pods by
+	SortKeys = []string{
+		"namespace", "name", "cpu-floor", "cpu-ceil", "memory-floor", "memory-ceil",
+	}
+)
+
+// Sort sorts the supplied Pods in place by the supplied sort key, one of
+// SortKeys. Resource amounts are sorted largest first, with unbounded
+// ceilings largest of all. Pods are left in their original order if the sort
+// key is empty or unknown.
+func Sort(pods []*types.Pod, key string) {
+	var less func(a, b *types.Pod) bool
+	switch key {
+	case "namespace":
+		less = func(a, b *types.Pod) bool {
+			if a.Namespace == b.Namespace {
+				return a.Name < b.Name
+			}
+			return a.Namespace < b.Namespace
+		}
+	case "name":
+		less = func(a, b *types.Pod) bool {
+			return a.Name < b.Name
+		}
+	case "cpu-floor":
+		less = func(a, b *types.Pod) bool {
+			return a.ResourceRequests.CPU.Floor > b.ResourceRequests.CPU.Floor
+		}
+	case "cpu-ceil":
+		less = func(a, b *types.Pod) bool {
+			return ceilingGreater(a.ResourceRequests.CPU.Ceiling, b.ResourceRequests.CPU.Ceiling)
+		}
+	case "memory-floor":
+		less = func(a, b *types.Pod) bool {
+			return a.ResourceRequests.Memory.Floor > b.ResourceRequests.Memory.Floor
+		}
+	case "memory-ceil":
+		less = func(a, b *types.Pod) bool {
+			return ceilingGreater(a.ResourceRequests.Memory.Ceiling, b.ResourceRequests.Memory.Ceiling)
+		}
+	default:
+		return
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		return less(pods[i], pods[j])
+	})
+}
+
+// ceilingGreater returns true if ceiling a is greater than ceiling b, where
+// -1 means unbounded and is greater than any other ceiling.
+func ceilingGreater(a, b float64) bool {
+	if a == -1 {
+		return b != -1
+	}
+	if b == -1 {
+		return false
+	}
+	return a > b
+}
@@ -0,0 +1,150 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package quota
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+var (
+	quotaGVK = schema.GroupVersionKind{
+		Kind: "ResourceQuota",
+	}
+)
+
+// Get returns a slice of `ResourceQuota` objects contained in a Kubernetes
+// cluster.
+func Get(
+	ctx context.Context,
+	c *kconnect.Connection,
+) ([]*types.ResourceQuota, error) {
+	gvrQuota, err := c.GVR(quotaGVK)
+	if err != nil {
+		return nil, err
+	}
+	opts := metav1.ListOptions{}
+	list, err := c.Client().Resource(gvrQuota).List(
+		ctx, opts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	quotas := make([]*types.ResourceQuota, len(list.Items))
+	for x, obj := range list.Items {
+		name, _, _ := unstructured.NestedString(obj.Object, "metadata", "name")
+		ns, _, _ := unstructured.NestedString(obj.Object, "metadata", "namespace")
+		// status.hard is the enforced set of hard limits, but it may not yet
+		// be populated on a freshly-created ResourceQuota, so fall back to
+		// spec.hard.
+		hardMap, found, _ := unstructured.NestedStringMap(obj.Object, "status", "hard")
+		if !found {
+			hardMap, _, _ = unstructured.NestedStringMap(obj.Object, "spec", "hard")
+		}
+		usedMap, _, _ := unstructured.NestedStringMap(obj.Object, "status", "used")
+		hard, err := resourceRequestsFromQuotaMap(hardMap)
+		if err != nil {
+			return nil, err
+		}
+		used, err := resourceRequestsFromQuotaMap(usedMap)
+		if err != nil {
+			return nil, err
+		}
+		quotas[x] = &types.ResourceQuota{
+			Cluster:   "default",
+			Namespace: ns,
+			Name:      name,
+			Hard:      hard,
+			Used:      used,
+		}
+	}
+	return quotas, nil
+}
+
+// ByNamespace returns a map, keyed by namespace, of the combined
+// `ResourceQuota` for each namespace. When multiple ResourceQuotas constrain
+// the same amount in a namespace, all of them must be satisfied, so the
+// combined hard limit is the lowest of the hard limits.
+func ByNamespace(
+	quotas []*types.ResourceQuota,
+) map[string]*types.ResourceQuota {
+	res := map[string]*types.ResourceQuota{}
+	for _, q := range quotas {
+		combined, ok := res[q.Namespace]
+		if !ok {
+			cq := *q
+			res[q.Namespace] = &cq
+			continue
+		}
+		combined.Name += "," + q.Name
+		combineHard(&combined.Hard.CPU, q.Hard.CPU)
+		combineHard(&combined.Hard.Memory, q.Hard.Memory)
+		combineUsed(&combined.Used.CPU, q.Used.CPU)
+		combineUsed(&combined.Used.Memory, q.Used.Memory)
+	}
+	return res
+}
+
+// combineHard sets the floor and ceiling of the supplied destination request
+// to the lowest of the two hard limits, where -1 means unlimited.
+func combineHard(dst *types.ResourceRequest, src types.ResourceRequest) {
+	if dst.Floor == -1 || (src.Floor != -1 && src.Floor < dst.Floor) {
+		dst.Floor = src.Floor
+	}
+	if dst.Ceiling == -1 || (src.Ceiling != -1 && src.Ceiling < dst.Ceiling) {
+		dst.Ceiling = src.Ceiling
+	}
+}
+
+// combineUsed sets the floor and ceiling of the supplied destination request
+// to the highest of the two used amounts. All ResourceQuotas in a namespace
+// track the same consumption, so these only differ when a quota has not
+// been recalculated yet or does not track the amount at all (-1).
+func combineUsed(dst *types.ResourceRequest, src types.ResourceRequest) {
+	dst.Floor = max(dst.Floor, src.Floor)
+	dst.Ceiling = max(dst.Ceiling, src.Ceiling)
+}
+
+// resourceRequestsFromQuotaMap accepts a map of ResourceQuota resource names
+// to quantity strings and returns a `ResourceRequests` with the Floor set to
+// the requests amount and the Ceiling set to the limits amount. Amounts not
+// in the map are set to -1.
+func resourceRequestsFromQuotaMap(
+	m map[string]string,
+) (types.ResourceRequests, error) {
+	res := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: -1, Ceiling: -1},
+		Memory: types.ResourceRequest{Floor: -1, Ceiling: -1},
+	}
+	for key, amt := range m {
+		var dst *float64
+		switch key {
+		case "cpu", "requests.cpu":
+			dst = &res.CPU.Floor
+		case "limits.cpu":
+			dst = &res.CPU.Ceiling
+		case "memory", "requests.memory":
+			dst = &res.Memory.Floor
+		case "limits.memory":
+			dst = &res.Memory.Ceiling
+		default:
+			continue
+		}
+		q, err := resource.ParseQuantity(amt)
+		if err != nil {
+			return res, fmt.Errorf("invalid %s %q: %w", key, amt, err)
+		}
+		*dst = q.AsApproximateFloat64()
+	}
+	return res, nil
+}
@@ -0,0 +1,137 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package workload
+
+import (
+	"context"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kconnect "github.com/jaypipes/kwiz/pkg/kube/connect"
+	kpod "github.com/jaypipes/kwiz/pkg/kube/pod"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+var (
+	replicaSetGVK = schema.GroupVersionKind{
+		Kind: "ReplicaSet",
+	}
+	jobGVK = schema.GroupVersionKind{
+		Kind: "Job",
+	}
+)
+
+// owner is the kind and name of an object's controller
+type owner struct {
+	kind string
+	name string
+}
+
+// Get returns a slice of `Workload` objects contained in a Kubernetes
+// cluster, built by following the controller owner references of every Pod
+// up to its top-level controller (e.g. Pod -> ReplicaSet -> Deployment).
+func Get(
+	ctx context.Context,
+	c *kconnect.Connection,
+) ([]*types.Workload, error) {
+	pods, err := kpod.Get(ctx, c, &kpod.PodGetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// ReplicaSets are typically owned by Deployments and Jobs are typically
+	// owned by CronJobs, so we need to look up their owners as well.
+	owners := map[string]owner{}
+	for _, gvk := range []schema.GroupVersionKind{replicaSetGVK, jobGVK} {
+		if err = addOwners(ctx, c, gvk, owners); err != nil {
+			return nil, err
+		}
+	}
+
+	byKey := map[string]*types.Workload{}
+	keys := []string{}
+	for _, p := range pods {
+		o := owner{kind: p.OwnerKind, name: p.OwnerName}
+		if o.kind == "" {
+			o = owner{kind: "Pod", name: p.Name}
+		} else if parent, ok := owners[ownerKey(p.Namespace, o.kind, o.name)]; ok {
+			o = parent
+		}
+		key := ownerKey(p.Namespace, o.kind, o.name)
+		w, ok := byKey[key]
+		if !ok {
+			w = &types.Workload{
+				Cluster:    "default",
+				Namespace:  p.Namespace,
+				Kind:       o.kind,
+				Name:       o.name,
+				PerReplica: p.ResourceRequests,
+			}
+			byKey[key] = w
+			keys = append(keys, key)
+		}
+		w.Pods = append(w.Pods, p)
+		w.ResourceRequests.Add(p.ResourceRequests)
+		if p.Node != "" {
+			w.Nodes = append(w.Nodes, p.Node)
+		}
+	}
+	sort.Strings(keys)
+	workloads := make([]*types.Workload, len(keys))
+	for x, key := range keys {
+		w := byKey[key]
+		w.Nodes = uniqueSorted(w.Nodes)
+		workloads[x] = w
+	}
+	return workloads, nil
+}
+
+// addOwners lists all objects of the supplied kind and adds the controller
+// of each object to the supplied map, keyed by the object's namespace, kind
+// and name.
+func addOwners(
+	ctx context.Context,
+	c *kconnect.Connection,
+	gvk schema.GroupVersionKind,
+	owners map[string]owner,
+) error {
+	gvr, err := c.GVR(gvk)
+	if err != nil {
+		return err
+	}
+	list, err := c.Client().Resource(gvr).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, obj := range list.Items {
+		kind, name := kpod.ControllerFromRaw(obj.Object)
+		if kind == "" {
+			continue
+		}
+		ns, _, _ := unstructured.NestedString(obj.Object, "metadata", "namespace")
+		objName, _, _ := unstructured.NestedString(obj.Object, "metadata", "name")
+		owners[ownerKey(ns, gvk.Kind, objName)] = owner{kind: kind, name: name}
+	}
+	return nil
+}
+
+// ownerKey returns a map key for an object's namespace, kind and name
+func ownerKey(ns string, kind string, name string) string {
+	return ns + "/" + kind + "/" + name
+}
+
+// uniqueSorted returns the sorted, unique strings in the supplied slice
+func uniqueSorted(s []string) []string {
+	sort.Strings(s)
+	res := []string{}
+	for x, v := range s {
+		if x == 0 || v != s[x-1] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
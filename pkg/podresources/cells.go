@@ -0,0 +1,151 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package podresources
+
+import (
+	"sort"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// memoryTypeRegular is the PodResources memory type for regular
+	// (non-hugepage) memory
+	memoryTypeRegular = "memory"
+)
+
+// ApplyToCells sets the per-NUMA cell allocations of the supplied NUMA cells
+// from the supplied allocatable resources and container assignments returned
+// by the kubelet's PodResources API:
+//
+//   - the allocated CPUs, and the allocatable and exclusively requested CPU
+//     amounts, of cells with a known CPU topology.
+//   - the allocatable and exclusively requested regular memory, if the
+//     memory manager is enabled. Memory assigned from more than one cell is
+//     split evenly between them.
+//   - the total and allocated devices of each extended resource. A device
+//     with affinity to more than one cell is counted in each of them.
+func ApplyToCells(
+	cells []types.NUMACell,
+	alloc *Allocatable,
+	assignments []types.ContainerAssignment,
+) {
+	assignedCPUs := map[int]bool{}
+	for _, a := range assignments {
+		for _, cpu := range a.CPUs {
+			assignedCPUs[cpu] = true
+		}
+	}
+	allocatableCPUs := map[int]bool{}
+	for _, cpu := range alloc.CPUs {
+		allocatableCPUs[cpu] = true
+	}
+	for x := range cells {
+		cell := &cells[x]
+		if len(cell.Cores) == 0 {
+			continue
+		}
+		cell.AllocatedCPUs = []int{}
+		allocatable := 0
+		for _, core := range cell.Cores {
+			for _, cpu := range core.CPUs {
+				if assignedCPUs[cpu] {
+					cell.AllocatedCPUs = append(cell.AllocatedCPUs, cpu)
+				}
+				if allocatableCPUs[cpu] {
+					allocatable++
+				}
+			}
+		}
+		sort.Ints(cell.AllocatedCPUs)
+		cell.Resources.CPU.ExclusiveRequestedFloor = float64(len(cell.AllocatedCPUs))
+		if len(alloc.CPUs) > 0 {
+			cell.Resources.CPU.Allocatable = float64(allocatable)
+		}
+	}
+
+	if len(alloc.Memory) > 0 {
+		applyMemory(cells, alloc.Memory, assignments)
+	}
+	applyDevices(cells, alloc.Devices, assignments)
+}
+
+// applyMemory sets the allocatable and exclusively requested regular memory
+// of the supplied NUMA cells
+func applyMemory(
+	cells []types.NUMACell,
+	allocatable []types.MemoryBlock,
+	assignments []types.ContainerAssignment,
+) {
+	cellAllocatable := map[int]float64{}
+	for _, b := range allocatable {
+		if b.Type != memoryTypeRegular {
+			continue
+		}
+		for _, id := range b.NUMACells {
+			cellAllocatable[id] += b.Size / float64(len(b.NUMACells))
+		}
+	}
+	cellAssigned := map[int]float64{}
+	for _, a := range assignments {
+		for _, b := range a.Memory {
+			if b.Type != memoryTypeRegular {
+				continue
+			}
+			for _, id := range b.NUMACells {
+				cellAssigned[id] += b.Size / float64(len(b.NUMACells))
+			}
+		}
+	}
+	for x := range cells {
+		cell := &cells[x]
+		if amt, ok := cellAllocatable[cell.ID]; ok {
+			cell.Resources.Memory.Allocatable = amt
+		}
+		cell.Resources.Memory.ExclusiveRequestedFloor = cellAssigned[cell.ID]
+	}
+}
+
+// applyDevices sets the total and allocated devices of each extended
+// resource of the supplied NUMA cells
+func applyDevices(
+	cells []types.NUMACell,
+	allocatable []types.DeviceAssignment,
+	assignments []types.ContainerAssignment,
+) {
+	// Keyed by NUMA cell ID, then by resource name
+	devs := map[int]map[string]*types.Devices{}
+	get := func(cellID int, resName string) *types.Devices {
+		if _, ok := devs[cellID]; !ok {
+			devs[cellID] = map[string]*types.Devices{}
+		}
+		if _, ok := devs[cellID][resName]; !ok {
+			devs[cellID][resName] = &types.Devices{ResourceName: resName}
+		}
+		return devs[cellID][resName]
+	}
+	for _, d := range allocatable {
+		for _, id := range d.NUMACells {
+			get(id, d.ResourceName).Total += len(d.DeviceIDs)
+		}
+	}
+	for _, a := range assignments {
+		for _, d := range a.Devices {
+			for _, id := range d.NUMACells {
+				get(id, d.ResourceName).Allocated += len(d.DeviceIDs)
+			}
+		}
+	}
+	for x := range cells {
+		cell := &cells[x]
+		cell.Devices = []types.Devices{}
+		for _, d := range devs[cell.ID] {
+			cell.Devices = append(cell.Devices, *d)
+		}
+		sort.Slice(cell.Devices, func(i, j int) bool {
+			return cell.Devices[i].ResourceName < cell.Devices[j].ResourceName
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package podresources
+
+import (
+	"context"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	podresourcesv1 "k8s.io/kubelet/pkg/apis/podresources/v1"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// DefaultSocket is the default path of the unix socket the kubelet
+	// serves the PodResources API on
+	DefaultSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+	// maxMsgSize is the largest response accepted from the kubelet, the
+	// same limit the kubelet's own PodResources client uses
+	maxMsgSize = 16 * 1024 * 1024
+)
+
+// Allocatable contains the CPUs, NUMA-local memory and devices the kubelet
+// can assign to containers
+type Allocatable struct {
+	// CPUs contains the IDs of the logical CPUs the CPU manager can assign
+	// exclusively. Empty if the CPU manager's policy is "none".
+	CPUs []int
+	// Memory contains the blocks of memory the memory manager can assign,
+	// one for each memory type and NUMA cell. Empty if the memory manager's
+	// policy is "None".
+	Memory []types.MemoryBlock
+	// Devices contains the devices device plugins can assign
+	Devices []types.DeviceAssignment
+}
+
+// Client queries the kubelet's PodResources API over its unix socket
+type Client struct {
+	socket string
+	conn   *grpc.ClientConn
+	client podresourcesv1.PodResourcesListerClient
+}
+
+// Connect returns a Client for the kubelet PodResources API served on the
+// supplied unix socket (DefaultSocket if empty). The socket is not dialed
+// until the first call, so an unreachable kubelet is only reported then.
+func Connect(socket string) (*Client, error) {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	conn, err := grpc.Dial(
+		"unix://"+socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize)),
+	)
+	if err != nil {
+		return nil, PodResourcesUnavailable(socket, err.Error())
+	}
+	return &Client{
+		socket: socket,
+		conn:   conn,
+		client: podresourcesv1.NewPodResourcesListerClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the kubelet
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// List returns the CPUs, memory and devices assigned to every container
+// running on the Node, sorted by Pod namespace, Pod name and container name.
+// Containers with nothing assigned are omitted.
+func (c *Client) List(ctx context.Context) ([]types.ContainerAssignment, error) {
+	resp, err := c.client.List(ctx, &podresourcesv1.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, PodResourcesUnavailable(c.socket, err.Error())
+	}
+	res := []types.ContainerAssignment{}
+	for _, pod := range resp.GetPodResources() {
+		for _, ctr := range pod.GetContainers() {
+			a := types.ContainerAssignment{
+				PodNamespace:  pod.GetNamespace(),
+				PodName:       pod.GetName(),
+				ContainerName: ctr.GetName(),
+				CPUs:          cpuIDs(ctr.GetCpuIds()),
+				Memory:        memoryBlocks(ctr.GetMemory()),
+				Devices:       deviceAssignments(ctr.GetDevices()),
+			}
+			if len(a.CPUs) == 0 && len(a.Memory) == 0 && len(a.Devices) == 0 {
+				continue
+			}
+			res = append(res, a)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.PodNamespace != b.PodNamespace {
+			return a.PodNamespace < b.PodNamespace
+		}
+		if a.PodName != b.PodName {
+			return a.PodName < b.PodName
+		}
+		return a.ContainerName < b.ContainerName
+	})
+	return res, nil
+}
+
+// Allocatable returns the CPUs, memory and devices the kubelet can assign
+// to containers
+func (c *Client) Allocatable(ctx context.Context) (*Allocatable, error) {
+	resp, err := c.client.GetAllocatableResources(
+		ctx, &podresourcesv1.AllocatableResourcesRequest{},
+	)
+	if err != nil {
+		return nil, PodResourcesUnavailable(c.socket, err.Error())
+	}
+	return &Allocatable{
+		CPUs:    cpuIDs(resp.GetCpuIds()),
+		Memory:  memoryBlocks(resp.GetMemory()),
+		Devices: deviceAssignments(resp.GetDevices()),
+	}, nil
+}
+
+// cpuIDs returns the supplied CPU IDs as a sorted slice of ints
+func cpuIDs(ids []int64) []int {
+	res := make([]int, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, int(id))
+	}
+	sort.Ints(res)
+	return res
+}
+
+// numaCells returns the sorted IDs of the NUMA cells in the supplied
+// topology
+func numaCells(topo *podresourcesv1.TopologyInfo) []int {
+	res := []int{}
+	for _, n := range topo.GetNodes() {
+		res = append(res, int(n.GetID()))
+	}
+	sort.Ints(res)
+	return res
+}
+
+// memoryBlocks returns the supplied PodResources memory as MemoryBlocks
+func memoryBlocks(mem []*podresourcesv1.ContainerMemory) []types.MemoryBlock {
+	res := []types.MemoryBlock{}
+	for _, m := range mem {
+		res = append(res, types.MemoryBlock{
+			NUMACells: numaCells(m.GetTopology()),
+			Type:      m.GetMemoryType(),
+			Size:      float64(m.GetSize_()),
+		})
+	}
+	return res
+}
+
+// deviceAssignments returns the supplied PodResources devices as
+// DeviceAssignments
+func deviceAssignments(devs []*podresourcesv1.ContainerDevices) []types.DeviceAssignment {
+	res := []types.DeviceAssignment{}
+	for _, d := range devs {
+		res = append(res, types.DeviceAssignment{
+			ResourceName: d.GetResourceName(),
+			DeviceIDs:    d.GetDeviceIds(),
+			NUMACells:    numaCells(d.GetTopology()),
+		})
+	}
+	return res
+}
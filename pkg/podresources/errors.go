@@ -0,0 +1,26 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package podresources
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrPodResourcesUnavailable is returned when the kubelet's PodResources
+	// API cannot be queried.
+	ErrPodResourcesUnavailable = fmt.Errorf(
+		"%w: kubelet PodResources API unavailable",
+		kwerrors.RuntimeError,
+	)
+)
+
+// PodResourcesUnavailable returns ErrPodResourcesUnavailable with some
+// further context
+func PodResourcesUnavailable(socket string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrPodResourcesUnavailable, socket, reason)
+}
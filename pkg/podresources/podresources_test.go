@@ -0,0 +1,266 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package podresources_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	podresourcesv1 "k8s.io/kubelet/pkg/apis/podresources/v1"
+
+	"github.com/jaypipes/kwiz/pkg/podresources"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+// fakeKubelet serves canned PodResources API responses
+type fakeKubelet struct {
+	podresourcesv1.UnimplementedPodResourcesListerServer
+}
+
+func topology(ids ...int64) *podresourcesv1.TopologyInfo {
+	res := &podresourcesv1.TopologyInfo{}
+	for _, id := range ids {
+		res.Nodes = append(res.Nodes, &podresourcesv1.NUMANode{ID: id})
+	}
+	return res
+}
+
+func (f *fakeKubelet) List(
+	ctx context.Context,
+	req *podresourcesv1.ListPodResourcesRequest,
+) (*podresourcesv1.ListPodResourcesResponse, error) {
+	return &podresourcesv1.ListPodResourcesResponse{
+		PodResources: []*podresourcesv1.PodResources{
+			{
+				Namespace: "ml",
+				Name:      "trainer",
+				Containers: []*podresourcesv1.ContainerResources{
+					{
+						Name:   "train",
+						CpuIds: []int64{5, 4},
+						Memory: []*podresourcesv1.ContainerMemory{
+							{
+								MemoryType: "memory",
+								Size_:      uint64(4 * unit.Gi),
+								Topology:   topology(1),
+							},
+						},
+						Devices: []*podresourcesv1.ContainerDevices{
+							{
+								ResourceName: "nvidia.com/gpu",
+								DeviceIds:    []string{"gpu-1"},
+								Topology:     topology(1),
+							},
+						},
+					},
+					// Containers with nothing assigned are omitted
+					{Name: "sidecar"},
+				},
+			},
+			{
+				Namespace: "db",
+				Name:      "postgres",
+				Containers: []*podresourcesv1.ContainerResources{
+					{
+						Name:   "postgres",
+						CpuIds: []int64{0, 1},
+						Memory: []*podresourcesv1.ContainerMemory{
+							{
+								MemoryType: "memory",
+								Size_:      uint64(2 * unit.Gi),
+								Topology:   topology(0, 1),
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (f *fakeKubelet) GetAllocatableResources(
+	ctx context.Context,
+	req *podresourcesv1.AllocatableResourcesRequest,
+) (*podresourcesv1.AllocatableResourcesResponse, error) {
+	return &podresourcesv1.AllocatableResourcesResponse{
+		// CPUs 3 and 7 are reserved for system daemons
+		CpuIds: []int64{0, 1, 2, 4, 5, 6},
+		Memory: []*podresourcesv1.ContainerMemory{
+			{MemoryType: "memory", Size_: uint64(15 * unit.Gi), Topology: topology(0)},
+			{MemoryType: "memory", Size_: uint64(16 * unit.Gi), Topology: topology(1)},
+			{MemoryType: "hugepages-1Gi", Size_: uint64(2 * unit.Gi), Topology: topology(1)},
+		},
+		Devices: []*podresourcesv1.ContainerDevices{
+			{
+				ResourceName: "nvidia.com/gpu",
+				DeviceIds:    []string{"gpu-0"},
+				Topology:     topology(0),
+			},
+			{
+				ResourceName: "nvidia.com/gpu",
+				DeviceIds:    []string{"gpu-1", "gpu-2"},
+				Topology:     topology(1),
+			},
+		},
+	}, nil
+}
+
+// startFakeKubelet serves the fake kubelet's PodResources API on a unix
+// socket in a temporary directory and returns the socket's path
+func startFakeKubelet(t *testing.T) string {
+	socket := filepath.Join(t.TempDir(), "kubelet.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	podresourcesv1.RegisterPodResourcesListerServer(srv, &fakeKubelet{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return socket
+}
+
+func TestList(t *testing.T) {
+	c, err := podresources.Connect(startFakeKubelet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []types.ContainerAssignment{
+		{
+			PodNamespace:  "db",
+			PodName:       "postgres",
+			ContainerName: "postgres",
+			CPUs:          []int{0, 1},
+			Memory: []types.MemoryBlock{
+				{NUMACells: []int{0, 1}, Type: "memory", Size: 2 * unit.Gi},
+			},
+			Devices: []types.DeviceAssignment{},
+		},
+		{
+			PodNamespace:  "ml",
+			PodName:       "trainer",
+			ContainerName: "train",
+			CPUs:          []int{4, 5},
+			Memory: []types.MemoryBlock{
+				{NUMACells: []int{1}, Type: "memory", Size: 4 * unit.Gi},
+			},
+			Devices: []types.DeviceAssignment{
+				{
+					ResourceName: "nvidia.com/gpu",
+					DeviceIDs:    []string{"gpu-1"},
+					NUMACells:    []int{1},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+}
+
+func TestApplyToCells(t *testing.T) {
+	c, err := podresources.Connect(startFakeKubelet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	alloc, err := c.Allocatable(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignments, err := c.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := []types.NUMACell{
+		{
+			ID: 0,
+			Cores: []types.CPUCore{
+				{ID: 0, CPUs: []int{0}},
+				{ID: 1, CPUs: []int{1}},
+				{ID: 2, CPUs: []int{2}},
+				{ID: 3, CPUs: []int{3}},
+			},
+		},
+		{
+			ID: 1,
+			Cores: []types.CPUCore{
+				{ID: 0, CPUs: []int{4}},
+				{ID: 1, CPUs: []int{5}},
+				{ID: 2, CPUs: []int{6}},
+				{ID: 3, CPUs: []int{7}},
+			},
+		},
+	}
+	podresources.ApplyToCells(cells, alloc, assignments)
+
+	tcs := []struct {
+		cpus        []int
+		cpuAlloc    float64
+		memAlloc    float64
+		memExcl     float64
+		devices     []types.Devices
+		description string
+	}{
+		{
+			[]int{0, 1}, 3, 15 * unit.Gi, unit.Gi,
+			[]types.Devices{{ResourceName: "nvidia.com/gpu", Total: 1}},
+			"cell 0",
+		},
+		{
+			[]int{4, 5}, 3, 16 * unit.Gi, 5 * unit.Gi,
+			[]types.Devices{{ResourceName: "nvidia.com/gpu", Total: 2, Allocated: 1}},
+			"cell 1",
+		},
+	}
+	for x, tc := range tcs {
+		cell := cells[x]
+		if !reflect.DeepEqual(cell.AllocatedCPUs, tc.cpus) {
+			t.Fatalf("%s: expected allocated CPUs %v but got %v", tc.description, tc.cpus, cell.AllocatedCPUs)
+		}
+		if cell.Resources.CPU.ExclusiveRequestedFloor != float64(len(tc.cpus)) {
+			t.Fatalf("%s: expected %d exclusive CPUs but got %.0f", tc.description, len(tc.cpus), cell.Resources.CPU.ExclusiveRequestedFloor)
+		}
+		if cell.Resources.CPU.Allocatable != tc.cpuAlloc {
+			t.Fatalf("%s: expected %.0f allocatable CPUs but got %.0f", tc.description, tc.cpuAlloc, cell.Resources.CPU.Allocatable)
+		}
+		if cell.Resources.Memory.Allocatable != tc.memAlloc {
+			t.Fatalf("%s: expected allocatable memory %s but got %s", tc.description, unit.BytesToSizeString(tc.memAlloc), unit.BytesToSizeString(cell.Resources.Memory.Allocatable))
+		}
+		if cell.Resources.Memory.ExclusiveRequestedFloor != tc.memExcl {
+			t.Fatalf("%s: expected exclusive memory %s but got %s", tc.description, unit.BytesToSizeString(tc.memExcl), unit.BytesToSizeString(cell.Resources.Memory.ExclusiveRequestedFloor))
+		}
+		if !reflect.DeepEqual(cell.Devices, tc.devices) {
+			t.Fatalf("%s: expected devices %+v but got %+v", tc.description, tc.devices, cell.Devices)
+		}
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	c, err := podresources.Connect(filepath.Join(t.TempDir(), "missing.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.List(context.Background())
+	if !errors.Is(err, podresources.ErrPodResourcesUnavailable) {
+		t.Fatalf("expected ErrPodResourcesUnavailable but got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// resultTypeVector is the result type of an instant query
+	resultTypeVector = "vector"
+	// resultTypeMatrix is the result type of a range query
+	resultTypeMatrix = "matrix"
+)
+
+// Sample is a single value of a time series returned by an instant query
+type Sample struct {
+	// Labels contains the labels of the time series
+	Labels map[string]string
+	// Value is the value of the time series at the query's evaluation time
+	Value float64
+}
+
+// Point is a single value of a time series returned by a range query
+type Point struct {
+	// Time is the step of the query's range the value was evaluated at
+	Time time.Time
+	// Value is the value of the time series at Time
+	Value float64
+}
+
+// Series is a time series returned by a range query
+type Series struct {
+	// Labels contains the labels of the time series
+	Labels map[string]string
+	// Points contains the values of the time series at each step of the
+	// query's range, oldest first. Steps with no value are omitted.
+	Points []Point
+}
+
+// Client queries the HTTP API of Prometheus or a compatible server (e.g.
+// Thanos, Mimir, VictoriaMetrics)
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient returns a Client for the Prometheus-compatible HTTP API at the
+// supplied base URL, e.g. "http://prometheus.monitoring:9090"
+func NewClient(baseURL string) *Client {
+	return &Client{
+		url:  strings.TrimSuffix(baseURL, "/"),
+		http: &http.Client{},
+	}
+}
+
+// response is the envelope of every Prometheus HTTP API response
+type response struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+// queryData is the data of an instant query response
+type queryData struct {
+	ResultType string `json:"resultType"`
+	Result     []struct {
+		Metric map[string]string `json:"metric"`
+		Value  []interface{}     `json:"value"`
+	} `json:"result"`
+}
+
+// Query evaluates the supplied PromQL instant query at the current time and
+// returns the resulting samples. The query must return an instant vector.
+func (c *Client) Query(ctx context.Context, query string) ([]Sample, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", strconv.FormatInt(time.Now().Unix(), 10))
+	data, err := c.get(ctx, "/api/v1/query", params)
+	if err != nil {
+		return nil, QueryFailed(query, err.Error())
+	}
+	qd := queryData{}
+	if err = json.Unmarshal(data, &qd); err != nil {
+		return nil, QueryFailed(query, err.Error())
+	}
+	if qd.ResultType != resultTypeVector {
+		return nil, QueryFailed(
+			query, fmt.Sprintf("expected a %s but got a %s", resultTypeVector, qd.ResultType),
+		)
+	}
+	res := make([]Sample, 0, len(qd.Result))
+	for _, r := range qd.Result {
+		v, err := sampleValue(r.Value)
+		if err != nil {
+			return nil, QueryFailed(query, err.Error())
+		}
+		res = append(res, Sample{Labels: r.Metric, Value: v})
+	}
+	return res, nil
+}
+
+// rangeData is the data of a range query response
+type rangeData struct {
+	ResultType string `json:"resultType"`
+	Result     []struct {
+		Metric map[string]string `json:"metric"`
+		Values [][]interface{}   `json:"values"`
+	} `json:"result"`
+}
+
+// QueryRange evaluates the supplied PromQL query at each step of the
+// supplied range and returns the resulting time series
+func (c *Client) QueryRange(
+	ctx context.Context,
+	query string,
+	start time.Time,
+	end time.Time,
+	step time.Duration,
+) ([]Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", promDuration(step))
+	data, err := c.get(ctx, "/api/v1/query_range", params)
+	if err != nil {
+		return nil, QueryFailed(query, err.Error())
+	}
+	rd := rangeData{}
+	if err = json.Unmarshal(data, &rd); err != nil {
+		return nil, QueryFailed(query, err.Error())
+	}
+	if rd.ResultType != resultTypeMatrix {
+		return nil, QueryFailed(
+			query, fmt.Sprintf("expected a %s but got a %s", resultTypeMatrix, rd.ResultType),
+		)
+	}
+	res := make([]Series, 0, len(rd.Result))
+	for _, r := range rd.Result {
+		s := Series{Labels: r.Metric, Points: make([]Point, 0, len(r.Values))}
+		for _, pair := range r.Values {
+			v, err := sampleValue(pair)
+			if err != nil {
+				return nil, QueryFailed(query, err.Error())
+			}
+			t, err := sampleTime(pair)
+			if err != nil {
+				return nil, QueryFailed(query, err.Error())
+			}
+			s.Points = append(s.Points, Point{Time: t, Value: v})
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// get calls the supplied API path with the supplied parameters and returns
+// the data of a successful response
+func (c *Client) get(
+	ctx context.Context,
+	path string,
+	params url.Values,
+) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, c.url+path+"?"+params.Encode(), nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	r := response{}
+	if err = json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("unexpected response (HTTP %d)", resp.StatusCode)
+	}
+	if r.Status != "success" {
+		return nil, fmt.Errorf("%s: %s", r.ErrorType, r.Error)
+	}
+	return r.Data, nil
+}
+
+// sampleValue returns the value of a [<unix time>, "<value>"] pair
+func sampleValue(pair []interface{}) (float64, error) {
+	if len(pair) != 2 {
+		return 0, fmt.Errorf("invalid sample %v", pair)
+	}
+	s, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid sample value %v", pair[1])
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// sampleTime returns the time of a [<unix time>, "<value>"] pair
+func sampleTime(pair []interface{}) (time.Time, error) {
+	if len(pair) != 2 {
+		return time.Time{}, fmt.Errorf("invalid sample %v", pair)
+	}
+	ts, ok := pair[0].(float64)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid sample time %v", pair[0])
+	}
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e3))*int64(time.Millisecond)), nil
+}
@@ -0,0 +1,25 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package prometheus
+
+import (
+	"fmt"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+)
+
+var (
+	// ErrQueryFailed is returned when a Prometheus-compatible API does not
+	// answer a query successfully.
+	ErrQueryFailed = fmt.Errorf(
+		"%w: Prometheus query failed",
+		kwerrors.RuntimeError,
+	)
+)
+
+// QueryFailed returns ErrQueryFailed with some further context
+func QueryFailed(query string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrQueryFailed, query, reason)
+}
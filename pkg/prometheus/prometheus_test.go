@@ -0,0 +1,220 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package prometheus_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/prometheus"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+// vector returns an instant query response with one sample per supplied
+// "<namespace>/<pod>" key
+func vector(values map[string]string) string {
+	results := []string{}
+	for key, v := range values {
+		ns, pod, _ := strings.Cut(key, "/")
+		results = append(results, fmt.Sprintf(
+			`{"metric":{"namespace":%q,"pod":%q},"value":[1700000000,%q]}`,
+			ns, pod, v,
+		))
+	}
+	return fmt.Sprintf(
+		`{"status":"success","data":{"resultType":"vector","result":[%s]}}`,
+		strings.Join(results, ","),
+	)
+}
+
+// matrix returns a range query response with one series per supplied
+// "<namespace>/<pod>/<container>" key
+func matrix(values map[string][]string) string {
+	results := []string{}
+	for key, vs := range values {
+		parts := strings.Split(key, "/")
+		pairs := []string{}
+		for x, v := range vs {
+			pairs = append(pairs, fmt.Sprintf("[%d,%q]", 1700000000+x*60, v))
+		}
+		results = append(results, fmt.Sprintf(
+			`{"metric":{"namespace":%q,"pod":%q,"container":%q},"values":[%s]}`,
+			parts[0], parts[1], parts[2], strings.Join(pairs, ","),
+		))
+	}
+	return fmt.Sprintf(
+		`{"status":"success","data":{"resultType":"matrix","result":[%s]}}`,
+		strings.Join(results, ","),
+	)
+}
+
+// stubPrometheus returns a server answering instant and range queries
+// containing each of the supplied metric names with the associated response.
+// Each request is passed to the optional onRequest function first.
+func stubPrometheus(
+	t *testing.T,
+	responses map[string]string,
+	onRequest func(r *http.Request),
+) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if onRequest != nil {
+			onRequest(r)
+		}
+		if r.URL.Path != "/api/v1/query" && r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query().Get("query")
+		for metric, resp := range responses {
+			if strings.Contains(query, metric+"{") {
+				fmt.Fprint(w, resp)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unknown query"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQuery(t *testing.T) {
+	srv := stubPrometheus(t, map[string]string{
+		"up":     vector(map[string]string{"default/web": "1"}),
+		"matrix": `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+	}, nil)
+	c := prometheus.NewClient(srv.URL + "/")
+	ctx := context.Background()
+
+	got, err := c.Query(ctx, `up{job="kubelet"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []prometheus.Sample{
+		{Labels: map[string]string{"namespace": "default", "pod": "web"}, Value: 1},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+
+	for _, query := range []string{`matrix{}`, `unknown{}`} {
+		_, err = c.Query(ctx, query)
+		if !errors.Is(err, prometheus.ErrQueryFailed) {
+			t.Fatalf("%s: expected ErrQueryFailed but got %v", query, err)
+		}
+	}
+}
+
+func TestThrottling(t *testing.T) {
+	srv := stubPrometheus(t, map[string]string{
+		"container_cpu_cfs_periods_total": vector(map[string]string{
+			"default/web": "36000", "db/postgres": "36000",
+		}),
+		"container_cpu_cfs_throttled_periods_total": vector(map[string]string{
+			"default/web": "9000", "db/postgres": "0",
+		}),
+		"container_cpu_cfs_throttled_seconds_total": vector(map[string]string{
+			"default/web": "450.5", "db/postgres": "0",
+		}),
+	}, nil)
+	got, err := prometheus.Throttling(
+		context.Background(), prometheus.NewClient(srv.URL), time.Hour,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]*types.CPUThrottling{
+		"default/web": {Periods: 36000, ThrottledPeriods: 9000, ThrottledSeconds: 450.5},
+		"db/postgres": {Periods: 36000},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+}
+
+func TestSourceContainerUsage(t *testing.T) {
+	steps := []string{}
+	queries := []string{}
+	srv := stubPrometheus(t, map[string]string{
+		"container_cpu_usage_seconds_total": matrix(map[string][]string{
+			"default/web/app": {"0.25", "0.5", "0.75"},
+		}),
+		"container_memory_working_set_bytes": matrix(map[string][]string{
+			"default/web/app":     {"1024", "2048", "4096"},
+			"default/web/sidecar": {"512"},
+		}),
+	}, func(r *http.Request) {
+		steps = append(steps, r.URL.Query().Get("step"))
+		queries = append(queries, r.URL.Query().Get("query"))
+	})
+
+	var source usage.Source = prometheus.NewSource(prometheus.NewClient(srv.URL))
+	got, err := source.ContainerUsage(context.Background(), 24*time.Hour, usage.Scope{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[usage.ContainerKey]*usage.Samples{
+		{Namespace: "default", Pod: "web", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(1700000000, 0), Value: 0.25},
+				{Time: time.Unix(1700000060, 0), Value: 0.5},
+				{Time: time.Unix(1700000120, 0), Value: 0.75},
+			},
+			Memory: []usage.Sample{
+				{Time: time.Unix(1700000000, 0), Value: 1024},
+				{Time: time.Unix(1700000060, 0), Value: 2048},
+				{Time: time.Unix(1700000120, 0), Value: 4096},
+			},
+		},
+		{Namespace: "default", Pod: "web", Container: "sidecar"}: {
+			Memory: []usage.Sample{
+				{Time: time.Unix(1700000000, 0), Value: 512},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %+v but got %+v", exp, got)
+	}
+	// A day in at most 1000 steps is a step of 86.4s, truncated to seconds
+	if !reflect.DeepEqual(steps, []string{"86s", "86s"}) {
+		t.Fatalf("expected two range queries with a step of 86s but got %v", steps)
+	}
+	// The CPU usage rate range is the step or 2 minutes, whichever is longer
+	if !strings.Contains(queries[0], "[120s]") {
+		t.Fatalf("expected a CPU usage rate range of 120s but got %s", queries[0])
+	}
+}
+
+func TestSourceContainerUsageScope(t *testing.T) {
+	queries := []string{}
+	empty := matrix(map[string][]string{})
+	srv := stubPrometheus(t, map[string]string{
+		"container_cpu_usage_seconds_total":  empty,
+		"container_memory_working_set_bytes": empty,
+	}, func(r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("query"))
+	})
+	source := prometheus.NewSource(prometheus.NewClient(srv.URL))
+	scope := usage.Scope{Namespace: "team-a", Pod: "web"}
+	if _, err := source.ContainerUsage(context.Background(), time.Hour, scope); err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected two range queries but got %d", len(queries))
+	}
+	for _, q := range queries {
+		if !strings.Contains(q, `,namespace="team-a",pod="web"}`) {
+			t.Fatalf("expected the scope's label matchers in %s", q)
+		}
+	}
+}
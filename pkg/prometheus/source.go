@@ -0,0 +1,118 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+const (
+	// Queries of the cAdvisor series scraped from the kubelets, by
+	// container. The first %s verb of each query is replaced by the label
+	// matchers of the scope and the second of the CPU query by the rate
+	// range. Series without a container label are Pod-level cgroups and
+	// those with a "POD" container are pause containers.
+	queryContainerCPU = `sum by (namespace, pod, container) ` +
+		`(rate(container_cpu_usage_seconds_total{container!="",container!="POD"%s}[%s]))`
+	queryContainerMemory = `sum by (namespace, pod, container) ` +
+		`(container_memory_working_set_bytes{container!="",container!="POD"%s})`
+	// maxSteps is the most samples requested of each series, keeping well
+	// under the 11,000 points per series Prometheus allows
+	maxSteps = 1000
+	// minStep is the smallest step between samples, about the scrape
+	// interval of the kubelets' cAdvisor endpoints
+	minStep = 30 * time.Second
+	// minRateRange is the smallest range the CPU usage rate is calculated
+	// over, at least four scrape intervals so every range has enough
+	// samples for rate() even if a scrape is missed
+	minRateRange = 2 * time.Minute
+)
+
+// Source is a usage.Source running range queries of cAdvisor series
+// against a Prometheus-compatible HTTP API
+type Source struct {
+	c *Client
+}
+
+// NewSource returns a Source querying the supplied Client
+func NewSource(c *Client) *Source {
+	return &Source{c: c}
+}
+
+// ContainerUsage returns samples of the resource usage of every container
+// in the supplied scope over the supplied window ending now. Samples are at
+// most 1000 steps of no less than 30 seconds apart, and CPU usage is the
+// average rate over the step or 2 minutes, whichever is longer, before each
+// sample.
+func (s *Source) ContainerUsage(
+	ctx context.Context,
+	window time.Duration,
+	scope usage.Scope,
+) (map[usage.ContainerKey]*usage.Samples, error) {
+	matchers := scopeMatchers(scope)
+	end := time.Now()
+	start := end.Add(-window)
+	step := max(window/maxSteps, minStep)
+	res := map[usage.ContainerKey]*usage.Samples{}
+	for _, q := range []struct {
+		query string
+		add   func(s *usage.Samples, samples []usage.Sample)
+	}{
+		{
+			fmt.Sprintf(queryContainerCPU, matchers, promDuration(max(step, minRateRange))),
+			func(s *usage.Samples, samples []usage.Sample) { s.CPU = append(s.CPU, samples...) },
+		},
+		{
+			fmt.Sprintf(queryContainerMemory, matchers),
+			func(s *usage.Samples, samples []usage.Sample) { s.Memory = append(s.Memory, samples...) },
+		},
+	} {
+		series, err := s.c.QueryRange(ctx, q.query, start, end, step)
+		if err != nil {
+			return nil, err
+		}
+		for _, ser := range series {
+			key := usage.ContainerKey{
+				Namespace: ser.Labels["namespace"],
+				Pod:       ser.Labels["pod"],
+				Container: ser.Labels["container"],
+			}
+			samples, ok := res[key]
+			if !ok {
+				samples = &usage.Samples{}
+				res[key] = samples
+			}
+			points := make([]usage.Sample, len(ser.Points))
+			for x, p := range ser.Points {
+				points[x] = usage.Sample{Time: p.Time, Value: p.Value}
+			}
+			q.add(samples, points)
+		}
+	}
+	return res, nil
+}
+
+// scopeMatchers returns the label matchers selecting the series of the
+// containers in the supplied scope, each preceded by a comma
+func scopeMatchers(scope usage.Scope) string {
+	res := ""
+	for _, m := range []struct {
+		label string
+		value string
+	}{
+		{"namespace", scope.Namespace},
+		{"pod", scope.Pod},
+	} {
+		if m.value != "" {
+			res += "," + m.label + "=" + strconv.Quote(m.value)
+		}
+	}
+	return res
+}
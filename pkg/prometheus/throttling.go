@@ -0,0 +1,74 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// Queries of cAdvisor's CFS counters, summed over each Pod's containers.
+	// The %s verb is replaced by the range of the window.
+	queryCFSPeriods = `sum by (namespace, pod) ` +
+		`(increase(container_cpu_cfs_periods_total{container!=""}[%s]))`
+	queryCFSThrottledPeriods = `sum by (namespace, pod) ` +
+		`(increase(container_cpu_cfs_throttled_periods_total{container!=""}[%s]))`
+	queryCFSThrottledSeconds = `sum by (namespace, pod) ` +
+		`(increase(container_cpu_cfs_throttled_seconds_total{container!=""}[%s]))`
+)
+
+// Throttling returns a map, keyed by "<namespace>/<name>", of the CFS
+// throttling counters of every Pod with a CPU limit over the supplied window
+// ending now, from the cAdvisor series scraped from the kubelets
+func Throttling(
+	ctx context.Context,
+	c *Client,
+	window time.Duration,
+) (map[string]*types.CPUThrottling, error) {
+	res := map[string]*types.CPUThrottling{}
+	rng := promDuration(window)
+	for _, q := range []struct {
+		query string
+		set   func(t *types.CPUThrottling, v float64)
+	}{
+		{
+			queryCFSPeriods,
+			func(t *types.CPUThrottling, v float64) { t.Periods = v },
+		},
+		{
+			queryCFSThrottledPeriods,
+			func(t *types.CPUThrottling, v float64) { t.ThrottledPeriods = v },
+		},
+		{
+			queryCFSThrottledSeconds,
+			func(t *types.CPUThrottling, v float64) { t.ThrottledSeconds = v },
+		},
+	} {
+		samples, err := c.Query(ctx, fmt.Sprintf(q.query, rng))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			key := s.Labels["namespace"] + "/" + s.Labels["pod"]
+			t, ok := res[key]
+			if !ok {
+				t = &types.CPUThrottling{}
+				res[key] = t
+			}
+			q.set(t, s.Value)
+		}
+	}
+	return res, nil
+}
+
+// promDuration returns the supplied duration as a PromQL duration in whole
+// seconds, e.g. "3600s"
+func promDuration(d time.Duration) string {
+	return fmt.Sprintf("%ds", int64(d.Seconds()))
+}
@@ -0,0 +1,112 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package reserved
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/jaypipes/kwiz/pkg/cpuset"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+const (
+	// evictionSignalMemory is the hard eviction signal that reserves memory
+	evictionSignalMemory = "memory.available"
+	// unexplainedTolerancePct is the percentage of a resource's capacity an
+	// unexplained remainder must exceed to be flagged. This absorbs rounding
+	// of quantities.
+	unexplainedTolerancePct = 1.0
+)
+
+// CPU returns the breakdown of the supplied CPU amounts' Reserved amount
+// according to the supplied kubelet configuration.
+func CPU(
+	kc *types.KubeletConfig,
+	amounts types.ResourceAmounts,
+) (*types.ReservedBreakdown, error) {
+	b := &types.ReservedBreakdown{}
+	if kc.ReservedSystemCPUs != "" {
+		cpus, err := cpuset.Parse(kc.ReservedSystemCPUs)
+		if err != nil {
+			return nil, err
+		}
+		b.ReservedSystemCPUs = float64(len(cpus))
+	} else {
+		var err error
+		if b.KubeReserved, err = cpuAmount(kc.KubeReserved); err != nil {
+			return nil, err
+		}
+		if b.SystemReserved, err = cpuAmount(kc.SystemReserved); err != nil {
+			return nil, err
+		}
+	}
+	b.Unexplained = amounts.Reserved - b.KubeReserved - b.SystemReserved - b.ReservedSystemCPUs
+	return b, nil
+}
+
+// Memory returns the breakdown of the supplied memory amounts' Reserved
+// amount according to the supplied kubelet configuration and the amount of
+// memory pre-allocated to hugepages on the Node.
+func Memory(
+	kc *types.KubeletConfig,
+	amounts types.ResourceAmounts,
+	hugepages float64,
+) *types.ReservedBreakdown {
+	b := &types.ReservedBreakdown{
+		KubeReserved:   memAmount(kc.KubeReserved["memory"], amounts.Capacity),
+		SystemReserved: memAmount(kc.SystemReserved["memory"], amounts.Capacity),
+		EvictionHard:   memAmount(kc.EvictionHard[evictionSignalMemory], amounts.Capacity),
+		Hugepages:      hugepages,
+	}
+	b.Unexplained = amounts.Reserved - b.KubeReserved - b.SystemReserved - b.EvictionHard - b.Hugepages
+	return b
+}
+
+// IsUnexplained returns true if the unexplained remainder of the supplied
+// breakdown is large enough to indicate the Reserved amount is caused by
+// something other than the known kubelet settings.
+func IsUnexplained(b *types.ReservedBreakdown, capacity float64) bool {
+	if b == nil || capacity <= 0 {
+		return false
+	}
+	return math.Abs(b.Unexplained)/capacity*100 > unexplainedTolerancePct
+}
+
+// cpuAmount returns the number of CPU cores in the "cpu" entry of a kubelet
+// reservation map, or 0 if there is none
+func cpuAmount(m map[string]string) (float64, error) {
+	s, ok := m["cpu"]
+	if !ok {
+		return 0, nil
+	}
+	return unit.CPUStringToCores(s)
+}
+
+// memAmount returns the number of bytes in a kubelet memory quantity string,
+// which may be a percentage of the supplied capacity (e.g. "5%" for eviction
+// thresholds). An empty or invalid string is 0 bytes.
+func memAmount(s string, capacity float64) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	if pct, isPct := strings.CutSuffix(s, "%"); isPct {
+		v, err := strconv.ParseFloat(pct, 64)
+		if err != nil {
+			return 0
+		}
+		return capacity * v / 100
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0
+	}
+	return q.AsApproximateFloat64()
+}
@@ -0,0 +1,97 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package reserved_test
+
+import (
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/reserved"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func TestCPU(t *testing.T) {
+	kc := &types.KubeletConfig{
+		KubeReserved:   map[string]string{"cpu": "500m"},
+		SystemReserved: map[string]string{"cpu": "250m"},
+	}
+	amounts := types.ResourceAmounts{Capacity: 16, Allocatable: 15, Reserved: 1}
+	b, err := reserved.CPU(kc, amounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.KubeReserved != 0.5 || b.SystemReserved != 0.25 {
+		t.Fatalf("unexpected kube/system reserved: %+v", b)
+	}
+	if b.Unexplained != 0.25 {
+		t.Fatalf("expected 0.25 unexplained but got %.2f", b.Unexplained)
+	}
+	if !reserved.IsUnexplained(b, amounts.Capacity) {
+		t.Fatalf("expected 0.25 of 16 CPUs to be flagged as unexplained")
+	}
+
+	// reservedSystemCPUs replaces the CPU amounts of kube and system
+	// reserved
+	kc.ReservedSystemCPUs = "0,8"
+	amounts = types.ResourceAmounts{Capacity: 16, Allocatable: 14, Reserved: 2}
+	b, err = reserved.CPU(kc, amounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ReservedSystemCPUs != 2 || b.KubeReserved != 0 || b.SystemReserved != 0 {
+		t.Fatalf("unexpected breakdown with reserved system CPUs: %+v", b)
+	}
+	if reserved.IsUnexplained(b, amounts.Capacity) {
+		t.Fatalf("expected no unexplained reservation but got %.2f", b.Unexplained)
+	}
+
+	kc.ReservedSystemCPUs = "bogus"
+	if _, err = reserved.CPU(kc, amounts); err == nil {
+		t.Fatalf("expected error for invalid reserved system CPUs")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	kc := &types.KubeletConfig{
+		KubeReserved:   map[string]string{"memory": "1Gi"},
+		SystemReserved: map[string]string{"memory": "512Mi"},
+		EvictionHard:   map[string]string{"memory.available": "100Mi", "nodefs.available": "10%"},
+	}
+	capacity := 64 * unit.Gi
+	hugepages := 4 * unit.Gi
+	reservedMem := unit.Gi + 512*unit.Mi + 100*unit.Mi + hugepages
+	amounts := types.ResourceAmounts{
+		Capacity:    capacity,
+		Allocatable: capacity - reservedMem,
+		Reserved:    reservedMem,
+	}
+	b := reserved.Memory(kc, amounts, hugepages)
+	if b.KubeReserved != unit.Gi || b.SystemReserved != 512*unit.Mi {
+		t.Fatalf("unexpected kube/system reserved: %+v", b)
+	}
+	if b.EvictionHard != 100*unit.Mi || b.Hugepages != hugepages {
+		t.Fatalf("unexpected eviction/hugepages: %+v", b)
+	}
+	if b.Unexplained != 0 {
+		t.Fatalf("expected nothing unexplained but got %s", unit.BytesToSizeString(b.Unexplained))
+	}
+
+	// Eviction thresholds may be a percentage of capacity
+	kc.EvictionHard["memory.available"] = "5%"
+	b = reserved.Memory(kc, amounts, hugepages)
+	if b.EvictionHard != capacity*0.05 {
+		t.Fatalf("expected 5%% of capacity eviction threshold but got %s", unit.BytesToSizeString(b.EvictionHard))
+	}
+	if !reserved.IsUnexplained(b, amounts.Capacity) {
+		t.Fatalf("expected negative remainder to be flagged as unexplained")
+	}
+
+	// Decimal SI suffixes are common in kubelet configurations
+	kc.KubeReserved["memory"] = "1G"
+	b = reserved.Memory(kc, amounts, hugepages)
+	if b.KubeReserved != 1e9 {
+		t.Fatalf("expected 1G kube-reserved memory but got %s", unit.BytesToSizeString(b.KubeReserved))
+	}
+}
@@ -0,0 +1,96 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package rightsize
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+// podTemplatePaths contains the path to the Pod template of each Workload
+// kind whose Pod template can be patched. Bare Pods and Jobs have immutable
+// Pod templates.
+var podTemplatePaths = map[string][]string{
+	"Deployment":  {"spec", "template"},
+	"StatefulSet": {"spec", "template"},
+	"DaemonSet":   {"spec", "template"},
+	"ReplicaSet":  {"spec", "template"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template"},
+}
+
+// Patch returns a strategic merge patch that applies the recommended
+// requests and limits of the containers with usage samples to the
+// Recommendation's Workload. Returns false if the Workload's kind cannot be
+// patched or there is nothing to patch.
+func Patch(rec Recommendation) ([]byte, bool) {
+	path, ok := podTemplatePaths[rec.Workload.Kind]
+	if !ok {
+		return nil, false
+	}
+	containers := []interface{}{}
+	for _, cr := range rec.Containers {
+		if cr.Samples == 0 {
+			continue
+		}
+		containers = append(containers, map[string]interface{}{
+			"name":      cr.Name,
+			"resources": resourcesPatch(cr.Recommended),
+		})
+	}
+	if len(containers) == 0 {
+		return nil, false
+	}
+	var patch interface{} = map[string]interface{}{
+		"spec": map[string]interface{}{"containers": containers},
+	}
+	for x := len(path) - 1; x >= 0; x-- {
+		patch = map[string]interface{}{path[x]: patch}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// resourcesPatch returns the container "resources" field for the supplied
+// requests, omitting absent requests and unbounded limits
+func resourcesPatch(reqs types.ResourceRequests) map[string]interface{} {
+	requests := map[string]string{}
+	if reqs.CPU.Floor != -1 {
+		requests["cpu"] = cpuQuantity(reqs.CPU.Floor)
+	}
+	if reqs.Memory.Floor != -1 {
+		requests["memory"] = memoryQuantity(reqs.Memory.Floor)
+	}
+	limits := map[string]string{}
+	if reqs.CPU.Ceiling != -1 {
+		limits["cpu"] = cpuQuantity(reqs.CPU.Ceiling)
+	}
+	if reqs.Memory.Ceiling != -1 {
+		limits["memory"] = memoryQuantity(reqs.Memory.Ceiling)
+	}
+	res := map[string]interface{}{"requests": requests}
+	if len(limits) > 0 {
+		res["limits"] = limits
+	}
+	return res
+}
+
+// cpuQuantity returns the supplied CPU amount as a Kubernetes quantity in
+// millicores, e.g. "250m"
+func cpuQuantity(cores float64) string {
+	return fmt.Sprintf("%dm", int64(math.Round(cores*1000)))
+}
+
+// memoryQuantity returns the supplied memory amount as a Kubernetes
+// quantity in Mi, e.g. "256Mi"
+func memoryQuantity(bytes float64) string {
+	return fmt.Sprintf("%dMi", int64(math.Ceil(bytes/unit.Mi)))
+}
@@ -0,0 +1,277 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package rightsize
+
+import (
+	"math"
+
+	"github.com/jaypipes/kwiz/pkg/fit"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+const (
+	// minCPU is the smallest CPU amount recommended, in cores
+	minCPU = 0.01
+	// minMemory is the smallest memory amount recommended, in bytes
+	minMemory = 16 * unit.Mi
+)
+
+// Options contains the percentiles of observed usage that recommendations
+// are based on
+type Options struct {
+	// CPURequestPercentile is the percentile of CPU usage samples to
+	// recommend as the CPU request
+	CPURequestPercentile float64
+	// CPULimitPercentile is the percentile of CPU usage samples to
+	// recommend as the CPU limit
+	CPULimitPercentile float64
+	// MemoryRequestPercentile is the percentile of memory usage samples to
+	// recommend as the memory request
+	MemoryRequestPercentile float64
+	// MemoryLimitPercentile is the percentile of memory usage samples to
+	// recommend as the memory limit
+	MemoryLimitPercentile float64
+	// Margin is the fraction of each percentile added to it as a safety
+	// margin, e.g. 0.15 for 15%
+	Margin float64
+}
+
+// DefaultOptions are the Options used if none are specified. The 15% safety
+// margin is the same as the Vertical Pod Autoscaler's.
+var DefaultOptions = Options{
+	CPURequestPercentile:    90,
+	CPULimitPercentile:      99,
+	MemoryRequestPercentile: 95,
+	MemoryLimitPercentile:   99,
+	Margin:                  0.15,
+}
+
+// ContainerRecommendation contains the recommended requests and limits of a
+// single app container of a Workload's Pod template
+type ContainerRecommendation struct {
+	// Name is the name of the container
+	Name string
+	// Samples is the number of CPU usage samples the recommendation is
+	// based on, across all replicas. 0 if there was no usage to base a
+	// recommendation on, in which case Recommended is the same as Current.
+	Samples int
+	// Current contains the container's current requests and limits
+	Current types.ResourceRequests
+	// Recommended contains the container's recommended requests and limits
+	Recommended types.ResourceRequests
+}
+
+// Recommendation contains the recommended requests and limits of a
+// Workload's containers, and their effect on the cluster
+type Recommendation struct {
+	// Workload is the Workload the recommendation is for
+	Workload *types.Workload
+	// Containers contains a recommendation for each app container of the
+	// Workload's Pod template
+	Containers []ContainerRecommendation
+	// Current contains the current requests and limits of each replica
+	Current types.ResourceRequests
+	// Recommended contains the requests and limits of each replica if the
+	// container recommendations were applied
+	Recommended types.ResourceRequests
+	// CPUFreed is the number of cores of CPU requests that would be freed
+	// across all replicas if the recommendations were applied. Negative if
+	// the recommended requests are larger.
+	CPUFreed float64
+	// MemoryFreed is the number of bytes of memory requests that would be
+	// freed across all replicas if the recommendations were applied.
+	// Negative if the recommended requests are larger.
+	MemoryFreed float64
+	// NUMAFitCurrent is the number of Nodes on which a new replica with the
+	// current requests would fit in a single NUMA cell. -1 if no Node's
+	// NUMA cells are known.
+	NUMAFitCurrent int
+	// NUMAFitRecommended is the number of Nodes on which a new replica with
+	// the recommended requests would fit in a single NUMA cell. -1 if no
+	// Node's NUMA cells are known.
+	NUMAFitRecommended int
+}
+
+// Recommend returns a Recommendation for each of the supplied Workloads
+// from the supplied container usage samples. The samples of a container are
+// pooled across all of the Workload's replicas, since they share a Pod
+// template.
+func Recommend(
+	workloads []*types.Workload,
+	samples map[usage.ContainerKey]*usage.Samples,
+	opts Options,
+) []Recommendation {
+	res := make([]Recommendation, 0, len(workloads))
+	for _, w := range workloads {
+		if len(w.Pods) == 0 {
+			continue
+		}
+		rec := Recommendation{
+			Workload:           w,
+			Containers:         []ContainerRecommendation{},
+			Current:            w.PerReplica,
+			Recommended:        w.PerReplica,
+			NUMAFitCurrent:     -1,
+			NUMAFitRecommended: -1,
+		}
+		for _, ctr := range w.Pods[0].Containers {
+			if ctr.Type != types.ContainerTypeApp {
+				continue
+			}
+			pooled := pooledSamples{}
+			for _, p := range w.Pods {
+				key := usage.ContainerKey{Namespace: p.Namespace, Pod: p.Name, Container: ctr.Name}
+				if s, ok := samples[key]; ok {
+					pooled.CPU = append(pooled.CPU, usage.Values(s.CPU)...)
+					pooled.Memory = append(pooled.Memory, usage.Values(s.Memory)...)
+				}
+			}
+			cr := recommendContainer(ctr, pooled, opts)
+			rec.Containers = append(rec.Containers, cr)
+			adjust(&rec.Recommended.CPU, cr.Current.CPU, cr.Recommended.CPU)
+			adjust(&rec.Recommended.Memory, cr.Current.Memory, cr.Recommended.Memory)
+		}
+		replicas := float64(len(w.Pods))
+		rec.CPUFreed = (max(rec.Current.CPU.Floor, 0) - rec.Recommended.CPU.Floor) * replicas
+		rec.MemoryFreed = (max(rec.Current.Memory.Floor, 0) - rec.Recommended.Memory.Floor) * replicas
+		res = append(res, rec)
+	}
+	return res
+}
+
+// pooledSamples contains the values of the usage samples of a container
+// across all of a Workload's replicas
+type pooledSamples struct {
+	CPU    []float64
+	Memory []float64
+}
+
+// recommendContainer returns the recommended requests and limits of the
+// supplied container from its pooled usage samples. A limit is only
+// recommended if the container already has one, and is never lower than
+// the recommended request. The container keeps its QoS class: a request
+// equal to its limit stays equal to it, and a Guaranteed container with a
+// whole number of CPUs, which the static CPU manager pins to exclusive CPUs,
+// keeps a whole number of CPUs.
+func recommendContainer(
+	ctr types.Container,
+	samples pooledSamples,
+	opts Options,
+) ContainerRecommendation {
+	res := ContainerRecommendation{
+		Name:        ctr.Name,
+		Samples:     len(samples.CPU),
+		Current:     ctr.ResourceRequests,
+		Recommended: ctr.ResourceRequests,
+	}
+	if len(samples.CPU) > 0 {
+		round := roundCPU
+		if ctr.QOSClass == types.QOSGuaranteed && isWhole(requestFloor(ctr.ResourceRequests.CPU)) {
+			round = math.Ceil
+		}
+		res.Recommended.CPU = recommendRequest(
+			ctr.ResourceRequests.CPU, samples.CPU,
+			opts.CPURequestPercentile, opts.CPULimitPercentile, opts.Margin,
+			minCPU, round,
+		)
+	}
+	if len(samples.Memory) > 0 {
+		res.Recommended.Memory = recommendRequest(
+			ctr.ResourceRequests.Memory, samples.Memory,
+			opts.MemoryRequestPercentile, opts.MemoryLimitPercentile, opts.Margin,
+			minMemory, roundMemory,
+		)
+	}
+	return res
+}
+
+// recommendRequest returns the recommended floor and ceiling of a single
+// resource from the supplied samples. If the current floor equals the
+// ceiling, the recommended floor and ceiling are both the recommended
+// ceiling.
+func recommendRequest(
+	current types.ResourceRequest,
+	samples []float64,
+	floorPct float64,
+	ceilingPct float64,
+	margin float64,
+	minimum float64,
+	round func(float64) float64,
+) types.ResourceRequest {
+	floor := round(max(usage.Percentile(samples, floorPct)*(1+margin), minimum))
+	ceiling := float64(-1)
+	if current.Ceiling != -1 {
+		ceiling = round(max(usage.Percentile(samples, ceilingPct)*(1+margin), floor))
+		if requestFloor(current) == current.Ceiling {
+			floor = ceiling
+		}
+	}
+	return types.ResourceRequest{Floor: floor, Ceiling: ceiling}
+}
+
+// requestFloor returns the floor of a container's request, which the API
+// server defaults to the ceiling if not set
+func requestFloor(req types.ResourceRequest) float64 {
+	if req.Floor == -1 {
+		return req.Ceiling
+	}
+	return req.Floor
+}
+
+// isWhole returns true if the supplied amount is a positive whole number
+func isWhole(amount float64) bool {
+	return amount > 0 && amount == math.Trunc(amount)
+}
+
+// adjust applies the change from a container's current to recommended
+// request to the supplied Pod-level request. A Pod without a request floor
+// (-1) starts from zero. An unbounded ceiling stays unbounded.
+func adjust(
+	pod *types.ResourceRequest,
+	current types.ResourceRequest,
+	recommended types.ResourceRequest,
+) {
+	pod.Floor = max(pod.Floor, 0) + (max(recommended.Floor, 0) - max(current.Floor, 0))
+	if pod.Ceiling != -1 && recommended.Ceiling != -1 {
+		pod.Ceiling += recommended.Ceiling - max(current.Ceiling, 0)
+	}
+}
+
+// roundCPU rounds the supplied CPU amount up to the nearest millicore
+func roundCPU(cores float64) float64 {
+	return math.Ceil(cores*1000-1e-9) / 1000
+}
+
+// roundMemory rounds the supplied memory amount up to the nearest Mi
+func roundMemory(bytes float64) float64 {
+	return math.Ceil(bytes/unit.Mi) * unit.Mi
+}
+
+// SetNUMAFit sets the number of the supplied Nodes on which a new replica
+// of each Recommendation's Workload would fit in a single NUMA cell, with
+// both the current and the recommended requests. Nodes whose NUMA cells or
+// their unrequested amounts are unknown are not counted.
+func SetNUMAFit(recs []Recommendation, nodes []*types.Node) {
+	for x := range recs {
+		rec := &recs[x]
+		rec.NUMAFitCurrent = -1
+		rec.NUMAFitRecommended = -1
+		for _, n := range nodes {
+			if !fit.FreeKnown(n.NUMACells) {
+				continue
+			}
+			rec.NUMAFitCurrent = max(rec.NUMAFitCurrent, 0)
+			rec.NUMAFitRecommended = max(rec.NUMAFitRecommended, 0)
+			if fit.FitsSingleNUMACell(rec.Current, n.NUMACells) {
+				rec.NUMAFitCurrent++
+			}
+			if fit.FitsSingleNUMACell(rec.Recommended, n.NUMACells) {
+				rec.NUMAFitRecommended++
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package rightsize_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/rightsize"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+func testWorkload() *types.Workload {
+	app := types.Container{
+		Name: "app",
+		Type: types.ContainerTypeApp,
+		ResourceRequests: types.ResourceRequests{
+			CPU:    types.ResourceRequest{Floor: 2, Ceiling: 4},
+			Memory: types.ResourceRequest{Floor: 4 * unit.Gi, Ceiling: -1},
+		},
+	}
+	reqs := app.ResourceRequests
+	w := &types.Workload{
+		Namespace:  "default",
+		Kind:       "Deployment",
+		Name:       "web",
+		PerReplica: reqs,
+	}
+	for _, name := range []string{"web-a", "web-b"} {
+		w.Pods = append(w.Pods, &types.Pod{
+			Namespace:        "default",
+			Name:             name,
+			Containers:       []types.Container{app},
+			ResourceRequests: reqs,
+		})
+		w.ResourceRequests.Add(reqs)
+	}
+	return w
+}
+
+func TestRecommend(t *testing.T) {
+	w := testWorkload()
+	samples := map[usage.ContainerKey]*usage.Samples{
+		{Namespace: "default", Pod: "web-a", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 0.1},
+				{Time: time.Unix(60, 0), Value: 0.2},
+				{Time: time.Unix(120, 0), Value: 0.3},
+				{Time: time.Unix(180, 0), Value: 0.4},
+				{Time: time.Unix(240, 0), Value: 0.5},
+			},
+			Memory: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 400 * unit.Mi},
+				{Time: time.Unix(60, 0), Value: 500 * unit.Mi},
+			},
+		},
+		{Namespace: "default", Pod: "web-b", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 0.6},
+				{Time: time.Unix(60, 0), Value: 0.7},
+				{Time: time.Unix(120, 0), Value: 0.8},
+				{Time: time.Unix(180, 0), Value: 0.9},
+				{Time: time.Unix(240, 0), Value: 1.0},
+			},
+			Memory: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 600 * unit.Mi},
+				{Time: time.Unix(60, 0), Value: 1000 * unit.Mi},
+			},
+		},
+	}
+	opts := rightsize.Options{
+		CPURequestPercentile:    50,
+		CPULimitPercentile:      100,
+		MemoryRequestPercentile: 50,
+		MemoryLimitPercentile:   100,
+		Margin:                  0.1,
+	}
+	recs := rightsize.Recommend([]*types.Workload{w}, samples, opts)
+	if len(recs) != 1 || len(recs[0].Containers) != 1 {
+		t.Fatalf("expected one recommendation for one container but got %+v", recs)
+	}
+	rec := recs[0]
+	cr := rec.Containers[0]
+	if cr.Samples != 10 {
+		t.Fatalf("expected samples pooled across replicas but got %d", cr.Samples)
+	}
+	// p50 of CPU is 0.5 and p100 is 1.0, plus 10%. p50 of memory is 500Mi
+	// plus 10%. Memory had no limit, so none is recommended.
+	exp := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 0.55, Ceiling: 1.1},
+		Memory: types.ResourceRequest{Floor: 550 * unit.Mi, Ceiling: -1},
+	}
+	if cr.Recommended != exp {
+		t.Fatalf("expected %+v but got %+v", exp, cr.Recommended)
+	}
+	if rec.Recommended != exp {
+		t.Fatalf("expected per-replica %+v but got %+v", exp, rec.Recommended)
+	}
+	if rec.CPUFreed != 2.9 || rec.MemoryFreed != 2*(4*unit.Gi-550*unit.Mi) {
+		t.Fatalf(
+			"expected 2.9 CPUs and %s freed but got %.2f and %s",
+			unit.BytesToSizeString(2*(4*unit.Gi-550*unit.Mi)),
+			rec.CPUFreed, unit.BytesToSizeString(rec.MemoryFreed),
+		)
+	}
+
+	patch, ok := rightsize.Patch(rec)
+	if !ok {
+		t.Fatalf("expected a patch")
+	}
+	expPatch := `{"spec":{"template":{"spec":{"containers":[{"name":"app",` +
+		`"resources":{"limits":{"cpu":"1100m"},"requests":{"cpu":"550m","memory":"550Mi"}}}]}}}}`
+	if string(patch) != expPatch {
+		t.Fatalf("expected patch %s but got %s", expPatch, patch)
+	}
+}
+
+func TestRecommendGuaranteed(t *testing.T) {
+	w := testWorkload()
+	guaranteed := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 2, Ceiling: 2},
+		Memory: types.ResourceRequest{Floor: 4 * unit.Gi, Ceiling: 4 * unit.Gi},
+	}
+	for _, p := range w.Pods {
+		p.QOSClass = types.QOSGuaranteed
+		p.Containers[0].ResourceRequests = guaranteed
+		p.Containers[0].QOSClass = types.QOSGuaranteed
+	}
+	samples := map[usage.ContainerKey]*usage.Samples{
+		{Namespace: "default", Pod: "web-a", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 0.2},
+				{Time: time.Unix(60, 0), Value: 0.4},
+				{Time: time.Unix(120, 0), Value: 0.6},
+				{Time: time.Unix(180, 0), Value: 0.8},
+				{Time: time.Unix(240, 0), Value: 0.9},
+			},
+			Memory: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 400 * unit.Mi},
+				{Time: time.Unix(60, 0), Value: 500 * unit.Mi},
+			},
+		},
+	}
+	opts := rightsize.Options{
+		CPURequestPercentile:    50,
+		CPULimitPercentile:      100,
+		MemoryRequestPercentile: 50,
+		MemoryLimitPercentile:   100,
+		Margin:                  0.1,
+	}
+	cr := rightsize.Recommend([]*types.Workload{w}, samples, opts)[0].Containers[0]
+	// p100 of CPU plus 10% is 0.99, rounded up to a whole CPU, and p100 of
+	// memory plus 10% is 550Mi. Requests stay equal to limits.
+	exp := types.ResourceRequests{
+		CPU:    types.ResourceRequest{Floor: 1, Ceiling: 1},
+		Memory: types.ResourceRequest{Floor: 550 * unit.Mi, Ceiling: 550 * unit.Mi},
+	}
+	if cr.Recommended != exp {
+		t.Fatalf("expected %+v but got %+v", exp, cr.Recommended)
+	}
+}
+
+func TestRecommendNoRequests(t *testing.T) {
+	w := testWorkload()
+	noCPU := types.ResourceRequest{Floor: -1, Ceiling: -1}
+	w.PerReplica.CPU = noCPU
+	for _, p := range w.Pods {
+		p.Containers[0].ResourceRequests.CPU = noCPU
+		p.ResourceRequests.CPU = noCPU
+	}
+	samples := map[usage.ContainerKey]*usage.Samples{
+		{Namespace: "default", Pod: "web-a", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 1},
+				{Time: time.Unix(60, 0), Value: 1},
+			},
+		},
+	}
+	opts := rightsize.Options{CPURequestPercentile: 50, CPULimitPercentile: 100}
+	rec := rightsize.Recommend([]*types.Workload{w}, samples, opts)[0]
+	// A Pod without a CPU request starts from zero
+	if rec.Recommended.CPU.Floor != 1 {
+		t.Fatalf("expected a CPU floor of 1 but got %.2f", rec.Recommended.CPU.Floor)
+	}
+	if rec.CPUFreed != -2 {
+		t.Fatalf("expected -2 CPUs freed but got %.2f", rec.CPUFreed)
+	}
+}
+
+func TestRecommendNoSamples(t *testing.T) {
+	w := testWorkload()
+	recs := rightsize.Recommend([]*types.Workload{w}, nil, rightsize.DefaultOptions)
+	rec := recs[0]
+	if rec.Recommended != rec.Current || rec.CPUFreed != 0 || rec.MemoryFreed != 0 {
+		t.Fatalf("expected no change without samples but got %+v", rec)
+	}
+	if _, ok := rightsize.Patch(rec); ok {
+		t.Fatalf("expected no patch without samples")
+	}
+}
+
+func TestSetNUMAFit(t *testing.T) {
+	w := testWorkload()
+	cell := types.NUMACell{
+		Resources: types.Resources{
+			CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 7},
+			Memory: types.ResourceAmounts{Allocatable: 16 * unit.Gi},
+		},
+	}
+	nodes := []*types.Node{
+		{Name: "numa", NUMACells: []types.NUMACell{cell}},
+		{Name: "unknown"},
+	}
+	rec := rightsize.Recommendation{
+		Workload: w,
+		Current:  w.PerReplica,
+		Recommended: types.ResourceRequests{
+			CPU:    types.ResourceRequest{Floor: 0.5, Ceiling: 1},
+			Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: -1},
+		},
+	}
+	recs := []rightsize.Recommendation{rec}
+	rightsize.SetNUMAFit(recs, nodes)
+	if recs[0].NUMAFitCurrent != 0 || recs[0].NUMAFitRecommended != 1 {
+		t.Fatalf(
+			"expected a replica to fit on 0 nodes before and 1 after but got %d and %d",
+			recs[0].NUMAFitCurrent, recs[0].NUMAFitRecommended,
+		)
+	}
+	rightsize.SetNUMAFit(recs, nodes[1:])
+	if recs[0].NUMAFitCurrent != -1 {
+		t.Fatalf("expected unknown NUMA fit but got %d", recs[0].NUMAFitCurrent)
+	}
+}
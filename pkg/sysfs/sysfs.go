@@ -0,0 +1,271 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package sysfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaypipes/kwiz/pkg/cpuset"
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+const (
+	// DefaultRoot is the default mount point of sysfs
+	DefaultRoot = "/sys"
+)
+
+var (
+	// ErrInvalidSysfs is returned when a sysfs file cannot be read or has
+	// unexpected contents.
+	ErrInvalidSysfs = fmt.Errorf(
+		"%w: invalid sysfs",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidSysfs returns ErrInvalidSysfs with some further context
+func InvalidSysfs(path string, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidSysfs, path, reason)
+}
+
+// NUMACells reads the NUMA topology of the local host from the sysfs tree
+// mounted at the supplied root directory (DefaultRoot if empty) and returns
+// a NUMACell, sorted by ID, for each NUMA node. Each NUMACell has its
+// socket, physical cores, logical CPU capacity, memory capacity and usage,
+// hugepage pools and distances to the other NUMA cells filled in.
+func NUMACells(root string) ([]types.NUMACell, error) {
+	if root == "" {
+		root = DefaultRoot
+	}
+	nodeDirs, err := filepath.Glob(filepath.Join(root, "devices", "system", "node", "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(nodeDirs) == 0 {
+		return nil, InvalidSysfs(root, "no NUMA nodes found")
+	}
+	cells := make([]types.NUMACell, 0, len(nodeDirs))
+	for _, dir := range nodeDirs {
+		cell, err := numaCell(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, *cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].ID < cells[j].ID
+	})
+	return cells, nil
+}
+
+// numaCell returns the NUMACell for the NUMA node sysfs directory dir
+func numaCell(root string, dir string) (*types.NUMACell, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+	if err != nil {
+		return nil, InvalidSysfs(dir, "unexpected NUMA node directory name")
+	}
+	cell := &types.NUMACell{
+		ID:       id,
+		SocketID: -1,
+	}
+	cpuList, err := readString(filepath.Join(dir, "cpulist"))
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := cpuset.Parse(cpuList)
+	if err != nil {
+		return nil, err
+	}
+	if err = addCores(root, cell, cpus); err != nil {
+		return nil, err
+	}
+	cell.Resources.CPU.Capacity = float64(len(cpus))
+
+	memTotal, memFree, err := meminfo(filepath.Join(dir, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	cell.Resources.Memory.Capacity = memTotal
+	cell.Resources.Memory.Used = memTotal - memFree
+
+	if cell.Distances, err = distances(filepath.Join(dir, "distance")); err != nil {
+		return nil, err
+	}
+	if cell.Hugepages, err = hugepages(filepath.Join(dir, "hugepages")); err != nil {
+		return nil, err
+	}
+	return cell, nil
+}
+
+// coreKey identifies a physical core. Core IDs are only unique within a
+// socket, and the sockets of a multi-socket host reuse the same core IDs.
+type coreKey struct {
+	socketID int
+	coreID   int
+}
+
+// addCores groups the supplied logical CPUs of a NUMA cell into physical
+// cores using each CPU's topology directory and adds them to the cell,
+// sorted by socket and core ID. The cell's socket is that of its first CPU.
+func addCores(root string, cell *types.NUMACell, cpus []int) error {
+	cores := map[coreKey]*types.CPUCore{}
+	for _, cpu := range cpus {
+		topoDir := filepath.Join(
+			root, "devices", "system", "cpu", fmt.Sprintf("cpu%d", cpu), "topology",
+		)
+		coreID, err := readInt(filepath.Join(topoDir, "core_id"))
+		if err != nil {
+			return err
+		}
+		socketID, err := readInt(filepath.Join(topoDir, "physical_package_id"))
+		if err != nil {
+			return err
+		}
+		if cell.SocketID == -1 {
+			cell.SocketID = socketID
+		}
+		key := coreKey{socketID: socketID, coreID: coreID}
+		core, ok := cores[key]
+		if !ok {
+			core = &types.CPUCore{ID: coreID}
+			cores[key] = core
+		}
+		core.CPUs = append(core.CPUs, cpu)
+	}
+	keys := make([]coreKey, 0, len(cores))
+	for key := range cores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].socketID != keys[j].socketID {
+			return keys[i].socketID < keys[j].socketID
+		}
+		return keys[i].coreID < keys[j].coreID
+	})
+	for _, key := range keys {
+		core := cores[key]
+		sort.Ints(core.CPUs)
+		cell.Cores = append(cell.Cores, *core)
+	}
+	return nil
+}
+
+// meminfo returns the total and free bytes of memory in a NUMA node's
+// meminfo file, which has lines like "Node 0 MemTotal:  32768000 kB"
+func meminfo(path string) (float64, float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, InvalidSysfs(path, err.Error())
+	}
+	var total, free float64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		var dest *float64
+		switch fields[2] {
+		case "MemTotal:":
+			dest = &total
+		case "MemFree:":
+			dest = &free
+		default:
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return 0, 0, InvalidSysfs(path, err.Error())
+		}
+		if len(fields) > 4 && fields[4] == "kB" {
+			v *= unit.Ki
+		}
+		*dest = v
+	}
+	return total, free, nil
+}
+
+// distances returns the distances in a NUMA node's distance file, which
+// contains one distance per NUMA node, e.g. "10 21"
+func distances(path string) ([]int, error) {
+	s, err := readString(path)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(s)
+	res := make([]int, len(fields))
+	for x, f := range fields {
+		if res[x], err = strconv.Atoi(f); err != nil {
+			return nil, InvalidSysfs(path, err.Error())
+		}
+	}
+	return res, nil
+}
+
+// hugepages returns the hugepage pools in a NUMA node's hugepages
+// directory, which contains a "hugepages-<size>kB" directory for each
+// hugepage size, sorted by size. A missing directory means no pools.
+func hugepages(dir string) ([]types.Hugepages, error) {
+	res := []types.Hugepages{}
+	poolDirs, err := filepath.Glob(filepath.Join(dir, "hugepages-*kB"))
+	if err != nil {
+		return nil, err
+	}
+	for _, poolDir := range poolDirs {
+		sizeStr := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(poolDir), "hugepages-"), "kB")
+		sizeKB, err := strconv.Atoi(sizeStr)
+		if err != nil {
+			return nil, InvalidSysfs(poolDir, "unexpected hugepages directory name")
+		}
+		total, err := readInt(filepath.Join(poolDir, "nr_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+		free, err := readInt(filepath.Join(poolDir, "free_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, types.Hugepages{
+			SizeBytes: float64(sizeKB) * unit.Ki,
+			Total:     total,
+			Free:      free,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].SizeBytes < res[j].SizeBytes
+	})
+	return res, nil
+}
+
+// readString returns the trimmed contents of a sysfs file
+func readString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", InvalidSysfs(path, err.Error())
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readInt returns the integer contents of a sysfs file
+func readInt(path string) (int, error) {
+	s, err := readString(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, InvalidSysfs(path, err.Error())
+	}
+	return v, nil
+}
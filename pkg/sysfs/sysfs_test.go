@@ -0,0 +1,95 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package sysfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/sysfs"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func testRoot(name string) string {
+	return filepath.Join("..", "..", "test", "testdata", "sysfs", name)
+}
+
+func TestNUMACells(t *testing.T) {
+	cells, err := sysfs.NUMACells(testRoot("two-socket-smt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 NUMA cells but got %d", len(cells))
+	}
+
+	cell := cells[1]
+	if cell.ID != 1 || cell.SocketID != 1 {
+		t.Fatalf("expected NUMA cell 1 on socket 1 but got cell %d on socket %d", cell.ID, cell.SocketID)
+	}
+	expCores := []types.CPUCore{
+		{ID: 0, CPUs: []int{2, 6}},
+		{ID: 1, CPUs: []int{3, 7}},
+	}
+	if !reflect.DeepEqual(cell.Cores, expCores) {
+		t.Fatalf("expected cores %v but got %v", expCores, cell.Cores)
+	}
+	if cell.ThreadsPerCore() != 2 {
+		t.Fatalf("expected 2 threads per core but got %d", cell.ThreadsPerCore())
+	}
+	if cell.Resources.CPU.Capacity != 4 {
+		t.Fatalf("expected 4 CPUs but got %.0f", cell.Resources.CPU.Capacity)
+	}
+	if cell.Resources.Memory.Capacity != 16*unit.Gi {
+		t.Fatalf("expected 16Gi of memory but got %s", unit.BytesToSizeString(cell.Resources.Memory.Capacity))
+	}
+	if cell.Resources.Memory.Used != 4*unit.Gi {
+		t.Fatalf("expected 4Gi of memory used but got %s", unit.BytesToSizeString(cell.Resources.Memory.Used))
+	}
+	if !reflect.DeepEqual(cell.Distances, []int{21, 10}) {
+		t.Fatalf("expected distances [21 10] but got %v", cell.Distances)
+	}
+
+	expHugepages := []types.Hugepages{
+		{SizeBytes: 2 * unit.Mi, Total: 512, Free: 256},
+		{SizeBytes: unit.Gi, Total: 2, Free: 1},
+	}
+	if !reflect.DeepEqual(cells[0].Hugepages, expHugepages) {
+		t.Fatalf("expected hugepages %v but got %v", expHugepages, cells[0].Hugepages)
+	}
+}
+
+func TestNUMACellsSharedCoreIDs(t *testing.T) {
+	// A single NUMA cell spanning two sockets, each with cores 0 and 1
+	cells, err := sysfs.NUMACells(testRoot("one-node-two-socket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 1 {
+		t.Fatalf("expected 1 NUMA cell but got %d", len(cells))
+	}
+	expCores := []types.CPUCore{
+		{ID: 0, CPUs: []int{0}},
+		{ID: 1, CPUs: []int{1}},
+		{ID: 0, CPUs: []int{2}},
+		{ID: 1, CPUs: []int{3}},
+	}
+	if !reflect.DeepEqual(cells[0].Cores, expCores) {
+		t.Fatalf("expected cores %v but got %v", expCores, cells[0].Cores)
+	}
+	if cells[0].ThreadsPerCore() != 1 {
+		t.Fatalf("expected 1 thread per core but got %d", cells[0].ThreadsPerCore())
+	}
+}
+
+func TestNUMACellsNoNodes(t *testing.T) {
+	_, err := sysfs.NUMACells(t.TempDir())
+	if !errors.Is(err, sysfs.ErrInvalidSysfs) {
+		t.Fatalf("expected ErrInvalidSysfs but got %v", err)
+	}
+}
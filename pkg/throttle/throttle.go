@@ -0,0 +1,87 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package throttle
+
+import (
+	"math"
+	"sort"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	// cfsPeriodSeconds is the length of the default CFS enforcement period
+	// the kubelet configures for containers with a CPU limit
+	cfsPeriodSeconds = 0.1
+	// ceilingStepsPerCore is the number of steps per core suggested CPU
+	// ceilings are rounded up to, i.e. 100m steps
+	ceilingStepsPerCore = 10
+)
+
+// Entry describes the CFS throttling of a single Pod with a CPU limit
+type Entry struct {
+	// Pod is the throttled Pod
+	Pod *types.Pod
+	// Ceiling is the Pod's CPU ceiling (the sum of its containers' limits)
+	Ceiling float64
+	// Throttling contains the Pod's CFS throttling counters
+	Throttling types.CPUThrottling
+	// ThrottledRatio is the fraction of CFS periods in which the Pod was
+	// throttled, from 0 to 1
+	ThrottledRatio float64
+	// SuggestedCeiling is a CPU ceiling under which the Pod would not have
+	// been throttled. It is the same as Ceiling if the Pod was not
+	// throttled.
+	SuggestedCeiling float64
+}
+
+// Rank returns an Entry for each of the supplied Pods that has a CPU ceiling
+// and known throttling counters, sorted by most throttled periods first
+func Rank(pods []*types.Pod) []Entry {
+	res := []Entry{}
+	for _, p := range pods {
+		ceiling := p.ResourceRequests.CPU.Ceiling
+		if ceiling <= 0 || p.Usage == nil || p.Usage.Throttling == nil {
+			continue
+		}
+		t := *p.Usage.Throttling
+		e := Entry{
+			Pod:              p,
+			Ceiling:          ceiling,
+			Throttling:       t,
+			SuggestedCeiling: SuggestCeiling(ceiling, t),
+		}
+		if t.Periods > 0 {
+			e.ThrottledRatio = t.ThrottledPeriods / t.Periods
+		}
+		res = append(res, e)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Throttling.ThrottledPeriods != res[j].Throttling.ThrottledPeriods {
+			return res[i].Throttling.ThrottledPeriods > res[j].Throttling.ThrottledPeriods
+		}
+		return res[i].ThrottledRatio > res[j].ThrottledRatio
+	})
+	return res
+}
+
+// SuggestCeiling returns a CPU ceiling, rounded up to the nearest 100m, under
+// which a Pod with the supplied ceiling and throttling counters would not
+// have been throttled.
+//
+// In each throttled period the Pod used its whole quota (the ceiling) and
+// then waited for the rest of the period. The average time waited per
+// throttled period, as a fraction of the period, is the additional CPU the
+// Pod wanted in those periods.
+func SuggestCeiling(ceiling float64, t types.CPUThrottling) float64 {
+	if t.ThrottledPeriods <= 0 || t.ThrottledSeconds <= 0 {
+		return ceiling
+	}
+	wanted := t.ThrottledSeconds / (t.ThrottledPeriods * cfsPeriodSeconds)
+	// The small epsilon keeps an exact step from being rounded up to the
+	// next one due to floating point error
+	steps := math.Ceil((ceiling+wanted)*ceilingStepsPerCore - 1e-9)
+	return steps / ceilingStepsPerCore
+}
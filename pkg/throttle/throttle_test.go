@@ -0,0 +1,91 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package throttle_test
+
+import (
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/throttle"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+func TestSuggestCeiling(t *testing.T) {
+	tcs := []struct {
+		name       string
+		ceiling    float64
+		throttling types.CPUThrottling
+		exp        float64
+	}{
+		{"not throttled", 1, types.CPUThrottling{Periods: 1000}, 1},
+		{
+			// 25ms waited per throttled 100ms period is another 250m
+			"throttled",
+			1,
+			types.CPUThrottling{Periods: 1000, ThrottledPeriods: 200, ThrottledSeconds: 5},
+			1.3,
+		},
+		{
+			// Exactly another 500m is not rounded up further
+			"exact",
+			0.5,
+			types.CPUThrottling{Periods: 100, ThrottledPeriods: 100, ThrottledSeconds: 5},
+			1,
+		},
+	}
+	for _, tc := range tcs {
+		got := throttle.SuggestCeiling(tc.ceiling, tc.throttling)
+		if got != tc.exp {
+			t.Fatalf("%s: expected %.2f but got %.2f", tc.name, tc.exp, got)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	pods := []*types.Pod{
+		{
+			Name:             "unbounded",
+			ResourceRequests: types.ResourceRequests{CPU: types.ResourceRequest{Floor: 0.5, Ceiling: -1}},
+			Usage: &types.PodUsage{
+				Throttling: &types.CPUThrottling{Periods: 100, ThrottledPeriods: 90},
+			},
+		},
+		{Name: "unknown", ResourceRequests: types.ResourceRequests{CPU: types.ResourceRequest{Floor: 0.5, Ceiling: 1}}},
+		{
+			Name:             "mild",
+			ResourceRequests: types.ResourceRequests{CPU: types.ResourceRequest{Floor: 0.5, Ceiling: 2}},
+			Usage: &types.PodUsage{
+				Throttling: &types.CPUThrottling{Periods: 1000, ThrottledPeriods: 10, ThrottledSeconds: 0.1},
+			},
+		},
+		{
+			Name:             "severe",
+			ResourceRequests: types.ResourceRequests{CPU: types.ResourceRequest{Floor: 0.5, Ceiling: 1}},
+			Usage: &types.PodUsage{
+				Throttling: &types.CPUThrottling{Periods: 1000, ThrottledPeriods: 500, ThrottledSeconds: 25},
+			},
+		},
+		{
+			Name:             "idle",
+			ResourceRequests: types.ResourceRequests{CPU: types.ResourceRequest{Floor: 0.5, Ceiling: 1}},
+			Usage:            &types.PodUsage{Throttling: &types.CPUThrottling{}},
+		},
+	}
+	got := throttle.Rank(pods)
+	exp := []string{"severe", "mild", "idle"}
+	if len(got) != len(exp) {
+		t.Fatalf("expected %d entries but got %d", len(exp), len(got))
+	}
+	for x, name := range exp {
+		if got[x].Pod.Name != name {
+			t.Fatalf("expected %s at position %d but got %s", name, x, got[x].Pod.Name)
+		}
+	}
+	if got[0].ThrottledRatio != 0.5 || got[0].SuggestedCeiling != 1.5 {
+		t.Fatalf(
+			"expected a throttled ratio of 0.5 and suggested ceiling of 1.5 but got %.2f and %.2f",
+			got[0].ThrottledRatio, got[0].SuggestedCeiling,
+		)
+	}
+}
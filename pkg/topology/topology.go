@@ -0,0 +1,417 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package topology
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	kwerrors "github.com/jaypipes/kwiz/pkg/errors"
+	"github.com/jaypipes/kwiz/pkg/fit"
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+const (
+	PolicyNone           = "none"
+	PolicyBestEffort     = "best-effort"
+	PolicyRestricted     = "restricted"
+	PolicySingleNUMANode = "single-numa-node"
+	ScopeContainer       = "container"
+	ScopePod             = "pod"
+	// maxCells is the maximum number of NUMA cells we can simulate. NUMA
+	// affinities are represented as a bitmask and every combination of
+	// cells is considered, like the kubelet, which refuses to start with
+	// more than 8 NUMA cells.
+	maxCells = 8
+	// cpuManagerPolicyStatic and memoryManagerPolicyStatic are the kubelet
+	// CPU and memory manager policies that give NUMA affinity hints
+	cpuManagerPolicyStatic    = "static"
+	memoryManagerPolicyStatic = "Static"
+)
+
+var (
+	// Policies contains the Topology Manager policies, in order of
+	// increasing strictness
+	Policies = []string{
+		PolicyNone, PolicyBestEffort, PolicyRestricted, PolicySingleNUMANode,
+	}
+	// Scopes contains the Topology Manager scopes
+	Scopes = []string{ScopeContainer, ScopePod}
+)
+
+var (
+	// ErrInvalidPolicy is returned when an unknown Topology Manager policy
+	// or scope is supplied.
+	ErrInvalidPolicy = fmt.Errorf(
+		"%w: invalid topology manager policy",
+		kwerrors.RuntimeError,
+	)
+)
+
+// InvalidPolicy returns ErrInvalidPolicy with some further context
+func InvalidPolicy(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPolicy, reason)
+}
+
+// Result describes whether the kubelet Topology Manager would admit a Pod
+// to a Node and which NUMA cells the Pod's resources would be aligned to
+type Result struct {
+	// Policy is the Topology Manager policy simulated
+	Policy string
+	// Scope is the Topology Manager scope simulated
+	Scope string
+	// Admitted is true if the Pod would be admitted
+	Admitted bool
+	// Cells contains the IDs of the NUMA cells the Pod's exclusive
+	// resources would land on. Empty if the Pod has no resources the
+	// Topology Manager aligns or the policy does no alignment.
+	Cells []int
+	// Reason explains why the Pod would be rejected with a
+	// TopologyAffinityError. Empty if the Pod would be admitted.
+	Reason string
+}
+
+// hint is a NUMA affinity hint: a bitmask of NUMA cell indexes and whether
+// the affinity is preferred (i.e. uses as few cells as possible)
+type hint struct {
+	mask      uint64
+	preferred bool
+}
+
+// Managers describes which of the kubelet's resource managers give the
+// Topology Manager NUMA affinity hints
+type Managers struct {
+	// StaticCPU is true if the CPU manager policy is static, which gives
+	// hints for whole-number CPU requests of Guaranteed Pods
+	StaticCPU bool
+	// StaticMemory is true if the memory manager policy is Static, which
+	// gives hints for the memory requests of Guaranteed Pods
+	StaticMemory bool
+}
+
+// ManagersFromKubelet returns the resource managers that give NUMA affinity
+// hints with the supplied kubelet configuration
+func ManagersFromKubelet(cfg *types.KubeletConfig) Managers {
+	return Managers{
+		StaticCPU:    cfg.CPUManagerPolicy == cpuManagerPolicyStatic,
+		StaticMemory: cfg.MemoryManagerPolicy == memoryManagerPolicyStatic,
+	}
+}
+
+// Simulate returns the result of the kubelet Topology Manager admitting the
+// supplied Pod to a Node with the supplied NUMA cells, using the supplied
+// policy and scope.
+//
+// The static CPU manager and memory manager policies are assumed, so only
+// Guaranteed Pods get NUMA affinity hints: for whole-number CPU requests and
+// for memory. The free CPU and memory of each cell is its allocatable amount
+// less its requested floor.
+func Simulate(
+	pod *types.Pod,
+	cells []types.NUMACell,
+	policy string,
+	scope string,
+) (*Result, error) {
+	return SimulateWith(pod, cells, policy, scope, Managers{
+		StaticCPU:    true,
+		StaticMemory: true,
+	})
+}
+
+// SimulateWith is like Simulate but only the supplied resource managers
+// give NUMA affinity hints
+func SimulateWith(
+	pod *types.Pod,
+	cells []types.NUMACell,
+	policy string,
+	scope string,
+	managers Managers,
+) (*Result, error) {
+	if err := validate(policy, scope, cells); err != nil {
+		return nil, err
+	}
+	res := &Result{
+		Policy:   policy,
+		Scope:    scope,
+		Admitted: true,
+		Cells:    []int{},
+	}
+	if policy == PolicyNone || pod.QOSClass != types.QOSGuaranteed || len(cells) == 0 {
+		return res, nil
+	}
+
+	freeCPU := make([]float64, len(cells))
+	freeMem := make([]float64, len(cells))
+	for x, cell := range cells {
+		freeCPU[x] = fit.Free(cell.Resources.CPU)
+		freeMem[x] = fit.Free(cell.Resources.Memory)
+	}
+
+	type request struct {
+		name string
+		cpu  float64
+		mem  float64
+	}
+	reqs := []request{}
+	if scope == ScopePod {
+		cpu, mem := float64(0), float64(0)
+		for _, ctr := range pod.Containers {
+			if !alignedContainer(ctr) {
+				continue
+			}
+			cpu += exclusiveCPU(ctr)
+			mem += containerFloor(ctr.ResourceRequests.Memory)
+		}
+		reqs = append(reqs, request{name: "pod " + pod.Name, cpu: cpu, mem: mem})
+	} else {
+		for _, ctr := range pod.Containers {
+			if !alignedContainer(ctr) {
+				continue
+			}
+			reqs = append(reqs, request{
+				name: "container " + ctr.Name,
+				cpu:  exclusiveCPU(ctr),
+				mem:  containerFloor(ctr.ResourceRequests.Memory),
+			})
+		}
+	}
+
+	var used uint64
+	for _, req := range reqs {
+		if !managers.StaticCPU {
+			req.cpu = 0
+		}
+		if !managers.StaticMemory {
+			req.mem = 0
+		}
+		// No hint provider has a preference, which every policy admits
+		if req.cpu <= 0 && req.mem <= 0 {
+			continue
+		}
+		providers := [][]hint{
+			hintsFor(req.cpu, freeCPU, cellAllocatable(cells, true)),
+			hintsFor(req.mem, freeMem, cellAllocatable(cells, false)),
+		}
+		best := merge(providers, len(cells), policy)
+		if !admits(best, policy) {
+			res.Admitted = false
+			res.Reason = fmt.Sprintf(
+				"TopologyAffinityError: %s: no NUMA affinity satisfies the %s policy",
+				req.name, policy,
+			)
+			res.Cells = []int{}
+			return res, nil
+		}
+		used |= consume(freeCPU, best.mask, req.cpu)
+		used |= consume(freeMem, best.mask, req.mem)
+	}
+	for x, cell := range cells {
+		if used&(1<<x) != 0 {
+			res.Cells = append(res.Cells, cell.ID)
+		}
+	}
+	return res, nil
+}
+
+// validate returns an error if the supplied policy, scope or number of NUMA
+// cells cannot be simulated
+func validate(policy string, scope string, cells []types.NUMACell) error {
+	validPolicy := false
+	for _, p := range Policies {
+		validPolicy = validPolicy || p == policy
+	}
+	if !validPolicy {
+		return InvalidPolicy(fmt.Sprintf("unknown policy %q", policy))
+	}
+	if scope != ScopeContainer && scope != ScopePod {
+		return InvalidPolicy(fmt.Sprintf("unknown scope %q", scope))
+	}
+	if len(cells) > maxCells {
+		return InvalidPolicy(fmt.Sprintf("cannot simulate more than %d NUMA cells", maxCells))
+	}
+	return nil
+}
+
+// alignedContainer returns true if the supplied container's resources are
+// aligned by the Topology Manager. Init containers run to completion before
+// the app containers start and their resources are reused by them, and
+// ephemeral containers have no resources.
+func alignedContainer(ctr types.Container) bool {
+	return ctr.Type == types.ContainerTypeApp || ctr.Type == types.ContainerTypeSidecar
+}
+
+// exclusiveCPU returns the number of exclusive CPUs the static CPU manager
+// would assign to the supplied container of a Guaranteed Pod, which is 0
+// unless the container requests a whole number of CPUs
+func exclusiveCPU(ctr types.Container) float64 {
+	cpu := containerFloor(ctr.ResourceRequests.CPU)
+	if cpu != math.Trunc(cpu) {
+		return 0
+	}
+	return cpu
+}
+
+// containerFloor returns the request floor of a container resource, which
+// the API server defaults to the ceiling if not set
+func containerFloor(req types.ResourceRequest) float64 {
+	if req.Floor != -1 {
+		return req.Floor
+	}
+	return max(req.Ceiling, 0)
+}
+
+// cellAllocatable returns the allocatable CPU or memory of each NUMA cell
+func cellAllocatable(cells []types.NUMACell, cpu bool) []float64 {
+	res := make([]float64, len(cells))
+	for x, cell := range cells {
+		if cpu {
+			res[x] = cell.Resources.CPU.Allocatable
+		} else {
+			res[x] = cell.Resources.Memory.Allocatable
+		}
+	}
+	return res
+}
+
+// hintsFor returns the NUMA affinity hints a hint provider would give for a
+// request of a resource with the supplied free and allocatable amounts per
+// NUMA cell. A nil slice means the provider has no preference (nothing is
+// requested). An empty slice means no combination of cells has room.
+//
+// Like the kubelet, a hint is preferred if it has the minimum number of
+// cells whose allocatable amounts could ever satisfy the request.
+func hintsFor(request float64, free []float64, alloc []float64) []hint {
+	if request <= 0 {
+		return nil
+	}
+	minCells := len(alloc) + 1
+	hints := []hint{}
+	for mask := uint64(1); mask < uint64(1)<<len(free); mask++ {
+		if sumMasked(alloc, mask) >= request {
+			minCells = min(minCells, bits.OnesCount64(mask))
+		}
+		if sumMasked(free, mask) >= request {
+			hints = append(hints, hint{mask: mask})
+		}
+	}
+	for x := range hints {
+		hints[x].preferred = bits.OnesCount64(hints[x].mask) == minCells
+	}
+	return hints
+}
+
+// merge returns the best NUMA affinity from every combination of the
+// supplied hint providers' hints, the way the Topology Manager merges them
+// for the supplied policy
+func merge(providers [][]hint, numCells int, policy string) hint {
+	all := uint64(1)<<numCells - 1
+	candidates := make([][]hint, len(providers))
+	for x, hints := range providers {
+		switch {
+		case hints == nil:
+			candidates[x] = []hint{{mask: all, preferred: true}}
+		case policy == PolicySingleNUMANode:
+			single := []hint{}
+			for _, h := range hints {
+				if h.preferred && bits.OnesCount64(h.mask) == 1 {
+					single = append(single, h)
+				}
+			}
+			candidates[x] = single
+		default:
+			candidates[x] = hints
+		}
+		if len(candidates[x]) == 0 {
+			candidates[x] = []hint{{mask: all, preferred: false}}
+		}
+	}
+	best := hint{mask: all, preferred: false}
+	permute(candidates, 0, hint{mask: all, preferred: true}, func(h hint) {
+		if h.mask == 0 {
+			return
+		}
+		if better(h, best) {
+			best = h
+		}
+	})
+	return best
+}
+
+// permute calls fn with the merge of every combination of one hint from
+// each of the supplied providers
+func permute(providers [][]hint, x int, acc hint, fn func(hint)) {
+	if x == len(providers) {
+		fn(acc)
+		return
+	}
+	for _, h := range providers[x] {
+		permute(providers, x+1, hint{
+			mask:      acc.mask & h.mask,
+			preferred: acc.preferred && h.preferred,
+		}, fn)
+	}
+}
+
+// better returns true if hint a is better than hint b: preferred hints beat
+// non-preferred ones, then narrower masks beat wider ones, then masks of
+// lower-numbered cells win
+func better(a hint, b hint) bool {
+	if a.preferred != b.preferred {
+		return a.preferred
+	}
+	ac, bc := bits.OnesCount64(a.mask), bits.OnesCount64(b.mask)
+	if ac != bc {
+		return ac < bc
+	}
+	return a.mask < b.mask
+}
+
+// admits returns true if the supplied policy admits a Pod with the supplied
+// best merged hint
+func admits(best hint, policy string) bool {
+	switch policy {
+	case PolicyRestricted:
+		return best.preferred
+	case PolicySingleNUMANode:
+		return best.preferred && bits.OnesCount64(best.mask) == 1
+	}
+	return true
+}
+
+// sumMasked returns the sum of the amounts of the cells in the mask
+func sumMasked(amounts []float64, mask uint64) float64 {
+	sum := float64(0)
+	for x, v := range amounts {
+		if mask&(1<<x) != 0 {
+			sum += v
+		}
+	}
+	return sum
+}
+
+// consume subtracts the supplied amount from the free amounts of the cells
+// in the mask, filling the lowest-numbered cells first. Like the kubelet's
+// resource managers, if the cells in the mask do not have enough free, the
+// remainder is taken from other cells. Returns the mask of the cells taken
+// from.
+func consume(free []float64, mask uint64, amount float64) uint64 {
+	taken := uint64(0)
+	for _, inMask := range []bool{true, false} {
+		for x := range free {
+			if amount <= 0 {
+				return taken
+			}
+			if (mask&(1<<x) != 0) != inMask || free[x] <= 0 {
+				continue
+			}
+			take := min(free[x], amount)
+			free[x] -= take
+			amount -= take
+			taken |= 1 << x
+		}
+	}
+	return taken
+}
@@ -0,0 +1,582 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package topology_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/topology"
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func TestSimulate(t *testing.T) {
+	tcs := []struct {
+		name     string
+		pod      *types.Pod
+		cells    []types.NUMACell
+		policy   string
+		scope    string
+		admitted bool
+		expCells []int
+	}{
+		{
+			name: "none policy does no alignment",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 12, Ceiling: 12},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicyNone,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{},
+		},
+		{
+			name: "fits in first cell",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 4, Ceiling: 4},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{0},
+		},
+		{
+			name: "fits in second cell",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 4, Ceiling: 4},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 6},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 2},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{1},
+		},
+		{
+			name: "free CPUs split across cells",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 8, Ceiling: 8},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopeContainer,
+			admitted: false,
+			expCells: []int{},
+		},
+		{
+			name: "restricted rejects non-preferred affinity",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 8, Ceiling: 8},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicyRestricted,
+			scope:    topology.ScopeContainer,
+			admitted: false,
+			expCells: []int{},
+		},
+		{
+			name: "best-effort admits non-preferred affinity",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 8, Ceiling: 8},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicyBestEffort,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{0, 1},
+		},
+		{
+			name: "restricted admits request larger than a cell",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 12, Ceiling: 12},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicyRestricted,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{0, 1},
+		},
+		{
+			name: "container scope aligns containers separately",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 6, Ceiling: 6},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 6, Ceiling: 6},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{0, 1},
+		},
+		{
+			name: "pod scope aligns all containers together",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 6, Ceiling: 6},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 6, Ceiling: 6},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopePod,
+			admitted: false,
+			expCells: []int{},
+		},
+		{
+			name: "pod scope fits",
+			pod: &types.Pod{
+				Name:     "numa",
+				QOSClass: types.QOSGuaranteed,
+				Containers: []types.Container{
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 2, Ceiling: 2},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+					{
+						Name: "app",
+						Type: types.ContainerTypeApp,
+						ResourceRequests: types.ResourceRequests{
+							CPU:    types.ResourceRequest{Floor: 2, Ceiling: 2},
+							Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+						},
+						QOSClass: types.QOSGuaranteed,
+					},
+				},
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 2},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopePod,
+			admitted: true,
+			expCells: []int{0},
+		},
+		{
+			name: "burstable pods are not aligned",
+			pod: &types.Pod{
+				Name:     "burstable",
+				QOSClass: types.QOSBurstable,
+			},
+			cells: []types.NUMACell{
+				{
+					ID: 0,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+				{
+					ID: 1,
+					Resources: types.Resources{
+						CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 8},
+						Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+					},
+				},
+			},
+			policy:   topology.PolicySingleNUMANode,
+			scope:    topology.ScopeContainer,
+			admitted: true,
+			expCells: []int{},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := topology.Simulate(tc.pod, tc.cells, tc.policy, tc.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Admitted != tc.admitted {
+				t.Fatalf("expected admitted %v but got %v (%s)", tc.admitted, res.Admitted, res.Reason)
+			}
+			if !reflect.DeepEqual(res.Cells, tc.expCells) {
+				t.Fatalf("expected cells %v but got %v", tc.expCells, res.Cells)
+			}
+			if !res.Admitted && res.Reason == "" {
+				t.Fatalf("expected a reason for rejection")
+			}
+		})
+	}
+}
+
+func TestSimulateWithManagers(t *testing.T) {
+	// 8 CPUs only fit across both cells, but without the static CPU manager
+	// policy only memory is aligned and it fits in a single cell
+	pod := &types.Pod{
+		Name:     "numa",
+		QOSClass: types.QOSGuaranteed,
+		Containers: []types.Container{
+			{
+				Name: "app",
+				Type: types.ContainerTypeApp,
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 8, Ceiling: 8},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+				},
+				QOSClass: types.QOSGuaranteed,
+			},
+		},
+	}
+	cells := []types.NUMACell{
+		{
+			ID: 0,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+			},
+		},
+		{
+			ID: 1,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8, RequestedFloor: 4},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+			},
+		},
+	}
+	res, err := topology.SimulateWith(
+		pod, cells, topology.PolicySingleNUMANode, topology.ScopePod,
+		topology.Managers{StaticMemory: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Admitted || !reflect.DeepEqual(res.Cells, []int{0}) {
+		t.Fatalf("expected admission to cell 0 but got %+v", res)
+	}
+	res, err = topology.SimulateWith(
+		pod, cells, topology.PolicySingleNUMANode, topology.ScopePod,
+		topology.Managers{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Admitted || len(res.Cells) != 0 {
+		t.Fatalf("expected admission without alignment but got %+v", res)
+	}
+}
+
+func TestSimulateInvalid(t *testing.T) {
+	pod := &types.Pod{
+		Name:     "numa",
+		QOSClass: types.QOSGuaranteed,
+		Containers: []types.Container{
+			{
+				Name: "app",
+				Type: types.ContainerTypeApp,
+				ResourceRequests: types.ResourceRequests{
+					CPU:    types.ResourceRequest{Floor: 1, Ceiling: 1},
+					Memory: types.ResourceRequest{Floor: unit.Gi, Ceiling: unit.Gi},
+				},
+				QOSClass: types.QOSGuaranteed,
+			},
+		},
+	}
+	cells := []types.NUMACell{
+		{
+			ID: 0,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+			},
+		},
+		{
+			ID: 1,
+			Resources: types.Resources{
+				CPU:    types.ResourceAmounts{Allocatable: 8},
+				Memory: types.ResourceAmounts{Allocatable: 32 * unit.Gi},
+			},
+		},
+	}
+	_, err := topology.Simulate(pod, cells, "strict", topology.ScopePod)
+	if !errors.Is(err, topology.ErrInvalidPolicy) {
+		t.Fatalf("expected ErrInvalidPolicy but got %v", err)
+	}
+	_, err = topology.Simulate(pod, cells, topology.PolicyNone, "node")
+	if !errors.Is(err, topology.ErrInvalidPolicy) {
+		t.Fatalf("expected ErrInvalidPolicy but got %v", err)
+	}
+	_, err = topology.Simulate(pod, make([]types.NUMACell, 9), topology.PolicyNone, topology.ScopePod)
+	if !errors.Is(err, topology.ErrInvalidPolicy) {
+		t.Fatalf("expected ErrInvalidPolicy for 9 NUMA cells but got %v", err)
+	}
+}
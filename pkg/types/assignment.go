@@ -0,0 +1,55 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// ContainerAssignment contains the CPUs, NUMA-local memory and devices the
+// kubelet has assigned to a single container
+type ContainerAssignment struct {
+	// PodUID is the UID of the container's Pod. Empty if the assignment was
+	// read from a source that only knows Pod names.
+	PodUID string `json:"podUID"`
+	// PodNamespace is the namespace of the container's Pod. Empty if the
+	// assignment was read from a source that only knows Pod UIDs.
+	PodNamespace string `json:"podNamespace"`
+	// PodName is the name of the container's Pod. Empty if the assignment
+	// was read from a source that only knows Pod UIDs.
+	PodName string `json:"podName"`
+	// ContainerName is the name of the container in its Pod. Empty if the
+	// assignment was read from a source that only knows container IDs.
+	ContainerName string `json:"containerName"`
+	// ContainerID is the runtime ID of the container, if known
+	ContainerID string `json:"containerID"`
+	// CPUs contains the IDs of the logical CPUs assigned to the container
+	CPUs []int `json:"cpus"`
+	// Memory contains the blocks of memory assigned to the container
+	Memory []MemoryBlock `json:"memory"`
+	// Devices contains the devices assigned to the container by device
+	// plugins
+	Devices []DeviceAssignment `json:"devices"`
+}
+
+// MemoryBlock is an amount of a single type of memory assigned to a
+// container from a set of NUMA cells
+type MemoryBlock struct {
+	// NUMACells contains the IDs of the NUMA cells the memory comes from
+	NUMACells []int `json:"numaCells"`
+	// Type is the type of memory, e.g. "memory" or "hugepages-1Gi"
+	Type string `json:"type"`
+	// Size is the number of bytes of memory
+	Size float64 `json:"size"`
+}
+
+// DeviceAssignment is a set of devices of a single extended resource
+// assigned to a container
+type DeviceAssignment struct {
+	// ResourceName is the name of the extended resource, e.g.
+	// "nvidia.com/gpu"
+	ResourceName string `json:"resourceName"`
+	// DeviceIDs contains the device plugin's IDs of the devices
+	DeviceIDs []string `json:"deviceIDs"`
+	// NUMACells contains the IDs of the NUMA cells the devices have affinity
+	// to. Empty if unknown.
+	NUMACells []int `json:"numaCells"`
+}
@@ -0,0 +1,52 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// ContainerType describes the role of a container within a Pod
+type ContainerType string
+
+const (
+	// ContainerTypeInit is an init container that runs to completion before
+	// the Pod's app containers start
+	ContainerTypeInit ContainerType = "init"
+	// ContainerTypeSidecar is an init container with a restartPolicy of
+	// Always that keeps running alongside the Pod's app containers
+	ContainerTypeSidecar ContainerType = "sidecar"
+	// ContainerTypeApp is one of the Pod's regular containers
+	ContainerTypeApp ContainerType = "app"
+	// ContainerTypeEphemeral is an ephemeral (debug) container
+	ContainerTypeEphemeral ContainerType = "ephemeral"
+)
+
+// QOSClass is the Kubernetes quality of service class of a Pod
+type QOSClass string
+
+const (
+	// QOSGuaranteed means every container has CPU and memory limits equal to
+	// its requests
+	QOSGuaranteed QOSClass = "Guaranteed"
+	// QOSBurstable means at least one container has a CPU or memory request
+	// or limit, but the Pod does not qualify as Guaranteed
+	QOSBurstable QOSClass = "Burstable"
+	// QOSBestEffort means no container has any CPU or memory requests or
+	// limits
+	QOSBestEffort QOSClass = "BestEffort"
+)
+
+// Container represents a single container within a Kubernetes Pod
+type Container struct {
+	// Name is the name of the container
+	Name string `json:"name"`
+	// Type is the role of the container within the Pod
+	Type ContainerType `json:"type"`
+	// ResourceRequests contains the floor (requests) and ceiling (limits)
+	// amounts of resources for the container. -1.0 means the container has
+	// no request or limit for that amount.
+	ResourceRequests ResourceRequests `json:"resourceRequests"`
+	// QOSClass is the QoS class the container's requests and limits are
+	// compatible with. A Pod is only Guaranteed if all its containers are
+	// and only BestEffort if all its containers are.
+	QOSClass QOSClass `json:"qosClass"`
+}
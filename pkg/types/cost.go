@@ -0,0 +1,34 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// Cost contains the hourly cost of a provider of resources (or a group of
+// providers), broken down by how the provider's capacity is being used.
+//
+// Reserved + Allocatable == Hourly and Requested + Idle == Allocatable.
+type Cost struct {
+	// Hourly is the total hourly price of the provider
+	Hourly float64 `json:"hourly"`
+	// Reserved is the hourly cost of the capacity reserved for the system
+	Reserved float64 `json:"reserved"`
+	// Allocatable is the hourly cost of the capacity that may be allocated
+	// to consumers
+	Allocatable float64 `json:"allocatable"`
+	// Requested is the hourly cost of the allocatable capacity that has been
+	// requested (the request floor) by consumers
+	Requested float64 `json:"requested"`
+	// Idle is the hourly cost of the allocatable capacity that nobody has
+	// requested
+	Idle float64 `json:"idle"`
+}
+
+// Add adds the amounts in another Cost to this Cost
+func (c *Cost) Add(other Cost) {
+	c.Hourly += other.Hourly
+	c.Reserved += other.Reserved
+	c.Allocatable += other.Allocatable
+	c.Requested += other.Requested
+	c.Idle += other.Idle
+}
@@ -0,0 +1,18 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// PodGroup represents a set of Pods that share some grouping key, such as a
+// namespace or the value of a label
+type PodGroup struct {
+	// Name is the grouping key shared by all Pods in the group. An empty
+	// string means the Pods have no value for the grouping key.
+	Name string `json:"name"`
+	// Pods contains the Pods in the group
+	Pods []*Pod `json:"pods"`
+	// ResourceRequests contains the sum of the floor and ceiling amounts of
+	// resources requested by all Pods in the group
+	ResourceRequests ResourceRequests `json:"resourceRequests"`
+}
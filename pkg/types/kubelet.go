@@ -0,0 +1,51 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// KubeletConfig contains the kubelet configuration settings on a Node that
+// affect how the Node's resources are reserved and how containers are
+// aligned to NUMA cells
+type KubeletConfig struct {
+	// CPUManagerPolicy is the kubelet's CPU manager policy: "none" or
+	// "static"
+	CPUManagerPolicy string `json:"cpuManagerPolicy"`
+	// CPUManagerPolicyOptions contains the options of the static CPU manager
+	// policy, e.g. "full-pcpus-only": "true"
+	CPUManagerPolicyOptions map[string]string `json:"cpuManagerPolicyOptions"`
+	// MemoryManagerPolicy is the kubelet's memory manager policy: "None" or
+	// "Static"
+	MemoryManagerPolicy string `json:"memoryManagerPolicy"`
+	// TopologyManagerPolicy is the kubelet's Topology Manager policy:
+	// "none", "best-effort", "restricted" or "single-numa-node"
+	TopologyManagerPolicy string `json:"topologyManagerPolicy"`
+	// TopologyManagerScope is the granularity at which the Topology Manager
+	// aligns resources: "container" or "pod"
+	TopologyManagerScope string `json:"topologyManagerScope"`
+	// ReservedSystemCPUs is the cpuset of CPUs reserved for system and
+	// kubelet daemons, e.g. "0-1". Empty if not set.
+	ReservedSystemCPUs string `json:"reservedSystemCPUs"`
+	// KubeReserved contains the amounts of resources, keyed by resource
+	// name, reserved for Kubernetes system daemons
+	KubeReserved map[string]string `json:"kubeReserved"`
+	// SystemReserved contains the amounts of resources, keyed by resource
+	// name, reserved for OS system daemons
+	SystemReserved map[string]string `json:"systemReserved"`
+	// EvictionHard contains the kubelet's hard eviction thresholds, keyed by
+	// signal, e.g. "memory.available": "100Mi"
+	EvictionHard map[string]string `json:"evictionHard"`
+	// ReservedMemory contains the memory reserved in each NUMA cell for the
+	// static memory manager policy
+	ReservedMemory []ReservedMemory `json:"reservedMemory"`
+}
+
+// ReservedMemory contains the amounts of memory reserved by the kubelet in
+// a single NUMA cell
+type ReservedMemory struct {
+	// NUMACellID is the ID of the NUMA cell
+	NUMACellID int `json:"numaCellID"`
+	// Limits contains the reserved amounts, keyed by resource name, e.g.
+	// "memory" or "hugepages-1Gi"
+	Limits map[string]string `json:"limits"`
+}
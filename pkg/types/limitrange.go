@@ -0,0 +1,21 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// LimitRange represents a Kubernetes LimitRange that supplies default
+// requests and limits to containers in a namespace
+type LimitRange struct {
+	// Cluster is the name of the Kubernetes cluster
+	Cluster string `json:"cluster"`
+	// Namespace is the Kubernetes namespace the LimitRange applies to
+	Namespace string `json:"namespace"`
+	// Name is the name of the LimitRange
+	Name string `json:"name"`
+	// ContainerDefaults contains the defaults applied to containers that do
+	// not specify their own requests or limits. The Floor is the default
+	// request and the Ceiling is the default limit. -1.0 means the
+	// LimitRange has no default for that amount.
+	ContainerDefaults ResourceRequests `json:"containerDefaults"`
+}
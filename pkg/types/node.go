@@ -7,17 +7,41 @@ package types
 // Node represents a Kubernetes node in the cluster
 type Node struct {
 	// Cluster is the name of the Kubernetes cluster
-	Cluster string
+	Cluster string `json:"cluster"`
 	// Name is the name of the Kubernetes node
-	Name string
+	Name string `json:"name"`
 	// Address contains the internal IP address of the Kubernetes node
-	Address string
+	Address string `json:"address"`
+	// InstanceType is the value of the Node's
+	// `node.kubernetes.io/instance-type` label, if any
+	InstanceType string `json:"instanceType"`
+	// Labels contains the Kubernetes labels on the Node
+	Labels map[string]string `json:"labels"`
+	// Taints contains the Kubernetes taints on the Node
+	Taints []Taint `json:"taints"`
 	// Resources contains the capacity, reserved amount and used amount of
 	// various system resources on the Node. If the Node is representing a
 	// machine with multiple NUMA cells, Resources contains ALL resources,
 	// regardless of NUMA cell.
-	Resources Resources
+	Resources Resources `json:"resources"`
 	// NUMACells contains the NUMACell structs for each NUMA node/cell in the
 	// host machine.
-	NUMACells []NUMACell
+	NUMACells []NUMACell `json:"numaCells"`
+	// KubeletConfig contains the Node's kubelet configuration. nil if the
+	// kubelet configuration was not requested or could not be read.
+	KubeletConfig *KubeletConfig `json:"kubeletConfig"`
+	// KubeletConfigError is why the Node's kubelet configuration could not
+	// be read, if it was requested. Empty otherwise.
+	KubeletConfigError string `json:"kubeletConfigError"`
+}
+
+// Taint represents a Kubernetes taint on a Node that repels Pods that do not
+// tolerate it
+type Taint struct {
+	// Key is the taint key
+	Key string `json:"key"`
+	// Value is the taint value
+	Value string `json:"value"`
+	// Effect is the taint effect: NoSchedule, PreferNoSchedule or NoExecute
+	Effect string `json:"effect"`
 }
@@ -8,7 +8,69 @@ package types
 // typically be a baremetal machine, however a virtual machine may be
 // configured to emulate multiple NUMA cells.
 type NUMACell struct {
+	// ID is the NUMA node/cell identifier on the host
+	ID int `json:"id"`
+	// SocketID is the identifier of the physical CPU socket (package) the
+	// NUMA cell belongs to. -1 if unknown.
+	SocketID int `json:"socketID"`
+	// Cores contains the physical CPU cores in the NUMA cell. Empty if the
+	// CPU topology of the NUMA cell is unknown.
+	Cores []CPUCore `json:"cores"`
+	// Distances contains the relative distance from this NUMA cell to each
+	// NUMA cell on the host, indexed by NUMA cell ID, as reported by the
+	// ACPI SLIT. Empty if unknown.
+	Distances []int `json:"distances"`
+	// Hugepages contains the hugepage pools of the NUMA cell, one for each
+	// hugepage size
+	Hugepages []Hugepages `json:"hugepages"`
+	// Devices contains the number of devices of each extended resource with
+	// affinity to the NUMA cell
+	Devices []Devices `json:"devices"`
+	// AllocatedCPUs contains the IDs of the logical CPUs in the NUMA cell
+	// that are exclusively allocated to containers. nil if unknown.
+	AllocatedCPUs []int `json:"allocatedCPUs"`
 	// Resources contains the capacity, reserved amount and used amount of
 	// various system resources in this NUMACell
-	Resources Resources
+	Resources Resources `json:"resources"`
+}
+
+// CPUCore represents a single physical CPU core and its logical CPUs, which
+// are hyperthread siblings if the core has more than one
+type CPUCore struct {
+	// ID is the core identifier within its socket
+	ID int `json:"id"`
+	// CPUs contains the IDs of the logical CPUs of the core
+	CPUs []int `json:"cpus"`
+}
+
+// ThreadsPerCore returns the largest number of logical CPUs of any physical
+// core in the NUMA cell, or 0 if the CPU topology is unknown
+func (c NUMACell) ThreadsPerCore() int {
+	res := 0
+	for _, core := range c.Cores {
+		res = max(res, len(core.CPUs))
+	}
+	return res
+}
+
+// Hugepages describes the pool of hugepages of a single size in a NUMA cell
+type Hugepages struct {
+	// SizeBytes is the size of each hugepage in bytes
+	SizeBytes float64 `json:"sizeBytes"`
+	// Total is the number of hugepages pre-allocated in the pool
+	Total int `json:"total"`
+	// Free is the number of hugepages in the pool not in use
+	Free int `json:"free"`
+}
+
+// Devices describes the devices of a single extended resource with affinity
+// to a NUMA cell
+type Devices struct {
+	// ResourceName is the name of the extended resource, e.g.
+	// "nvidia.com/gpu"
+	ResourceName string `json:"resourceName"`
+	// Total is the number of allocatable devices
+	Total int `json:"total"`
+	// Allocated is the number of devices assigned to containers
+	Allocated int `json:"allocated"`
 }
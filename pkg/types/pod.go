@@ -7,14 +7,92 @@ package types
 // Pod represents a Kubernetes pod
 type Pod struct {
 	// Cluster is the name of the Kubernetes cluster
-	Cluster string
+	Cluster string `json:"cluster"`
 	// Node is the name of the Kubernetes node the Pod is on
-	Node string
+	Node string `json:"node"`
 	// Namespace is the Kubernetes namesapce the Pod is in
-	Namespace string
+	Namespace string `json:"namespace"`
 	// Name is the name of the Pod
-	Name string
+	Name string `json:"name"`
+	// UID is the Kubernetes UID of the Pod
+	UID string `json:"uid"`
+	// Phase is the Pod's lifecycle phase (e.g. Pending, Running)
+	Phase string `json:"phase"`
+	// Labels contains the Kubernetes labels on the Pod
+	Labels map[string]string `json:"labels"`
+	// OwnerKind is the Kind of the Pod's controller (e.g. ReplicaSet,
+	// DaemonSet), if any
+	OwnerKind string `json:"ownerKind"`
+	// OwnerName is the name of the Pod's controller, if any
+	OwnerName string `json:"ownerName"`
+	// NodeSelector contains the labels a Node must have for the Pod to be
+	// scheduled to it
+	NodeSelector map[string]string `json:"nodeSelector"`
+	// RequiredNodeAffinity contains the terms of the Pod's required node
+	// affinity. A Node must match at least one of them for the Pod to be
+	// scheduled to it. Empty if the Pod has no required node affinity.
+	RequiredNodeAffinity []NodeSelectorTerm `json:"requiredNodeAffinity"`
+	// Tolerations contains the Pod's tolerations of Node taints
+	Tolerations []Toleration `json:"tolerations"`
+	// QOSClass is the Pod's Kubernetes quality of service class
+	QOSClass QOSClass `json:"qosClass"`
+	// ExclusiveCPUs is the number of CPUs the Pod would be granted exclusive
+	// use of under the static CPU manager policy: the sum of the integer CPU
+	// requests of its app and sidecar containers if the Pod is Guaranteed,
+	// otherwise 0.
+	ExclusiveCPUs float64 `json:"exclusiveCPUs"`
+	// Containers contains the Pod's init, sidecar, app and ephemeral
+	// containers
+	Containers []Container `json:"containers"`
+	// Assignments contains the CPUs and NUMA-local memory the kubelet has
+	// exclusively assigned to the Pod's containers, as reported by the kwiz
+	// agent. Empty if unknown.
+	Assignments []ContainerAssignment `json:"assignments"`
+	// Usage contains the Pod's actual resource usage, as reported by the
+	// kwiz agent. nil if unknown.
+	Usage *PodUsage `json:"usage"`
 	// ResourceRequests contains the floor and ceiling amounts of resources
 	// requested by all containers in the Pod
-	ResourceRequests ResourceRequests
+	ResourceRequests ResourceRequests `json:"resourceRequests"`
+	// AdjustedResourceRequests contains the floor and ceiling amounts of
+	// resources requested by all containers in the Pod after applying the
+	// defaults of any LimitRange in the Pod's namespace to containers that
+	// have no requests or limits of their own. It is the same as
+	// ResourceRequests if LimitRanges were not considered.
+	AdjustedResourceRequests ResourceRequests `json:"adjustedResourceRequests"`
+}
+
+// NodeSelectorTerm represents a term of a Pod's required node affinity. A
+// Node matches the term if it matches all of the term's requirements.
+type NodeSelectorTerm struct {
+	// MatchExpressions contains requirements on the Node's labels
+	MatchExpressions []NodeSelectorRequirement `json:"matchExpressions"`
+	// MatchFields contains requirements on the Node's fields. Only the
+	// metadata.name field is supported by Kubernetes.
+	MatchFields []NodeSelectorRequirement `json:"matchFields"`
+}
+
+// NodeSelectorRequirement represents a requirement on a Node label or field
+type NodeSelectorRequirement struct {
+	// Key is the label key or field name the requirement applies to
+	Key string `json:"key"`
+	// Operator is one of In, NotIn, Exists, DoesNotExist, Gt or Lt
+	Operator string `json:"operator"`
+	// Values contains the values the Operator compares the label or field
+	// value to
+	Values []string `json:"values"`
+}
+
+// Toleration represents a Pod's toleration of Node taints
+type Toleration struct {
+	// Key is the taint key the toleration applies to. An empty Key with the
+	// Exists operator matches all taint keys.
+	Key string `json:"key"`
+	// Operator is either Exists or Equal. An empty Operator means Equal.
+	Operator string `json:"operator"`
+	// Value is the taint value the toleration matches when Operator is Equal
+	Value string `json:"value"`
+	// Effect is the taint effect the toleration matches. An empty Effect
+	// matches all taint effects.
+	Effect string `json:"effect"`
 }
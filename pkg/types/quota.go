@@ -0,0 +1,23 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// ResourceQuota represents a Kubernetes ResourceQuota constraining the
+// aggregate resource consumption of Pods in a namespace
+type ResourceQuota struct {
+	// Cluster is the name of the Kubernetes cluster
+	Cluster string `json:"cluster"`
+	// Namespace is the Kubernetes namespace the ResourceQuota constrains
+	Namespace string `json:"namespace"`
+	// Name is the name of the ResourceQuota
+	Name string `json:"name"`
+	// Hard contains the quota's hard limits. The Floor is the limit on the
+	// sum of requests and the Ceiling is the limit on the sum of limits. -1.0
+	// means the quota places no limit on that amount.
+	Hard ResourceRequests `json:"hard"`
+	// Used contains the amounts of the quota's resources currently consumed
+	// in the namespace. -1.0 means the quota does not track that amount.
+	Used ResourceRequests `json:"used"`
+}
@@ -8,41 +8,83 @@ package types
 // system resources on the provider of resources (either Node or NUMA cell)
 type Resources struct {
 	// CPU contains CPU resource amounts
-	CPU ResourceAmounts
+	CPU ResourceAmounts `json:"cpu"`
 	// Memory contains RAM resource amounts
-	Memory ResourceAmounts
+	Memory ResourceAmounts `json:"memory"`
 	// Pods contains the amounts of Pod resources
-	Pods ResourceAmounts
+	Pods ResourceAmounts `json:"pods"`
 }
 
 // ResourceAmounts contains a single resource's capacity, reserved amount and
 // used amount.
 type ResourceAmounts struct {
 	// Capacity is the total amount of this resource
-	Capacity float64
+	Capacity float64 `json:"capacity"`
 	// Allocatable is the amount of this resource that may be allocated to
 	// consumers
-	Allocatable float64
+	Allocatable float64 `json:"allocatable"`
 	// Reserved is the amount of this resource reserved for the system
-	Reserved float64
+	Reserved float64 `json:"reserved"`
+	// ReservedBreakdown attributes Reserved to the kubelet settings that
+	// cause it. nil if the kubelet configuration is unknown.
+	ReservedBreakdown *ReservedBreakdown `json:"reservedBreakdown"`
 	// RequestedFloor is the floor amount of this resource that has been
-	// requested by consumers
-	RequestedFloor float64
+	// requested by consumers. -1.0 means unknown, e.g. for a NUMA cell only
+	// known from a kwiz agent report, which cannot attribute the requests of
+	// Pods in the shared pool to NUMA cells.
+	RequestedFloor float64 `json:"requestedFloor"`
 	// RequestedCeiling is the maximum amount of this resource that has been
 	// requested by consumers
-	RequestedCeiling float64
+	RequestedCeiling float64 `json:"requestedCeiling"`
+	// DaemonSetRequestedFloor is the portion of RequestedFloor that has been
+	// requested by consumers owned by a DaemonSet
+	DaemonSetRequestedFloor float64 `json:"daemonSetRequestedFloor"`
+	// ExclusiveRequestedFloor is the portion of RequestedFloor that has been
+	// requested by consumers that are granted exclusive use of the resource
+	// (e.g. CPUs pinned to Guaranteed Pods by the static CPU manager). -1.0
+	// means unknown, e.g. because the Node's CPU manager policy is unknown.
+	ExclusiveRequestedFloor float64 `json:"exclusiveRequestedFloor"`
+	// AdjustedRequestedCeiling is the RequestedCeiling after applying the
+	// default limits of LimitRanges to consumers that have no limits of their
+	// own. -1.0 means some consumer remains unbounded.
+	AdjustedRequestedCeiling float64 `json:"adjustedRequestedCeiling"`
 	// Used is the reported actual amount of this resource being actively
-	// consumed (includes system usage)
-	Used float64
+	// consumed (includes system usage). -1.0 means unknown, e.g. because no
+	// kwiz agent reports the usage of the Node.
+	Used float64 `json:"used"`
+}
+
+// ReservedBreakdown contains the portions of a resource's Reserved amount
+// attributed to each kubelet setting that reserves the resource
+type ReservedBreakdown struct {
+	// KubeReserved is the amount reserved by the kubelet's kubeReserved
+	// setting
+	KubeReserved float64 `json:"kubeReserved"`
+	// SystemReserved is the amount reserved by the kubelet's
+	// systemReserved setting
+	SystemReserved float64 `json:"systemReserved"`
+	// ReservedSystemCPUs is the number of CPUs in the kubelet's
+	// reservedSystemCPUs setting, which replaces the CPU amounts of
+	// kubeReserved and systemReserved when set
+	ReservedSystemCPUs float64 `json:"reservedSystemCPUs"`
+	// EvictionHard is the amount reserved by the kubelet's hard eviction
+	// threshold for the resource
+	EvictionHard float64 `json:"evictionHard"`
+	// Hugepages is the amount of memory pre-allocated to hugepages, which
+	// is not allocatable as regular memory
+	Hugepages float64 `json:"hugepages"`
+	// Unexplained is the remainder of Reserved not attributed to any of the
+	// above. It may be negative if the above add up to more than Reserved.
+	Unexplained float64 `json:"unexplained"`
 }
 
 // ResourceRequests contains the floor and ceiling requests of various system
 // resources by a single consumer (Pod)
 type ResourceRequests struct {
 	// CPU contains CPU resource request
-	CPU ResourceRequest
+	CPU ResourceRequest `json:"cpu"`
 	// Memory contains RAM resource request
-	Memory ResourceRequest
+	Memory ResourceRequest `json:"memory"`
 }
 
 // ResourceRequests contains the floor and ceiling request for a particular
@@ -50,8 +92,67 @@ type ResourceRequests struct {
 type ResourceRequest struct {
 	// Floor is the floor amount of this resource that has been requested by
 	// the consumer.
-	Floor float64
+	Floor float64 `json:"floor"`
 	// Ceiling is the max/ceiling amount of this resource that has been
 	// requested by the consumer. -1.0 means there is no ceiling.
-	Ceiling float64
+	Ceiling float64 `json:"ceiling"`
+}
+
+// Add adds the amounts in another Resources to this Resources
+func (r *Resources) Add(other Resources) {
+	r.CPU.Add(other.CPU)
+	r.Memory.Add(other.Memory)
+	r.Pods.Add(other.Pods)
+}
+
+// Add adds the amounts in another ResourceAmounts to this ResourceAmounts.
+//
+// If either RequestedCeiling is -1, there is some consumer with no limit on
+// this resource that can potentially consume all of it, and the resulting
+// RequestedCeiling is -1. If either ExclusiveRequestedFloor or Used is
+// unknown (-1), the resulting ExclusiveRequestedFloor or Used is unknown
+// too.
+func (a *ResourceAmounts) Add(other ResourceAmounts) {
+	a.Capacity += other.Capacity
+	a.Allocatable += other.Allocatable
+	a.Reserved += other.Reserved
+	a.RequestedFloor += other.RequestedFloor
+	a.DaemonSetRequestedFloor += other.DaemonSetRequestedFloor
+	if a.ExclusiveRequestedFloor == -1 || other.ExclusiveRequestedFloor == -1 {
+		a.ExclusiveRequestedFloor = -1
+	} else {
+		a.ExclusiveRequestedFloor += other.ExclusiveRequestedFloor
+	}
+	if a.RequestedCeiling == -1 || other.RequestedCeiling == -1 {
+		a.RequestedCeiling = -1
+	} else {
+		a.RequestedCeiling += other.RequestedCeiling
+	}
+	if a.AdjustedRequestedCeiling == -1 || other.AdjustedRequestedCeiling == -1 {
+		a.AdjustedRequestedCeiling = -1
+	} else {
+		a.AdjustedRequestedCeiling += other.AdjustedRequestedCeiling
+	}
+	if a.Used == -1 || other.Used == -1 {
+		a.Used = -1
+	} else {
+		a.Used += other.Used
+	}
+}
+
+// Add adds the requests in another ResourceRequests to this ResourceRequests
+func (r *ResourceRequests) Add(other ResourceRequests) {
+	r.CPU.Add(other.CPU)
+	r.Memory.Add(other.Memory)
+}
+
+// Add adds another ResourceRequest to this ResourceRequest. If either
+// Ceiling is -1 (no ceiling), the resulting Ceiling is -1.
+func (r *ResourceRequest) Add(other ResourceRequest) {
+	r.Floor += other.Floor
+	if r.Ceiling == -1 || other.Ceiling == -1 {
+		r.Ceiling = -1
+	} else {
+		r.Ceiling += other.Ceiling
+	}
 }
@@ -0,0 +1,66 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// PodUsage contains the actual resource usage of a single Pod, as read from
+// the Pod's cgroups on its Node
+type PodUsage struct {
+	// PodUID is the UID of the Pod
+	PodUID string `json:"podUID"`
+	// Resources contains the CPU (in cores) and memory (working set, in
+	// bytes) Used by the Pod
+	Resources Resources `json:"resources"`
+	// NUMACells contains the Pod's usage attributed to each NUMA cell it
+	// runs on. Empty if unknown.
+	NUMACells []NUMACellUsage `json:"numaCells"`
+	// Throttling contains the Pod's CFS throttling counters. nil if unknown.
+	Throttling *CPUThrottling `json:"throttling"`
+	// CPUStats contains percentiles of the Pod's CPU usage over a window of
+	// time. nil if the Pod's usage history is unknown.
+	CPUStats *UsageStats `json:"cpuStats"`
+	// MemoryStats contains percentiles of the Pod's memory usage over a
+	// window of time. nil if the Pod's usage history is unknown.
+	MemoryStats *UsageStats `json:"memoryStats"`
+}
+
+// UsageStats contains percentiles of a single resource's usage over a
+// window of time
+type UsageStats struct {
+	// Samples is the number of samples the percentiles are calculated from
+	Samples int `json:"samples"`
+	// P50 is the median usage
+	P50 float64 `json:"p50"`
+	// P90 is the 90th percentile of usage
+	P90 float64 `json:"p90"`
+	// P95 is the 95th percentile of usage
+	P95 float64 `json:"p95"`
+	// P99 is the 99th percentile of usage
+	P99 float64 `json:"p99"`
+	// Max is the largest usage sampled
+	Max float64 `json:"max"`
+}
+
+// CPUThrottling contains the CFS bandwidth control counters of a Pod with a
+// CPU limit, either since the Pod started or over some window of time
+type CPUThrottling struct {
+	// Periods is the number of CFS enforcement periods that have elapsed
+	Periods float64 `json:"periods"`
+	// ThrottledPeriods is the number of periods in which the Pod used its
+	// whole CPU quota and was throttled
+	ThrottledPeriods float64 `json:"throttledPeriods"`
+	// ThrottledSeconds is the total time the Pod was throttled for
+	ThrottledSeconds float64 `json:"throttledSeconds"`
+}
+
+// NUMACellUsage contains the portion of a Pod's resource usage attributed to
+// a single NUMA cell
+type NUMACellUsage struct {
+	// ID is the NUMA node/cell identifier on the host
+	ID int `json:"id"`
+	// CPU is the number of cores used in the NUMA cell
+	CPU float64 `json:"cpu"`
+	// Memory is the number of bytes of the NUMA cell's memory used
+	Memory float64 `json:"memory"`
+}
@@ -0,0 +1,31 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package types
+
+// Workload represents the top-level controller of a set of Pods, such as a
+// Deployment, StatefulSet, DaemonSet, Job or CronJob. Pods with no
+// controller are represented as a Workload of Kind "Pod".
+type Workload struct {
+	// Cluster is the name of the Kubernetes cluster
+	Cluster string `json:"cluster"`
+	// Namespace is the Kubernetes namespace the Workload is in
+	Namespace string `json:"namespace"`
+	// Kind is the Kind of the Workload's top-level controller
+	Kind string `json:"kind"`
+	// Name is the name of the Workload's top-level controller
+	Name string `json:"name"`
+	// Pods contains the Workload's Pods (replicas)
+	Pods []*Pod `json:"pods"`
+	// PerReplica contains the floor and ceiling amounts of resources
+	// requested by the first of the Workload's Pods. Replicas created from
+	// the same template request the same amounts.
+	PerReplica ResourceRequests `json:"perReplica"`
+	// ResourceRequests contains the sum of the floor and ceiling amounts of
+	// resources requested by all of the Workload's Pods
+	ResourceRequests ResourceRequests `json:"resourceRequests"`
+	// Nodes contains the sorted, unique names of the Nodes the Workload's
+	// Pods are running on
+	Nodes []string `json:"nodes"`
+}
@@ -0,0 +1,40 @@
+//
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+//
+
+package unit
+
+import (
+	"strconv"
+	"strings"
+)
+
+// cpuSuffixDivisors contains the divisors of the fractional core suffixes
+// of CPU quantity strings. The resource metrics API reports usage in
+// nanocores.
+var cpuSuffixDivisors = map[string]float64{
+	"m": 1e3,
+	"u": 1e6,
+	"n": 1e9,
+}
+
+// CPUStringToCores returns the number of CPU cores given a CPU quantity
+// string such as "2", "0.5", "250m" or "12345678n".
+func CPUStringToCores(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	divisor := float64(1)
+	for suffix, d := range cpuSuffixDivisors {
+		if strings.HasSuffix(s, suffix) {
+			divisor = d
+			s = s[0 : len(s)-1]
+			break
+		}
+	}
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return amount / divisor, nil
+}
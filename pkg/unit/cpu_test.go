@@ -0,0 +1,39 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package unit_test
+
+import (
+	"testing"
+
+	"github.com/jaypipes/kwiz/pkg/unit"
+)
+
+func TestCPUStringToCores(t *testing.T) {
+	tcs := []struct {
+		val string
+		exp float64
+	}{
+		{"2", float64(2)},
+		{"0.5", float64(0.5)},
+		{"250m", float64(0.25)},
+		{" 1500m ", float64(1.5)},
+		{"250000u", float64(0.25)},
+		{"12500000n", float64(0.0125)},
+	}
+
+	for _, tc := range tcs {
+		got, err := unit.CPUStringToCores(tc.val)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != tc.exp {
+			t.Fatalf("expected %.3f but got %.3f", tc.exp, got)
+		}
+	}
+
+	if _, err := unit.CPUStringToCores("lots"); err == nil {
+		t.Fatalf("expected error for invalid CPU string")
+	}
+}
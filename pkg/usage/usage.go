@@ -0,0 +1,154 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package usage
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+)
+
+// ContainerKey identifies a single container of a Pod
+type ContainerKey struct {
+	// Namespace is the namespace of the container's Pod
+	Namespace string
+	// Pod is the name of the container's Pod
+	Pod string
+	// Container is the name of the container
+	Container string
+}
+
+// Sample is a single observation of the usage of a resource
+type Sample struct {
+	// Time is when the usage was observed
+	Time time.Time
+	// Value is the amount of the resource used
+	Value float64
+}
+
+// Samples contains observations of the resource usage of a single container,
+// oldest first
+type Samples struct {
+	// CPU contains samples of the number of cores used
+	CPU []Sample
+	// Memory contains samples of the number of bytes of memory (working
+	// set) used
+	Memory []Sample
+}
+
+// Values returns the values of the supplied samples
+func Values(samples []Sample) []float64 {
+	res := make([]float64, len(samples))
+	for x, s := range samples {
+		res[x] = s.Value
+	}
+	return res
+}
+
+// Scope limits the containers whose usage a Source returns
+type Scope struct {
+	// Namespace, if not empty, limits the containers to those of Pods in
+	// this namespace
+	Namespace string
+	// Pod, if not empty, limits the containers to those of Pods with this
+	// name
+	Pod string
+}
+
+// Source is a source of the historical resource usage of containers
+type Source interface {
+	// ContainerUsage returns samples of the resource usage of every
+	// container in the supplied scope over the supplied window ending now.
+	// A Source with no history returns a single, current sample of each
+	// container.
+	ContainerUsage(
+		ctx context.Context,
+		window time.Duration,
+		scope Scope,
+	) (map[ContainerKey]*Samples, error)
+}
+
+// Percentile returns the nearest-rank percentile (0 to 100) of the supplied
+// values, or -1 if there are no values
+func Percentile(values []float64, pct float64) float64 {
+	if len(values) == 0 {
+		return -1
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(pct / 100 * float64(len(sorted))))
+	return sorted[min(max(rank-1, 0), len(sorted)-1)]
+}
+
+// Stats returns percentiles of the supplied usage samples, or nil if there
+// are no samples
+func Stats(values []float64) *types.UsageStats {
+	if len(values) == 0 {
+		return nil
+	}
+	return &types.UsageStats{
+		Samples: len(values),
+		P50:     Percentile(values, 50),
+		P90:     Percentile(values, 90),
+		P95:     Percentile(values, 95),
+		P99:     Percentile(values, 99),
+		Max:     Percentile(values, 100),
+	}
+}
+
+// AttachToPods sets the Usage of each of the supplied Pods with samples of
+// any of its containers. Each Pod's usage samples are the sums of its
+// containers' samples taken at the same time, since containers may have
+// started at different times. The Pod's Used amounts are its newest samples
+// and its stats are calculated from all of its samples.
+func AttachToPods(pods []*types.Pod, samples map[ContainerKey]*Samples) {
+	byPod := map[string][]*Samples{}
+	for key, s := range samples {
+		podKey := key.Namespace + "/" + key.Pod
+		byPod[podKey] = append(byPod[podKey], s)
+	}
+	for _, p := range pods {
+		ctrSamples, ok := byPod[p.Namespace+"/"+p.Name]
+		if !ok {
+			continue
+		}
+		cpu := []Sample{}
+		mem := []Sample{}
+		for _, s := range ctrSamples {
+			cpu = sumByTime(cpu, s.CPU)
+			mem = sumByTime(mem, s.Memory)
+		}
+		if p.Usage == nil {
+			p.Usage = &types.PodUsage{PodUID: p.UID}
+		}
+		if len(cpu) > 0 {
+			p.Usage.Resources.CPU.Used = cpu[len(cpu)-1].Value
+			p.Usage.CPUStats = Stats(Values(cpu))
+		}
+		if len(mem) > 0 {
+			p.Usage.Resources.Memory.Used = mem[len(mem)-1].Value
+			p.Usage.MemoryStats = Stats(Values(mem))
+		}
+	}
+}
+
+// sumByTime returns the sum of the supplied series, oldest first, adding up
+// the values of samples taken at the same time
+func sumByTime(a []Sample, b []Sample) []Sample {
+	byTime := map[int64]Sample{}
+	for _, s := range append(append([]Sample{}, a...), b...) {
+		sum := byTime[s.Time.UnixNano()]
+		byTime[s.Time.UnixNano()] = Sample{Time: s.Time, Value: sum.Value + s.Value}
+	}
+	res := make([]Sample, 0, len(byTime))
+	for _, s := range byTime {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Time.Before(res[j].Time) })
+	return res
+}
@@ -0,0 +1,99 @@
+// Use and distribution licensed under the Apache license version 2.
+//
+// See the COPYING file in the root project directory for full text.
+
+package usage_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jaypipes/kwiz/pkg/types"
+	"github.com/jaypipes/kwiz/pkg/usage"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{15, 20, 35, 40, 50}
+	tcs := []struct {
+		pct float64
+		exp float64
+	}{
+		{0, 15},
+		{5, 15},
+		{30, 20},
+		{40, 20},
+		{50, 35},
+		{90, 50},
+		{100, 50},
+	}
+	for _, tc := range tcs {
+		got := usage.Percentile(values, tc.pct)
+		if got != tc.exp {
+			t.Fatalf("p%.0f: expected %.0f but got %.0f", tc.pct, tc.exp, got)
+		}
+	}
+	if got := usage.Percentile(nil, 50); got != -1 {
+		t.Fatalf("expected -1 without values but got %.0f", got)
+	}
+}
+
+func TestAttachToPods(t *testing.T) {
+	pods := []*types.Pod{
+		{Namespace: "default", Name: "web", UID: "web-uid"},
+		{Namespace: "default", Name: "idle"},
+	}
+	samples := map[usage.ContainerKey]*usage.Samples{
+		{Namespace: "default", Pod: "web", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 1},
+				{Time: time.Unix(60, 0), Value: 2},
+				{Time: time.Unix(120, 0), Value: 3},
+				{Time: time.Unix(180, 0), Value: 4},
+			},
+			Memory: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 100},
+				{Time: time.Unix(60, 0), Value: 200},
+				{Time: time.Unix(120, 0), Value: 300},
+				{Time: time.Unix(180, 0), Value: 400},
+			},
+		},
+		// The sidecar started after the app container and its newest
+		// sample is missing
+		{Namespace: "default", Pod: "web", Container: "sidecar"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(60, 0), Value: 0.5},
+				{Time: time.Unix(120, 0), Value: 0.5},
+			},
+			Memory: []usage.Sample{
+				{Time: time.Unix(60, 0), Value: 50},
+				{Time: time.Unix(120, 0), Value: 50},
+			},
+		},
+		{Namespace: "other", Pod: "web", Container: "app"}: {
+			CPU: []usage.Sample{
+				{Time: time.Unix(0, 0), Value: 100},
+			},
+		},
+	}
+	usage.AttachToPods(pods, samples)
+
+	u := pods[0].Usage
+	if u == nil || u.PodUID != "web-uid" {
+		t.Fatalf("expected usage of the web Pod but got %+v", u)
+	}
+	if u.Resources.CPU.Used != 4 || u.Resources.Memory.Used != 400 {
+		t.Fatalf(
+			"expected 4 CPUs and 400 bytes used but got %.2f and %.0f",
+			u.Resources.CPU.Used, u.Resources.Memory.Used,
+		)
+	}
+	// The Pod's CPU samples are 1, 2.5, 3.5 and 4
+	expCPU := &types.UsageStats{Samples: 4, P50: 2.5, P90: 4, P95: 4, P99: 4, Max: 4}
+	if !reflect.DeepEqual(u.CPUStats, expCPU) {
+		t.Fatalf("expected CPU stats %+v but got %+v", expCPU, u.CPUStats)
+	}
+	if pods[1].Usage != nil {
+		t.Fatalf("expected no usage of a Pod without samples but got %+v", pods[1].Usage)
+	}
+}